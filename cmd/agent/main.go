@@ -5,16 +5,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/agent"
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/config"
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"log"
 	//nolint:gosec // G108
@@ -30,7 +33,8 @@ func main() {
 	}
 	defer l.Sync()
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	// the container runtimes stop the agent with SIGTERM, the last metrics are sent on any of the signals
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
 
 	cfg, err := config.NewConfig()
@@ -38,18 +42,70 @@ func main() {
 		l.FatalCtx(ctx, "failed to get config", zap.Error(err))
 	}
 
+	setLogLevel(l, cfg.LogLevel)
+
 	l.InfoCtx(ctx, "Agent run with cfg", zap.Any("cfg", cfg))
 
-	if cfg.PprofAddr == "" {
-		agent.Run(ctx, cfg, l)
-	} else {
-		go agent.Run(ctx, cfg, l)
-		server := &http.Server{
-			Addr:              cfg.PprofAddr,
-			ReadHeaderTimeout: 3 * time.Second,
+	reload := make(chan *config.Config)
+	go reloadConfig(ctx, cfg, l, reload)
+
+	if cfg.PprofAddr != "" {
+		go servePprof(ctx, cfg.PprofAddr, l)
+	}
+	agent.Run(ctx, cfg, l, reload)
+	l.InfoCtx(context.WithoutCancel(ctx), "Agent stopped")
+}
+
+// servePprof serves pprof on the address until the context is done
+func servePprof(ctx context.Context, addr string, l *logging.ZapLogger) {
+	server := &http.Server{
+		Addr:              addr,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			l.ErrorCtx(context.WithoutCancel(ctx), fmt.Errorf("failed to close pprof server: %w", err).Error())
+		}
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		l.FatalCtx(ctx, fmt.Errorf("pprof server error: %w", err).Error())
+	}
+}
+
+// reloadConfig reads the config again on SIGHUP and passes the running config with the reloadable fields
+// applied to the agent, the changed fields are logged.
+func reloadConfig(ctx context.Context, cfg *config.Config, l *logging.ZapLogger, reload chan<- *config.Config) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	running := *cfg
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+		}
+		newCfg, err := config.Reload()
+		if err != nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to reload config, the running config is kept: %w", err).Error())
+			continue
 		}
-		if err = server.ListenAndServe(); err != nil {
-			l.FatalCtx(ctx, fmt.Errorf("pprof server error: %w", err).Error())
+		applied, restart := config.Apply(&running, newCfg)
+		setLogLevel(l, running.LogLevel)
+		applying := running
+		select {
+		case reload <- &applying:
+		case <-ctx.Done():
+			return
 		}
+		l.InfoCtx(ctx, "config reloaded", zap.Strings("applied", applied), zap.Strings("restart_required", restart))
+	}
+}
+
+// setLogLevel sets the log level validated by the config
+func setLogLevel(l *logging.ZapLogger, level string) {
+	if lvl, err := zapcore.ParseLevel(level); err == nil {
+		l.SetLevel(lvl)
 	}
 }
@@ -10,11 +10,13 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/server"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/config"
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 //go:generate go run ../../tools/genversion
@@ -33,6 +35,7 @@ func main() {
 	if err != nil {
 		l.FatalCtx(ctx, fmt.Errorf("failed to get config: %w", err).Error())
 	}
+	setLogLevel(l, cfg.LogLevel)
 	h := server.NewHandler()
 	defer func() {
 		l.InfoCtx(ctx, "Closing handler...")
@@ -43,8 +46,52 @@ func main() {
 	if err = h.Configure(ctx, cfg, l); err != nil {
 		l.FatalCtx(ctx, fmt.Errorf("failed to configure handler: %w", err).Error())
 	}
+	go reloadConfig(ctx, cfg, h, l)
+	if cfg.GRPCAddr != "" {
+		go serveGRPC(ctx, cfg, h, l)
+	}
 	l.InfoCtx(ctx, "Server started on http://"+cfg.Addr+"/", zap.Any("config", cfg))
 	if err = server.ListenAndServe(ctx, l, cfg.Addr, cfg.ShutdownTimeout, h); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		l.FatalCtx(ctx, "failed to start server", zap.Error(err))
 	}
 }
+
+// serveGRPC starts the gRPC server on cfg.GRPCAddr, the server application is stopped if it fails to start
+func serveGRPC(ctx context.Context, cfg *config.Config, h *server.Handler, l *logging.ZapLogger) {
+	l.InfoCtx(ctx, "gRPC server started on "+cfg.GRPCAddr)
+	if err := server.ServeGRPC(ctx, l, cfg.GRPCAddr, cfg.ShutdownTimeout, h.NewGRPCServer(l)); err != nil {
+		l.FatalCtx(ctx, "failed to start gRPC server", zap.Error(err))
+	}
+}
+
+// reloadConfig reads the config again on SIGHUP and applies the reloadable fields to the handler and the logger,
+// the changed fields are logged.
+func reloadConfig(ctx context.Context, cfg *config.Config, h *server.Handler, l *logging.ZapLogger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	running := *cfg
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+		}
+		newCfg, err := config.Reload()
+		if err != nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to reload config, the running config is kept: %w", err).Error())
+			continue
+		}
+		applied, restart := config.Apply(&running, newCfg)
+		setLogLevel(l, running.LogLevel)
+		h.Reload(&running)
+		l.InfoCtx(ctx, "config reloaded", zap.Strings("applied", applied), zap.Strings("restart_required", restart))
+	}
+}
+
+// setLogLevel sets the log level validated by the config
+func setLogLevel(l *logging.ZapLogger, level string) {
+	if lvl, err := zapcore.ParseLevel(level); err == nil {
+		l.SetLevel(lvl)
+	}
+}
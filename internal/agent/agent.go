@@ -5,53 +5,261 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/config"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/outbox"
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/service"
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"go.uber.org/zap"
 )
 
-// Run starts the agent.
-func Run(ctx context.Context, cfg *config.Config, l *logging.ZapLogger) {
-	source := service.NewSource()
+// Run starts the agent and blocks until the context is done.
+//
+// The configs received from reload are applied to the running agent without losing the collected metrics:
+// the poll and report intervals, the rate limit and the key are changed, the other fields need a restart.
+//
+// The metrics are sent to the servers of cfg.Senders according to cfg.SendMode: in the failover mode
+// the sender switches to the next server when the server fails, in the fanout mode every batch
+// is delivered to all servers, each of them keeps the metrics which failed to send to it.
+//
+// The metrics are sent over HTTP or gRPC according to cfg.Transport, the metadata is sent only over HTTP.
+//
+// The agent self-telemetry is collected with the metrics, its IDs have the service.TelemetryPrefix.
+//
+// When the context is done, polling and reporting are stopped and the metrics collected since the last report
+// are sent and committed once more within ShutdownTimeout, so the last poll and PollCount are not lost.
+func Run(ctx context.Context, cfg *config.Config, l *logging.ZapLogger, reload <-chan *config.Config) {
+	telemetry := service.NewTelemetry()
+	source := service.NewSource(append(slices.Clone(cfg.CollectorList), telemetry)...)
+	source.SetGaugeAggregation(cfg.GaugeAggregation)
+	source.SetTelemetry(telemetry)
+	defer func() {
+		if err := source.Close(); err != nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to close source: %w", err).Error())
+		}
+	}()
+	var wg sync.WaitGroup
 	tickPoll := time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
 	defer tickPoll.Stop()
+	wg.Add(1)
 	go func() {
-		for ; ; <-tickPoll.C {
-			if err := source.Collect(ctx); err != nil {
+		defer wg.Done()
+		for {
+			if err := source.Collect(ctx); err != nil && ctx.Err() == nil {
 				l.ErrorCtx(ctx, fmt.Errorf("failed to collect metrics: %w", err).Error())
 			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tickPoll.C:
+			}
 		}
 	}()
-	sendClient := sender.New(cfg.Sender, l)
-	tickReport := time.NewTicker(time.Duration(cfg.ReportInterval) * time.Second)
-	defer tickReport.Stop()
-	go func() {
-		for range tickReport.C {
-			data, delta := source.Get()
-			if len(data) == 0 {
-				continue
+	// the destinations of the fanout mode or the sender of the failover mode
+	var dests []*destination
+	var sendClient *sender.Sender
+	if cfg.SendMode == sender.ModeFanout {
+		for _, sc := range cfg.Senders {
+			d := newDestination(sc, l)
+			d.sender.SetRecorder(telemetry)
+			if !d.sender.SupportsMeta() {
+				l.WarnCtx(ctx, "the metrics metadata is not sent with the gRPC transport", zap.String("addr", sc.Addr))
+			}
+			dests = append(dests, d)
+		}
+	} else {
+		sendClient = sender.New(cfg.Senders[0], l)
+		sendClient.SetRecorder(telemetry)
+		if !sendClient.SupportsMeta() {
+			l.WarnCtx(ctx, "the metrics metadata is not sent with the gRPC transport")
+		}
+	}
+	defer func() {
+		for _, d := range dests {
+			if err := d.sender.Close(); err != nil {
+				l.ErrorCtx(ctx, fmt.Errorf("failed to close sender: %w", err).Error())
+			}
+		}
+		if sendClient == nil {
+			return
+		}
+		if err := sendClient.Close(); err != nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to close sender: %w", err).Error())
+		}
+	}()
+	var rateLimit atomic.Int64
+	rateLimit.Store(int64(cfg.RateLimit))
+	ob := openOutbox(ctx, cfg, l)
+	drain := make(chan struct{}, 1)
+	if ob != nil {
+		defer func() {
+			if err := ob.Close(); err != nil {
+				l.ErrorCtx(ctx, fmt.Errorf("failed to close outbox: %w", err).Error())
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drainOutbox(ctx, ob, sendClient, drain, l)
+		}()
+		// the batches left by the previous run are replayed once the server is reachable
+		drain <- struct{}{}
+	}
+	// the server which the metadata is delivered to, the metadata is sent again after the failover
+	metaSentTo := -1
+	report := func(ctx context.Context) {
+		data, delta := source.Get()
+		if len(data) == 0 {
+			return
+		}
+		if dests != nil {
+			fanOut(ctx, dests, source.Meta, data, l)
+			// the metrics which are not delivered are kept by the destinations
+			source.Commit(delta)
+			return
+		}
+		if sendClient.SupportsMeta() && metaSentTo != sendClient.Current() {
+			if err := sendClient.SendMeta(ctx, source.Meta()); err == nil {
+				metaSentTo = sendClient.Current()
+			} else {
+				l.ErrorCtx(ctx, fmt.Errorf("failed to send meta: %w", err).Error())
 			}
-			if cfg.Batching {
-				if err := sendClient.SendBatchMetrics(ctx, data); err == nil {
+		}
+		if cfg.Batching {
+			// the batch is queued behind the batches of the outbox until it is drained,
+			// so the gauges reach the server in the order of their samples
+			queued := ob != nil && ob.Pending() > 0
+			var err error
+			if !queued {
+				err = sendClient.SendBatchMetrics(ctx, data)
+			}
+			switch {
+			case err == nil && !queued:
+				source.Commit(delta)
+			case errors.Is(err, sender.ErrPartiallyStored):
+				// the batch is not sent again, so the stored deltas are not applied twice
+				l.ErrorCtx(ctx, fmt.Errorf("failed to send metrics, the partially stored batch is dropped: %w", err).Error())
+				source.Commit(delta)
+			case ob != nil:
+				if err != nil {
+					l.ErrorCtx(ctx, fmt.Errorf("failed to send metrics, the batch is put to the outbox: %w", err).Error())
+				}
+				if err = ob.Append(data); err == nil {
 					source.Commit(delta)
 				} else {
-					l.ErrorCtx(ctx, fmt.Errorf("failed to send metrics: %w", err).Error())
+					l.ErrorCtx(ctx, fmt.Errorf("failed to put batch to the outbox: %w", err).Error())
 				}
-			} else {
-				for result := range sendClient.SendPoolMetrics(ctx, cfg.RateLimit, data) {
-					if result.Err != nil {
-						l.ErrorCtx(ctx, fmt.Errorf("failed to send metric: %w", result.Err).Error())
-					} else if result.Metric != nil && result.Metric.MType == model.TypeCounter && result.Metric.ID == "PollCount" {
-						source.Commit(delta)
-					}
+				select {
+				case drain <- struct{}{}:
+				default:
+				}
+			default:
+				l.ErrorCtx(ctx, fmt.Errorf("failed to send metrics: %w", err).Error())
+			}
+		} else {
+			// the metrics are committed separately, the ones which failed to send are reported again
+			sent := make([]*model.Metric, 0, len(data))
+			for result := range sendClient.SendPoolMetrics(ctx, int(rateLimit.Load()), data) {
+				if result.Err != nil {
+					l.ErrorCtx(ctx, fmt.Errorf("failed to send metric: %w", result.Err).Error())
+				} else {
+					sent = append(sent, result.Metric)
 				}
 			}
+			source.CommitSent(sent)
+		}
+	}
+	tickReport := time.NewTicker(time.Duration(cfg.ReportInterval) * time.Second)
+	defer tickReport.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tickReport.C:
+				report(ctx)
+			}
 		}
 	}()
-	<-ctx.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			// the context of the agent is done, the last report is bounded by the shutdown timeout
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cfg.ShutdownTimeout)
+			l.InfoCtx(flushCtx, "sending the last metrics before shutdown")
+			report(flushCtx)
+			cancel()
+			return
+		case newCfg := <-reload:
+			tickPoll.Reset(time.Duration(newCfg.PollInterval) * time.Second)
+			tickReport.Reset(time.Duration(newCfg.ReportInterval) * time.Second)
+			rateLimit.Store(int64(newCfg.RateLimit))
+			if sendClient != nil {
+				sendClient.SetKey([]byte(newCfg.Key))
+			}
+			for _, d := range dests {
+				d.sender.SetKey([]byte(newCfg.Key))
+			}
+		}
+	}
+}
+
+// openOutbox opens the configured outbox, nil is returned if the outbox is disabled or can not be opened
+func openOutbox(ctx context.Context, cfg *config.Config, l *logging.ZapLogger) *outbox.Outbox {
+	if cfg.Outbox == nil {
+		return nil
+	}
+	if !cfg.Batching {
+		l.WarnCtx(ctx, "the outbox is used only with batching")
+		return nil
+	}
+	if cfg.SendMode == sender.ModeFanout {
+		l.WarnCtx(ctx, "the outbox is not used in the fanout mode, the destinations keep the metrics which failed to send")
+		return nil
+	}
+	ob, err := outbox.New(cfg.Outbox)
+	if err != nil {
+		l.ErrorCtx(ctx, fmt.Errorf("failed to open outbox: %w", err).Error())
+		return nil
+	}
+	return ob
+}
+
+// drainOutbox replays the batches of the outbox on every signal until the context is done,
+// the batches rejected by the server are dropped
+func drainOutbox(ctx context.Context, ob *outbox.Outbox, s *sender.Sender, drain <-chan struct{}, l *logging.ZapLogger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-drain:
+		}
+		n, rejected, err := ob.Drain(ctx, func(ctx context.Context, ms []*model.Metric) error {
+			err := s.SendBatchMetrics(ctx, ms)
+			if errors.Is(err, sender.ErrRejected) {
+				return fmt.Errorf("%w: %w", outbox.ErrRejected, err)
+			}
+			return err
+		})
+		if n > 0 {
+			l.InfoCtx(ctx, "replayed batches from the outbox", zap.Int("batches", n))
+		}
+		if rejected > 0 {
+			l.ErrorCtx(ctx, "dropped the batches rejected by the server from the outbox", zap.Int("batches", rejected))
+		}
+		if err != nil && ctx.Err() == nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to replay outbox: %w", err).Error())
+		}
+	}
 }
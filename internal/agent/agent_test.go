@@ -0,0 +1,348 @@
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/config"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/outbox"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/service"
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// stubCollector is the collector returning a gauge and signaling every collection
+type stubCollector struct {
+	collected chan struct{}
+}
+
+func (c *stubCollector) Name() string {
+	return "stub"
+}
+
+func (c *stubCollector) Interval() time.Duration {
+	return 0
+}
+
+func (c *stubCollector) Collect(context.Context) ([]*model.Metric, error) {
+	select {
+	case c.collected <- struct{}{}:
+	default:
+	}
+	return []*model.Metric{model.NewMetricGauge("g", 1)}, nil
+}
+
+var _ service.Collector = (*stubCollector)(nil)
+
+func TestRun_FlushOnShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]*model.Metric
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []*model.Metric
+		if assert.NoError(t, json.NewDecoder(gz).Decode(&batch)) {
+			mu.Lock()
+			batches = append(batches, batch)
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l, err := logging.NewZapLogger(zapcore.ErrorLevel)
+	require.NoError(t, err)
+	collector := &stubCollector{collected: make(chan struct{}, 1)}
+	cfg := &config.Config{
+		Senders: []*sender.Config{{
+			UpdatesURL: srv.URL + "/updates/",
+			MetaURL:    srv.URL + "/meta/",
+			Timeout:    time.Second,
+		}},
+		CollectorList:   []service.Collector{collector},
+		PollInterval:    3600,
+		ReportInterval:  3600,
+		RateLimit:       1,
+		Batching:        true,
+		ShutdownTimeout: time.Second,
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, cfg, l, nil)
+	}()
+	<-collector.collected
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run did not return after the context was done")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, batches, 1, "the metrics collected before the shutdown are sent")
+	got := make(map[string]string)
+	telemetry := make(map[string]string)
+	for _, m := range batches[0] {
+		if strings.HasPrefix(m.ID, service.TelemetryPrefix) {
+			telemetry[m.MType+":"+m.Key()] = fmt.Sprint(m.AnyValue())
+			continue
+		}
+		got[m.MType+":"+m.ID] = fmt.Sprint(m.AnyValue())
+	}
+	assert.Equal(t, map[string]string{"gauge:g": "1", "counter:PollCount": "1"}, got)
+	assert.Equal(t, "1", telemetry["gauge:agent_poll_backlog"], "the telemetry is sent with the metrics")
+}
+
+func TestRun_RejectedBatchIsNotCommitted(t *testing.T) {
+	var mu sync.Mutex
+	var pollCounts []int64
+	resent := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []*model.Metric
+		if !assert.NoError(t, json.NewDecoder(gz).Decode(&batch)) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range batch {
+			if m.ID == "PollCount" {
+				pollCounts = append(pollCounts, *m.Delta)
+			}
+		}
+		switch len(pollCounts) {
+		case 1:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case 2:
+			close(resent)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	cfg := &config.Config{
+		Senders: []*sender.Config{{
+			UpdatesURL: srv.URL + "/updates/",
+			MetaURL:    srv.URL + "/meta/",
+			Timeout:    time.Second,
+		}},
+		CollectorList:   []service.Collector{&stubCollector{}},
+		PollInterval:    3600,
+		ReportInterval:  1,
+		RateLimit:       1,
+		Batching:        true,
+		ShutdownTimeout: time.Second,
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, cfg, l, nil)
+	}()
+	select {
+	case <-resent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the metrics were not sent again")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{1, 1}, pollCounts[:2], "the rejected PollCount is sent again by the next report")
+}
+
+func TestRun_PartiallyStoredBatchIsCommitted(t *testing.T) {
+	var mu sync.Mutex
+	var pollCounts []int64
+	resent := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []*model.Metric
+		if !assert.NoError(t, json.NewDecoder(gz).Decode(&batch)) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range batch {
+			if m.ID == "PollCount" {
+				pollCounts = append(pollCounts, *m.Delta)
+			}
+		}
+		switch len(pollCounts) {
+		case 1:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		case 2:
+			close(resent)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	cfg := &config.Config{
+		Senders: []*sender.Config{{
+			UpdatesURL: srv.URL + "/updates/",
+			MetaURL:    srv.URL + "/meta/",
+			Timeout:    time.Second,
+		}},
+		CollectorList:   []service.Collector{&stubCollector{}},
+		PollInterval:    3600,
+		ReportInterval:  1,
+		RateLimit:       1,
+		Batching:        true,
+		ShutdownTimeout: time.Second,
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, cfg, l, nil)
+	}()
+	select {
+	case <-resent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the next report was not sent")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{1, 0}, pollCounts[:2], "the partially stored PollCount is not sent again")
+}
+
+// seqCollector is the collector returning the gauge incremented on every collection
+type seqCollector struct {
+	n atomic.Int64
+}
+
+func (c *seqCollector) Name() string {
+	return "seq"
+}
+
+func (c *seqCollector) Interval() time.Duration {
+	return 0
+}
+
+func (c *seqCollector) Collect(context.Context) ([]*model.Metric, error) {
+	return []*model.Metric{model.NewMetricGauge("seq", float64(c.n.Add(1)))}, nil
+}
+
+func TestRun_OutboxReplaysGauges(t *testing.T) {
+	var mu sync.Mutex
+	var failed, stored []float64
+	enough := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []*model.Metric
+		if !assert.NoError(t, json.NewDecoder(gz).Decode(&batch)) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var values []float64
+		for _, m := range batch {
+			if m.ID == "seq" && m.MType == model.TypeGauge {
+				assert.NotZero(t, m.Timestamp, "the gauge is sent with its sample timestamp")
+				values = append(values, *m.Value)
+			}
+		}
+		// the server is down for the first report
+		if failed == nil {
+			failed = values
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		stored = append(stored, values...)
+		if len(stored) == 3 {
+			close(enough)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	cfg := &config.Config{
+		Senders: []*sender.Config{{
+			UpdatesURL: srv.URL + "/updates/",
+			MetaURL:    srv.URL + "/meta/",
+			Timeout:    time.Second,
+		}},
+		Outbox:          &outbox.Config{Dir: t.TempDir(), MaxSize: 1 << 20},
+		CollectorList:   []service.Collector{&seqCollector{}},
+		PollInterval:    1,
+		ReportInterval:  1,
+		RateLimit:       1,
+		Batching:        true,
+		ShutdownTimeout: time.Second,
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, cfg, l, nil)
+	}()
+	select {
+	case <-enough:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the metrics were not replayed")
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, failed, 1)
+	assert.Equal(t, failed[0], stored[0], "the gauge of the outage is replayed first")
+	assert.IsIncreasing(t, stored[:3], "the live gauges are sent after the replayed ones")
+}
@@ -4,75 +4,322 @@ package config
 import (
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/caarlos0/env/v6"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/outbox"
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/service"
+	"github.com/korobkovandrey/runtime-metrics/pkg/confload"
+	"go.uber.org/zap/zapcore"
 )
 
 // Config is the agent config.
 type Config struct {
-	Sender         *sender.Config
-	Addr           string `env:"ADDRESS"`
-	Key            string `env:"KEY"`
-	PprofAddr      string `env:"PPROF_ADDRESS"`
-	PollInterval   int    `env:"POLL_INTERVAL"`
-	ReportInterval int    `env:"REPORT_INTERVAL"`
-	RateLimit      int    `env:"RATE_LIMIT"`
-	Batching       bool   `env:"BATCHING"`
+	Outbox           *outbox.Config           `json:"-"`
+	Key              string                   `env:"KEY" json:"key"`
+	PprofAddr        string                   `env:"PPROF_ADDRESS" json:"pprof_address"`
+	StatsdAddr       string                   `env:"STATSD_ADDRESS" json:"statsd_address"`
+	OutboxDir        string                   `env:"OUTBOX_DIR" json:"outbox_dir"`
+	ProcessName      string                   `env:"PROCESS_NAME" json:"process_name"`
+	ProcessCmdline   string                   `env:"PROCESS_CMDLINE" json:"process_cmdline"`
+	LogLevel         string                   `env:"LOG_LEVEL" json:"log_level"`
+	GaugeAggregation service.GaugeAggregation `env:"GAUGE_AGGREGATION" json:"gauge_aggregation"`
+	SendMode         sender.Mode              `env:"SEND_MODE" json:"send_mode"`
+	Transport        sender.Transport         `env:"TRANSPORT" json:"transport"`
+	Senders          []*sender.Config         `json:"-"`
+	CollectorList    []service.Collector      `json:"-"`
+	Addrs            []string                 `env:"ADDRESS" envSeparator:"," json:"address"`
+	Collectors       []string                 `env:"COLLECTORS" envSeparator:"," json:"collectors"`
+	DiskInclude      []string                 `env:"DISK_INCLUDE" envSeparator:"," json:"disk_include"`
+	DiskExclude      []string                 `env:"DISK_EXCLUDE" envSeparator:"," json:"disk_exclude"`
+	NetInclude       []string                 `env:"NET_INCLUDE" envSeparator:"," json:"net_include"`
+	NetExclude       []string                 `env:"NET_EXCLUDE" envSeparator:"," json:"net_exclude"`
+	ProcessPidFiles  []string                 `env:"PROCESS_PIDFILES" envSeparator:"," json:"process_pidfiles"`
+	ExecCommands     []string                 `env:"EXEC_COMMANDS" envSeparator:";" json:"exec_commands"`
+	SendTimeout      time.Duration            `env:"SEND_TIMEOUT" json:"send_timeout"`
+	ShutdownTimeout  time.Duration            `env:"SHUTDOWN_TIMEOUT" json:"shutdown_timeout"`
+	ExecTimeout      time.Duration            `env:"EXEC_TIMEOUT" json:"exec_timeout"`
+	RetryBaseDelay   time.Duration            `env:"RETRY_BASE_DELAY" json:"retry_base_delay"`
+	RetryMaxDelay    time.Duration            `env:"RETRY_MAX_DELAY" json:"retry_max_delay"`
+	BreakerCoolDown  time.Duration            `env:"BREAKER_COOLDOWN" json:"breaker_cooldown"`
+	OutboxMaxAge     time.Duration            `env:"OUTBOX_MAX_AGE" json:"outbox_max_age"`
+	OutboxMaxSize    int64                    `env:"OUTBOX_MAX_SIZE" json:"outbox_max_size"`
+	OutboxRate       int                      `env:"OUTBOX_REPLAY_RATE" json:"outbox_replay_rate"`
+	PollInterval     int                      `env:"POLL_INTERVAL" json:"poll_interval"`
+	ReportInterval   int                      `env:"REPORT_INTERVAL" json:"report_interval"`
+	RateLimit        int                      `env:"RATE_LIMIT" json:"rate_limit"`
+	RetryMax         int                      `env:"RETRY_MAX" json:"retry_max"`
+	BreakerThreshold int                      `env:"BREAKER_THRESHOLD" json:"breaker_threshold"`
+	Batching         bool                     `env:"BATCHING" json:"batching"`
+	RuntimeMemStats  bool                     `env:"RUNTIME_MEMSTATS" json:"runtime_memstats"`
+	Protobuf         bool                     `env:"PROTOBUF" json:"protobuf"`
 }
 
+// reloadableFields are the fields applied by the running agent on reload.
+var reloadableFields = []string{"PollInterval", "ReportInterval", "RateLimit", "Key", "LogLevel"}
+
 // NewConfig returns the agent config.
+//
+// The config is loaded from the flags, the env and the config file set by -c, -config or CONFIG,
+// see confload for the precedence.
 func NewConfig() (*Config, error) {
+	cfg, src, err := newConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.CollectorList, err = service.NewCollectors(cfg.Collectors, 0, &service.CollectorsConfig{
+		Runtime: service.RuntimeConfig{MemStats: cfg.RuntimeMemStats},
+		Disk:    service.DiskConfig{Include: cfg.DiskInclude, Exclude: cfg.DiskExclude},
+		Net:     service.NetConfig{Include: cfg.NetInclude, Exclude: cfg.NetExclude},
+		Exec:    service.ExecConfig{Commands: cfg.ExecCommands, Timeout: cfg.ExecTimeout},
+		Statsd:  service.StatsdConfig{Addr: cfg.StatsdAddr},
+		Process: service.ProcessConfig{
+			Name:     cfg.ProcessName,
+			Cmdline:  cfg.ProcessCmdline,
+			PidFiles: cfg.ProcessPidFiles,
+		},
+	}); err != nil {
+		return cfg, fmt.Errorf("failed to create collectors (%s): %w",
+			src.Describe("Collectors", "DiskInclude", "DiskExclude", "NetInclude", "NetExclude",
+				"ProcessName", "ProcessCmdline", "ProcessPidFiles", "ExecCommands", "ExecTimeout", "StatsdAddr"), err)
+	}
+	return cfg, nil
+}
+
+// Reload reads the agent config again from the command line, the env and the config file,
+// the collectors are not created.
+func Reload() (*Config, error) {
+	cfg, _, err := newConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+	return cfg, err
+}
+
+// Apply copies the reloadable fields of the reloaded cfg to the running config and returns
+// the names of the applied fields and the changed fields which need a restart.
+func Apply(running, cfg *Config) (applied, restart []string) {
+	return confload.Apply(running, cfg, reloadableFields...)
+}
+
+// newConfig parses the args with the flag set and loads the config without the collectors.
+func newConfig(fs *flag.FlagSet, args []string) (*Config, confload.Sources, error) {
 	const (
 		pollIntervalSeconds   = 2
 		reportIntervalSeconds = 10
+		execTimeout           = 5 * time.Second
+		outboxMaxSize         = 64 << 20
+		outboxMaxAge          = 24 * time.Hour
+		outboxReplayRate      = 10
+		sendTimeout           = reportIntervalSeconds * time.Second
+		shutdownTimeout       = 5 * time.Second
+		retryMax              = 3
+		retryBaseDelay        = time.Second
+		retryMaxDelay         = 5 * time.Second
+		breakerThreshold      = 5
+		breakerCoolDown       = 30 * time.Second
 	)
 	cfg := &Config{}
-	flag.StringVar(&cfg.Addr, "a", "localhost:8080", "server host")
-	flag.IntVar(&cfg.PollInterval, "p", pollIntervalSeconds, "pollInterval in seconds")
-	flag.IntVar(&cfg.ReportInterval, "r", reportIntervalSeconds, "reportInterval in seconds")
-	flag.StringVar(&cfg.Key, "k", "", "key")
-	flag.IntVar(&cfg.RateLimit, "l", runtime.NumCPU(), "rate limit")
-	flag.BoolVar(&cfg.Batching, "b", true, "batching")
-	flag.StringVar(&cfg.PprofAddr, "pprof", "", "pprof address")
+	var configPath string
+	fs.StringVar(&configPath, "c", "", "config file, JSON or YAML if the extension is .yaml or .yml")
+	fs.StringVar(&configPath, "config", "", "config file, JSON or YAML if the extension is .yaml or .yml")
+	cfg.Addrs = []string{"localhost:8080"}
+	fs.Var(confload.List(&cfg.Addrs, ","), "a", "comma separated server hosts, see -send-mode")
+	fs.StringVar((*string)(&cfg.SendMode), "send-mode", string(sender.ModeFailover),
+		"mode of sending to several servers: failover (the next server is tried when the server fails) or fanout (all servers)")
+	fs.StringVar((*string)(&cfg.Transport), "transport", string(sender.TransportHTTP),
+		"transport of sending the metrics: http or grpc, the server hosts are the gRPC hosts with grpc")
+	fs.IntVar(&cfg.PollInterval, "p", pollIntervalSeconds, "pollInterval in seconds")
+	fs.IntVar(&cfg.ReportInterval, "r", reportIntervalSeconds, "reportInterval in seconds")
+	fs.StringVar(&cfg.Key, "k", "", "key")
+	fs.IntVar(&cfg.RateLimit, "l", runtime.NumCPU(), "rate limit")
+	fs.BoolVar(&cfg.Batching, "b", true, "batching")
+	fs.BoolVar(&cfg.Protobuf, "proto", false, "send metrics encoded with protobuf")
+	fs.StringVar(&cfg.PprofAddr, "pprof", "", "pprof address")
+	fs.DurationVar(&cfg.SendTimeout, "send-timeout", sendTimeout, "timeout of sending the metrics")
+	fs.IntVar(&cfg.RetryMax, "retry-max", retryMax, "maximum number of the retries of a failed request, 0 - no retries")
+	fs.DurationVar(&cfg.RetryBaseDelay, "retry-base-delay", retryBaseDelay,
+		"maximum delay before the first retry, the delay is doubled on every retry and is random below the maximum")
+	fs.DurationVar(&cfg.RetryMaxDelay, "retry-max-delay", retryMaxDelay,
+		"maximum delay before a retry, the longer Retry-After of the server is not awaited")
+	fs.IntVar(&cfg.BreakerThreshold, "breaker-threshold", breakerThreshold,
+		"number of the consecutive failed sends opening the circuit breaker, 0 - no breaker")
+	fs.DurationVar(&cfg.BreakerCoolDown, "breaker-cooldown", breakerCoolDown,
+		"time the circuit breaker fails the sends fast before the probe request")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", shutdownTimeout,
+		"timeout of sending the last metrics on shutdown")
+	fs.StringVar(&cfg.OutboxDir, "outbox", "",
+		"directory of the outbox of the batches which failed to send, the outbox is disabled if empty")
+	fs.Int64Var(&cfg.OutboxMaxSize, "outbox-max-size", outboxMaxSize, "maximum size of the outbox in bytes")
+	fs.DurationVar(&cfg.OutboxMaxAge, "outbox-max-age", outboxMaxAge, "maximum age of the replayed batch, 0 - no limit")
+	fs.IntVar(&cfg.OutboxRate, "outbox-rate", outboxReplayRate, "maximum number of the batches replayed per second, 0 - no limit")
+	fs.StringVar(&cfg.StatsdAddr, "statsd", "", "UDP address of the StatsD listener, e.g. :8125, the listener is disabled if empty")
+	cfg.Collectors = slices.Clone(service.DefaultCollectors)
+	fs.Var(confload.List(&cfg.Collectors, ","), "collectors",
+		"comma separated collectors, name or name:interval, e.g. runtime,gopsutil:10s,net:30s")
+	fs.BoolVar(&cfg.RuntimeMemStats, "memstats", false,
+		"report the legacy runtime.MemStats metrics and RandomValue by the runtime collector")
+	fs.Var(confload.List(&cfg.DiskInclude, ","), "disk-include", "comma separated mount point patterns of the reported filesystems")
+	fs.Var(confload.List(&cfg.DiskExclude, ","), "disk-exclude",
+		"comma separated mount point patterns of the filesystems which are not reported")
+	fs.Var(confload.List(&cfg.NetInclude, ","), "net-include", "comma separated patterns of the reported network interfaces")
+	fs.Var(confload.List(&cfg.NetExclude, ","), "net-exclude", "comma separated patterns of the network interfaces which are not reported")
+	fs.StringVar(&cfg.ProcessName, "process-name", "", "regular expression of the names of the processes reported by the process collector")
+	fs.StringVar(&cfg.ProcessCmdline, "process-cmdline", "",
+		"regular expression of the command lines of the processes reported by the process collector")
+	fs.Var(confload.List(&cfg.ProcessPidFiles, ","), "process-pidfiles",
+		"comma separated pid files of the processes reported by the process collector")
+	fs.Var(confload.List(&cfg.ExecCommands, ";"), "exec",
+		"semicolon separated commands run by the exec collector, e.g. \"/opt/app/stats.sh -v;uptime-metrics\"")
+	fs.DurationVar(&cfg.ExecTimeout, "exec-timeout", execTimeout, "maximum run time of a command of the exec collector")
+	fs.StringVar((*string)(&cfg.GaugeAggregation), "gauge-aggregation", string(service.GaugeAggregationNone),
+		"aggregation of the gauges polled over the report window: none, derived (ID.min, ID.max, ID.avg, ID.count) "+
+			"or summary (ID.p50, ID.p90, ID.p99, ID.count)")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "log level: debug, info, warn or error")
 
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, fmt.Errorf("failed to parse flags: %w", err)
+	}
 
-	err := env.Parse(cfg)
+	src, err := confload.Load(fs, cfg, configPath)
 	if err != nil {
-		return cfg, fmt.Errorf("failed to parse config: %w", err)
+		return cfg, nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	if cfg.ReportInterval < 1 {
-		return cfg, fmt.Errorf("ReportInterval (%ds) must be greater 0",
-			cfg.ReportInterval)
+		return cfg, nil, fmt.Errorf("ReportInterval (%ds, %s) must be greater 0",
+			cfg.ReportInterval, src.Of("ReportInterval"))
 	}
 
 	if cfg.PollInterval < 1 {
-		return cfg, fmt.Errorf("ReportInterval (%ds) must be greater 0",
-			cfg.ReportInterval)
+		return cfg, nil, fmt.Errorf("PollInterval (%ds, %s) must be greater 0",
+			cfg.PollInterval, src.Of("PollInterval"))
 	}
 
 	if cfg.ReportInterval <= cfg.PollInterval {
-		return cfg, fmt.Errorf("ReportInterval (%ds) must be greater than PollInterval (%ds)",
-			cfg.ReportInterval, cfg.PollInterval)
+		return cfg, nil, fmt.Errorf("ReportInterval (%ds, %s) must be greater than PollInterval (%ds, %s)",
+			cfg.ReportInterval, src.Of("ReportInterval"), cfg.PollInterval, src.Of("PollInterval"))
 	}
 
 	if cfg.RateLimit < 1 {
-		return cfg, fmt.Errorf("RateLimit (%d) must be greater 0",
-			cfg.RateLimit)
+		return cfg, nil, fmt.Errorf("RateLimit (%d, %s) must be greater 0",
+			cfg.RateLimit, src.Of("RateLimit"))
 	}
 
-	baseURL := "http://" + cfg.Addr
-	cfg.Sender = &sender.Config{
-		UpdateURL:   baseURL + "/update/",
-		UpdatesURL:  baseURL + "/updates/",
-		RetryDelays: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second},
-		Timeout:     reportIntervalSeconds * time.Second,
-		Key:         []byte(cfg.Key),
-		RateLimit:   cfg.RateLimit,
+	if cfg.SendTimeout <= 0 {
+		return cfg, nil, fmt.Errorf("SendTimeout (%s, %s) must be greater 0",
+			cfg.SendTimeout, src.Of("SendTimeout"))
 	}
-	return cfg, nil
+
+	if cfg.ShutdownTimeout <= 0 {
+		return cfg, nil, fmt.Errorf("ShutdownTimeout (%s, %s) must be greater 0",
+			cfg.ShutdownTimeout, src.Of("ShutdownTimeout"))
+	}
+
+	if cfg.RetryMax < 0 {
+		return cfg, nil, fmt.Errorf("RetryMax (%d, %s) must not be negative", cfg.RetryMax, src.Of("RetryMax"))
+	}
+
+	if cfg.RetryBaseDelay <= 0 || cfg.RetryMaxDelay < cfg.RetryBaseDelay {
+		return cfg, nil, fmt.Errorf("RetryBaseDelay (%s, %s) must be greater 0 and not greater than RetryMaxDelay (%s, %s)",
+			cfg.RetryBaseDelay, src.Of("RetryBaseDelay"), cfg.RetryMaxDelay, src.Of("RetryMaxDelay"))
+	}
+
+	if _, err = zapcore.ParseLevel(cfg.LogLevel); err != nil {
+		return cfg, nil, fmt.Errorf("LogLevel (%s): %w", src.Of("LogLevel"), err)
+	}
+
+	if cfg.BreakerThreshold < 0 || cfg.BreakerCoolDown < 0 {
+		return cfg, nil, fmt.Errorf("%s must not be negative", src.Describe("BreakerThreshold", "BreakerCoolDown"))
+	}
+
+	if len(cfg.Addrs) == 0 || slices.Contains(cfg.Addrs, "") {
+		return cfg, nil, fmt.Errorf("Addrs (%q, %s) must not be empty", cfg.Addrs, src.Of("Addrs"))
+	}
+
+	if cfg.SendMode, err = sender.ParseMode(string(cfg.SendMode)); err != nil {
+		return cfg, nil, fmt.Errorf("SendMode (%s): %w", src.Of("SendMode"), err)
+	}
+
+	if cfg.Transport, err = sender.ParseTransport(string(cfg.Transport)); err != nil {
+		return cfg, nil, fmt.Errorf("Transport (%s): %w", src.Of("Transport"), err)
+	}
+
+	if cfg.SendMode == sender.ModeFanout && !cfg.Batching {
+		return cfg, nil, fmt.Errorf("SendMode fanout requires batching (%s)", src.Describe("SendMode", "Batching"))
+	}
+
+	if cfg.GaugeAggregation, err = service.ParseGaugeAggregation(string(cfg.GaugeAggregation)); err != nil {
+		return cfg, nil, fmt.Errorf("GaugeAggregation (%s): %w", src.Of("GaugeAggregation"), err)
+	}
+
+	if cfg.StatsdAddr != "" && !slices.ContainsFunc(cfg.Collectors, isStatsdSpec) {
+		cfg.Collectors = append(cfg.Collectors, service.StatsdCollectorName)
+	}
+	if cfg.OutboxDir != "" {
+		cfg.Outbox = &outbox.Config{
+			Dir:        cfg.OutboxDir,
+			MaxSize:    cfg.OutboxMaxSize,
+			MaxAge:     cfg.OutboxMaxAge,
+			ReplayRate: cfg.OutboxRate,
+		}
+		if err = cfg.Outbox.Validate(); err != nil {
+			return cfg, nil, fmt.Errorf("invalid outbox config (%s): %w",
+				src.Describe("OutboxDir", "OutboxMaxSize", "OutboxMaxAge", "OutboxRate"), err)
+		}
+	}
+
+	cfg.Senders = newSenderConfigs(cfg)
+	return cfg, src, nil
+}
+
+// newSenderConfigs returns the configs of the senders: one config of the first server with the others
+// as the fallbacks in the failover mode, and a config of every server in the fanout mode.
+func newSenderConfigs(cfg *Config) []*sender.Config {
+	endpoints := make([]sender.Endpoint, len(cfg.Addrs))
+	for i, addr := range cfg.Addrs {
+		endpoints[i] = sender.NewEndpoint(strings.TrimSpace(addr))
+	}
+	newSenderConfig := func(e sender.Endpoint, fallbacks []sender.Endpoint) *sender.Config {
+		return &sender.Config{
+			UpdateURL:  e.UpdateURL,
+			UpdatesURL: e.UpdatesURL,
+			MetaURL:    e.MetaURL,
+			Addr:       e.Addr,
+			Transport:  cfg.Transport,
+			Fallbacks:  fallbacks,
+			Key:        []byte(cfg.Key),
+			Timeout:    cfg.SendTimeout,
+			RateLimit:  cfg.RateLimit,
+			Protobuf:   cfg.Protobuf,
+			Retry: sender.RetryPolicy{
+				MaxRetries: cfg.RetryMax,
+				BaseDelay:  cfg.RetryBaseDelay,
+				MaxDelay:   cfg.RetryMaxDelay,
+			},
+			Breaker: sender.BreakerConfig{
+				FailureThreshold: cfg.BreakerThreshold,
+				CoolDown:         cfg.BreakerCoolDown,
+			},
+		}
+	}
+	if cfg.SendMode != sender.ModeFanout {
+		var fallbacks []sender.Endpoint
+		if len(endpoints) > 1 {
+			fallbacks = endpoints[1:]
+		}
+		return []*sender.Config{newSenderConfig(endpoints[0], fallbacks)}
+	}
+	configs := make([]*sender.Config, len(endpoints))
+	for i, e := range endpoints {
+		configs[i] = newSenderConfig(e, nil)
+	}
+	return configs
+}
+
+// isStatsdSpec returns true if the collector spec enables the StatsD listener
+func isStatsdSpec(spec string) bool {
+	name, _, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	return name == service.StatsdCollectorName
 }
@@ -1,37 +1,144 @@
 package config
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/outbox"
 	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewConfig(t *testing.T) {
-	t.Setenv("ADDRESS", "test.host:1234")
+	t.Setenv("ADDRESS", "test.host:1234,backup.host:1234")
 	t.Setenv("POLL_INTERVAL", "3")
 	t.Setenv("REPORT_INTERVAL", "11")
 	t.Setenv("KEY", "test_KEY")
 	t.Setenv("RATE_LIMIT", "15")
 	t.Setenv("BATCHING", "true")
 	t.Setenv("PPROF_ADDRESS", ":6066")
+	t.Setenv("COLLECTORS", "gopsutil:10s,runtime,exec:1m")
+	t.Setenv("DISK_EXCLUDE", "/snap/*,/boot")
+	t.Setenv("NET_INCLUDE", "eth*")
+	t.Setenv("PROCESS_NAME", "^nginx$")
+	t.Setenv("RUNTIME_MEMSTATS", "true")
+	t.Setenv("EXEC_COMMANDS", "/opt/app/stats.sh -v;uptime-metrics")
+	t.Setenv("EXEC_TIMEOUT", "3s")
+	t.Setenv("STATSD_ADDRESS", "127.0.0.1:0")
+	t.Setenv("OUTBOX_DIR", "/var/lib/agent/outbox")
+	t.Setenv("OUTBOX_MAX_AGE", "1h")
+	t.Setenv("GAUGE_AGGREGATION", "derived")
+	path := filepath.Join(t.TempDir(), "agent.json")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"poll_interval": 1, "retry_max": 2, "retry_max_delay": "4s", "send_timeout": "4s", "net_exclude": ["lo", "docker*"]}`), 0o600))
+	t.Setenv("CONFIG", path)
 	cfg, err := NewConfig()
 	require.NoError(t, err)
-	assert.Equal(t, "test.host:1234", cfg.Addr)
+	assert.Equal(t, []string{"test.host:1234", "backup.host:1234"}, cfg.Addrs)
+	assert.Equal(t, sender.ModeFailover, cfg.SendMode)
 	assert.Equal(t, 3, cfg.PollInterval)
 	assert.Equal(t, 11, cfg.ReportInterval)
 	assert.Equal(t, "test_KEY", cfg.Key)
 	assert.Equal(t, 15, cfg.RateLimit)
 	assert.True(t, cfg.Batching)
 	assert.Equal(t, ":6066", cfg.PprofAddr)
+	assert.Equal(t, []string{"gopsutil:10s", "runtime", "exec:1m", "statsd"}, cfg.Collectors)
+	assert.Equal(t, []string{"/snap/*", "/boot"}, cfg.DiskExclude)
+	assert.Empty(t, cfg.DiskInclude)
+	assert.Equal(t, []string{"eth*"}, cfg.NetInclude)
+	assert.Equal(t, []string{"lo", "docker*"}, cfg.NetExclude)
+	assert.Equal(t, "^nginx$", cfg.ProcessName)
+	assert.True(t, cfg.RuntimeMemStats)
+	assert.Equal(t, []string{"/opt/app/stats.sh -v", "uptime-metrics"}, cfg.ExecCommands)
+	assert.Equal(t, 3*time.Second, cfg.ExecTimeout)
+	assert.Equal(t, "127.0.0.1:0", cfg.StatsdAddr)
+	assert.Equal(t, service.GaugeAggregationDerived, cfg.GaugeAggregation)
+	assert.Equal(t, &outbox.Config{Dir: "/var/lib/agent/outbox", MaxSize: 64 << 20, MaxAge: time.Hour, ReplayRate: 10}, cfg.Outbox)
+	require.Len(t, cfg.CollectorList, 4)
+	assert.Equal(t, 10*time.Second, cfg.CollectorList[0].Interval())
+	assert.Equal(t, time.Minute, cfg.CollectorList[2].Interval())
+	statsd, ok := cfg.CollectorList[3].(io.Closer)
+	require.True(t, ok, "the statsd listener is enabled by the address")
+	require.NoError(t, statsd.Close())
+	require.Len(t, cfg.Senders, 1, "the second server is the fallback")
 	assert.Equal(t, sender.Config{
-		UpdateURL:   "http://" + cfg.Addr + "/update/",
-		UpdatesURL:  "http://" + cfg.Addr + "/updates/",
-		RetryDelays: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second},
-		Timeout:     10 * time.Second,
-		Key:         []byte(cfg.Key),
-		RateLimit:   cfg.RateLimit,
-	}, *cfg.Sender)
+		UpdateURL:  "http://test.host:1234/update/",
+		UpdatesURL: "http://test.host:1234/updates/",
+		MetaURL:    "http://test.host:1234/meta/",
+		Addr:       "test.host:1234",
+		Transport:  sender.TransportHTTP,
+		Fallbacks:  []sender.Endpoint{sender.NewEndpoint("backup.host:1234")},
+		Key:        []byte(cfg.Key),
+		Retry:      sender.RetryPolicy{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+		Breaker:    sender.BreakerConfig{FailureThreshold: 5, CoolDown: 30 * time.Second},
+		Timeout:    4 * time.Second,
+		RateLimit:  cfg.RateLimit,
+	}, *cfg.Senders[0])
+}
+
+func TestReload_SendMode(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"agent", "-a", "a:1,b:2", "-send-mode", "fanout"}
+	t.Cleanup(func() { os.Args = args })
+	cfg, err := Reload()
+	require.NoError(t, err)
+	require.Len(t, cfg.Senders, 2)
+	assert.Equal(t, "http://a:1/updates/", cfg.Senders[0].UpdatesURL)
+	assert.Equal(t, "http://b:2/updates/", cfg.Senders[1].UpdatesURL)
+	assert.Empty(t, cfg.Senders[0].Fallbacks)
+
+	t.Setenv("BATCHING", "false")
+	_, err = Reload()
+	require.ErrorContains(t, err, "SendMode fanout requires batching (SendMode from flag -send-mode, Batching from env BATCHING)")
+
+	os.Args = []string{"agent"}
+	t.Setenv("SEND_MODE", "broadcast")
+	_, err = Reload()
+	require.ErrorContains(t, err, `SendMode (env SEND_MODE): unknown send mode "broadcast"`)
+}
+
+func TestReload_Transport(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"agent", "-a", "a:3200", "-transport", "grpc"}
+	t.Cleanup(func() { os.Args = args })
+	cfg, err := Reload()
+	require.NoError(t, err)
+	assert.Equal(t, sender.TransportGRPC, cfg.Transport)
+	require.Len(t, cfg.Senders, 1)
+	assert.Equal(t, sender.TransportGRPC, cfg.Senders[0].Transport)
+	assert.Equal(t, "a:3200", cfg.Senders[0].Addr)
+
+	os.Args = []string{"agent"}
+	t.Setenv("TRANSPORT", "udp")
+	_, err = Reload()
+	require.ErrorContains(t, err, `Transport (env TRANSPORT): unknown transport "udp"`)
+}
+
+func TestReload(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"agent", "-p", "4"}
+	t.Cleanup(func() { os.Args = args })
+	running, err := Reload()
+	require.NoError(t, err)
+	assert.Empty(t, running.CollectorList, "the collectors are not created on reload")
+
+	t.Setenv("REPORT_INTERVAL", "20")
+	t.Setenv("KEY", "new key")
+	t.Setenv("COLLECTORS", "runtime")
+	cfg, err := Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 4, cfg.PollInterval)
+	applied, restart := Apply(running, cfg)
+	assert.Equal(t, []string{"Key", "ReportInterval"}, applied)
+	assert.Equal(t, []string{"Collectors"}, restart)
+	assert.Equal(t, 20, running.ReportInterval)
+
+	t.Setenv("REPORT_INTERVAL", "3")
+	_, err = Reload()
+	require.ErrorContains(t, err, "ReportInterval (3s, env REPORT_INTERVAL) must be greater than PollInterval (4s, flag -p)")
 }
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// destination is the server of the fan-out with the metrics which are not delivered to it yet.
+//
+// The source commits the metrics once they are handed over to all destinations, so every destination
+// keeps its own undelivered deltas and they are not subtracted twice: the counters, histograms, summaries
+// and sets are merged with the next batch, the gauges are replaced with the newer values.
+type destination struct {
+	sender  *sender.Sender
+	pending map[string]*model.Metric
+	url     string
+	order   []string
+	// metaSentTo is the server of the sender which the metadata is delivered to, -1 - none
+	metaSentTo int
+}
+
+// newDestination returns the destination sending with the sender configured by cfg
+func newDestination(cfg *sender.Config, l *logging.ZapLogger) *destination {
+	return &destination{
+		sender:     sender.New(cfg, l),
+		pending:    make(map[string]*model.Metric),
+		url:        cfg.UpdatesURL,
+		metaSentTo: -1,
+	}
+}
+
+// add merges the metrics into the pending ones
+func (d *destination) add(data []*model.Metric) {
+	for _, m := range data {
+		key := m.MType + ":" + m.Key()
+		p, ok := d.pending[key]
+		if !ok {
+			d.pending[key] = m.Clone()
+			d.order = append(d.order, key)
+			continue
+		}
+		// the pending metric is replaced if it can not be merged, e.g. the histogram bounds are changed
+		if !model.IsAccumulating(m.MType) || p.Merge(m) != nil {
+			d.pending[key] = m.Clone()
+		}
+	}
+}
+
+// send merges the metrics into the pending ones and sends them, the metadata is sent until it is delivered
+// to the current server of the sender, the pending metrics are dropped once they are delivered or rejected
+func (d *destination) send(ctx context.Context, metas func() []*model.Meta, data []*model.Metric) error {
+	d.add(data)
+	var errMeta error
+	if d.sender.SupportsMeta() && d.metaSentTo != d.sender.Current() {
+		if errMeta = d.sender.SendMeta(ctx, metas()); errMeta == nil {
+			d.metaSentTo = d.sender.Current()
+		}
+	}
+	batch := make([]*model.Metric, len(d.order))
+	for i, key := range d.order {
+		batch[i] = d.pending[key]
+	}
+	err := d.sender.SendBatchMetrics(ctx, batch)
+	// the batch rejected by the server is dropped, it would be rejected again
+	if err == nil || errors.Is(err, sender.ErrRejected) {
+		clear(d.pending)
+		d.order = d.order[:0]
+	}
+	return errors.Join(errMeta, err)
+}
+
+// fanOut sends the metrics to all destinations concurrently and logs the failures,
+// the metrics are kept by the destinations which failed until they are delivered
+func fanOut(ctx context.Context, dests []*destination, metas func() []*model.Meta, data []*model.Metric, l *logging.ZapLogger) {
+	var once sync.Once
+	var cached []*model.Meta
+	metasOnce := func() []*model.Meta {
+		once.Do(func() { cached = metas() })
+		return cached
+	}
+	var wg sync.WaitGroup
+	for _, d := range dests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.send(ctx, metasOnce, data); err != nil {
+				l.ErrorCtx(ctx, fmt.Errorf("failed to fan out metrics: %w", err).Error(),
+					zap.String("url", d.url), zap.Int("pending", len(d.pending)))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+// testServer is the server recording the received batches, the connection is closed while it is down,
+// the batches are answered with status if it is set
+type testServer struct {
+	*httptest.Server
+	batches []map[string]string
+	metas   atomic.Int64
+	status  atomic.Int32
+	down    atomic.Bool
+	mu      sync.Mutex
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	ts := &testServer{}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ts.down.Load() {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if assert.NoError(t, err) {
+				assert.NoError(t, conn.Close())
+			}
+			return
+		}
+		if r.URL.Path == "/meta/" {
+			ts.metas.Add(1)
+		}
+		if r.URL.Path != "/updates/" {
+			return
+		}
+		if status := ts.status.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []*model.Metric
+		if !assert.NoError(t, json.NewDecoder(gz).Decode(&batch)) {
+			return
+		}
+		got := make(map[string]string, len(batch))
+		for _, m := range batch {
+			got[m.MType+":"+m.Key()] = fmt.Sprint(m.AnyValue())
+		}
+		ts.mu.Lock()
+		ts.batches = append(ts.batches, got)
+		ts.mu.Unlock()
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// received returns the received batches
+func (ts *testServer) received() []map[string]string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.batches
+}
+
+func (ts *testServer) senderConfig() *sender.Config {
+	return &sender.Config{UpdatesURL: ts.URL + "/updates/", MetaURL: ts.URL + "/meta/", Timeout: time.Second}
+}
+
+func TestFanOut(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	a, b := newTestServer(t), newTestServer(t)
+	dests := []*destination{newDestination(a.senderConfig(), l), newDestination(b.senderConfig(), l)}
+	metas := func() []*model.Meta { return nil }
+	batch := func(gauge float64, polls int64) []*model.Metric {
+		return []*model.Metric{model.NewMetricGauge("g", gauge), model.NewMetricCounter("PollCount", polls)}
+	}
+
+	b.down.Store(true)
+	fanOut(context.Background(), dests, metas, batch(1, 2), l)
+	assert.Equal(t, []map[string]string{{"gauge:g": "1", "counter:PollCount": "2"}}, a.received())
+	assert.Empty(t, b.received())
+	assert.Len(t, dests[1].pending, 2, "the destination keeps the metrics which failed to send")
+
+	b.down.Store(false)
+	fanOut(context.Background(), dests, metas, batch(5, 3), l)
+	assert.Equal(t, []map[string]string{
+		{"gauge:g": "1", "counter:PollCount": "2"},
+		{"gauge:g": "5", "counter:PollCount": "3"},
+	}, a.received(), "the delivered deltas are not sent again")
+	assert.Equal(t, []map[string]string{{"gauge:g": "5", "counter:PollCount": "5"}}, b.received(),
+		"the undelivered deltas are merged and the gauge is replaced")
+	assert.Empty(t, dests[1].pending)
+}
+
+func TestFanOut_Rejected(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	ts := newTestServer(t)
+	dests := []*destination{newDestination(ts.senderConfig(), l)}
+	metas := func() []*model.Meta { return nil }
+
+	ts.status.Store(http.StatusBadRequest)
+	fanOut(context.Background(), dests, metas, []*model.Metric{model.NewMetricCounter("PollCount", 2)}, l)
+	assert.Empty(t, dests[0].pending, "the rejected batch is dropped")
+
+	ts.status.Store(0)
+	fanOut(context.Background(), dests, metas, []*model.Metric{model.NewMetricCounter("PollCount", 3)}, l)
+	assert.Equal(t, []map[string]string{{"counter:PollCount": "3"}}, ts.received(),
+		"the rejected deltas are not sent again")
+}
+
+func TestFanOut_MetaAfterFailover(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	primary, fallback := newTestServer(t), newTestServer(t)
+	cfg := primary.senderConfig()
+	cfg.Fallbacks = []sender.Endpoint{{UpdatesURL: fallback.URL + "/updates/", MetaURL: fallback.URL + "/meta/"}}
+	dests := []*destination{newDestination(cfg, l)}
+	metas := func() []*model.Meta { return []*model.Meta{{MType: model.TypeGauge, ID: "g", Help: "help"}} }
+	batch := []*model.Metric{model.NewMetricGauge("g", 1)}
+
+	fanOut(context.Background(), dests, metas, batch, l)
+	fanOut(context.Background(), dests, metas, batch, l)
+	assert.Equal(t, int64(1), primary.metas.Load(), "the metadata is sent once")
+
+	primary.down.Store(true)
+	fanOut(context.Background(), dests, metas, batch, l)
+	require.Len(t, fallback.received(), 1, "the sender fails over to the fallback")
+	fanOut(context.Background(), dests, metas, batch, l)
+	assert.Equal(t, int64(1), fallback.metas.Load(), "the metadata is sent to the server after the failover")
+}
@@ -0,0 +1,434 @@
+// Package outbox contains the persistent queue of the metric batches which the agent failed to send.
+//
+// The batches are appended to the segment files in the directory, one JSON line per batch. The drainer
+// replays them in the order of appending and remembers the position of the last sent batch in the cursor
+// file, so a batch is not sent twice after a restart. The oldest segments are dropped when the total size
+// exceeds the limit, the batches older than the age limit are dropped without sending.
+package outbox
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+const (
+	segmentExt = ".seg"
+	cursorFile = "cursor"
+	// segmentsPerOutbox is the number of the segments the maximum size is divided into,
+	// the size limit is enforced by dropping the whole oldest segment
+	segmentsPerOutbox = 8
+	filePerm          = 0o600
+	dirPerm           = 0o700
+)
+
+// Config contains the configuration of the outbox.
+type Config struct {
+	// Dir is the directory of the segment files.
+	Dir string
+	// MaxSize is the maximum total size of the segment files in bytes.
+	MaxSize int64
+	// MaxAge is the maximum age of the replayed batch, 0 - no limit.
+	MaxAge time.Duration
+	// ReplayRate is the maximum number of the batches replayed per second, 0 - no limit.
+	ReplayRate int
+}
+
+// Validate returns an error if the config is not valid.
+func (c *Config) Validate() error {
+	if c.Dir == "" {
+		return errors.New("no outbox directory")
+	}
+	if c.MaxSize <= 0 {
+		return errors.New("outbox size must be positive")
+	}
+	if c.MaxAge < 0 {
+		return errors.New("outbox age must not be negative")
+	}
+	if c.ReplayRate < 0 {
+		return errors.New("outbox replay rate must not be negative")
+	}
+	return nil
+}
+
+// record is the batch stored in the segment.
+type record struct {
+	Metrics []*model.Metric `json:"metrics"`
+	Time    int64           `json:"time"` // время добавления в Unix миллисекундах
+}
+
+// cursor is the position of the next batch to replay.
+type cursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+// ErrRejected is wrapped by the error of the send function of Drain if the batch is rejected by the server,
+// e.g. as malformed: the batch is dropped, since its replay would fail again, and the replay goes on.
+var ErrRejected = errors.New("batch is rejected")
+
+// segment is the segment file.
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// Outbox is the persistent queue of the metric batches.
+type Outbox struct {
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+	current  *os.File
+	segments []*segment
+	cfg      Config
+	cursor   cursor
+	size     int64
+	mu       sync.Mutex
+	drainMu  sync.Mutex
+}
+
+// New opens the outbox in the configured directory, the directory is created if it does not exist.
+//
+// The batches left by the previous run are kept, the new batches are appended to a new segment.
+func New(cfg *Config) (*Outbox, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+	o := &Outbox{cfg: *cfg, now: time.Now, sleep: sleep}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+	for _, e := range entries {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentExt), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), segmentExt) || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat segment: %w", err)
+		}
+		o.segments = append(o.segments, &segment{seq: seq, size: info.Size()})
+		o.size += info.Size()
+	}
+	slices.SortFunc(o.segments, func(a, b *segment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	if err = o.loadCursor(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// loadCursor reads the cursor file, the segments before the cursor are replayed already and removed,
+// the cursor is moved to the first segment if it points to a dropped one.
+func (o *Outbox) loadCursor() error {
+	data, err := os.ReadFile(filepath.Join(o.cfg.Dir, cursorFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read outbox cursor: %w", err)
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &o.cursor); err != nil {
+			return fmt.Errorf("failed to unmarshal outbox cursor: %w", err)
+		}
+	}
+	for len(o.segments) > 0 && o.segments[0].seq < o.cursor.Segment {
+		if err = o.dropOldest(); err != nil {
+			return err
+		}
+	}
+	o.fixCursor()
+	return nil
+}
+
+// fixCursor moves the cursor to the first segment if the segment of the cursor is dropped.
+func (o *Outbox) fixCursor() {
+	if len(o.segments) == 0 || o.cursor.Segment >= o.segments[0].seq {
+		return
+	}
+	o.cursor = cursor{Segment: o.segments[0].seq}
+}
+
+// Append appends the batch to the outbox.
+//
+// The oldest segments are dropped if the size limit is exceeded or all their batches are too old.
+func (o *Outbox) Append(ms []*model.Metric) error {
+	line, err := json.Marshal(&record{Metrics: ms, Time: o.now().UnixMilli()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	line = append(line, '\n')
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	last := o.lastSegment()
+	if o.current == nil || last.size+int64(len(line)) > o.segmentSize() && last.size > 0 {
+		if last, err = o.openSegment(); err != nil {
+			return err
+		}
+	}
+	n, err := o.current.Write(line)
+	last.size += int64(n)
+	o.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write batch: %w", err)
+	}
+	return o.trim()
+}
+
+// segmentSize returns the size of the segment after which a new segment is started.
+func (o *Outbox) segmentSize() int64 {
+	return max(o.cfg.MaxSize/segmentsPerOutbox, 1)
+}
+
+// lastSegment returns the last segment or nil.
+func (o *Outbox) lastSegment() *segment {
+	if len(o.segments) == 0 {
+		return nil
+	}
+	return o.segments[len(o.segments)-1]
+}
+
+// openSegment closes the current segment and starts a new one.
+func (o *Outbox) openSegment() (*segment, error) {
+	if err := o.closeCurrent(); err != nil {
+		return nil, err
+	}
+	var seq uint64
+	if last := o.lastSegment(); last != nil {
+		seq = last.seq + 1
+	} else {
+		seq = o.cursor.Segment
+	}
+	f, err := os.OpenFile(o.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_TRUNC, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment: %w", err)
+	}
+	o.current = f
+	s := &segment{seq: seq}
+	o.segments = append(o.segments, s)
+	o.fixCursor()
+	return s, nil
+}
+
+// closeCurrent closes the current segment.
+func (o *Outbox) closeCurrent() error {
+	if o.current == nil {
+		return nil
+	}
+	err := o.current.Close()
+	o.current = nil
+	if err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+	return nil
+}
+
+// segmentPath returns the path of the segment file.
+func (o *Outbox) segmentPath(seq uint64) string {
+	return filepath.Join(o.cfg.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+// trim drops the oldest closed segments while the size limit is exceeded or the segment is too old.
+func (o *Outbox) trim() error {
+	for len(o.segments) > 1 {
+		oldest := o.segments[0]
+		if o.size <= o.cfg.MaxSize && !o.expired(oldest) {
+			break
+		}
+		if err := o.dropOldest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired returns true if the last batch of the segment is older than the age limit.
+func (o *Outbox) expired(s *segment) bool {
+	if o.cfg.MaxAge == 0 {
+		return false
+	}
+	info, err := os.Stat(o.segmentPath(s.seq))
+	return err == nil && o.now().Sub(info.ModTime()) > o.cfg.MaxAge
+}
+
+// dropOldest removes the oldest segment.
+func (o *Outbox) dropOldest() error {
+	oldest := o.segments[0]
+	if err := os.Remove(o.segmentPath(oldest.seq)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove segment: %w", err)
+	}
+	o.segments = o.segments[1:]
+	o.size -= oldest.size
+	o.fixCursor()
+	return nil
+}
+
+// Pending returns the size of the batches which are not replayed yet.
+func (o *Outbox) Pending() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.size - o.cursor.Offset
+}
+
+// Drain replays the batches in the order of appending until the outbox is empty or send fails.
+//
+// It returns the number of the sent batches and the number of the batches rejected by the server,
+// see ErrRejected. The batches older than the age limit are dropped, the malformed batches,
+// e.g. the one torn by a crash, are skipped.
+//
+// The gauges are replayed with the timestamps of their samples, so the history of the outage is kept.
+// The agent queues the new batches behind the pending ones, so the replayed gauges do not overwrite
+// the newer values, the out-of-order policy of the server settles the rest.
+func (o *Outbox) Drain(ctx context.Context, send func(ctx context.Context, ms []*model.Metric) error) (int, int, error) {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+	sent, rejected := 0, 0
+	for {
+		rec, at, n, err := o.next()
+		if errors.Is(err, io.EOF) {
+			return sent, rejected, nil
+		}
+		if err != nil {
+			return sent, rejected, err
+		}
+		if rec != nil && !o.tooOld(rec) {
+			if sent+rejected > 0 && o.cfg.ReplayRate > 0 {
+				if err = o.sleep(ctx, time.Second/time.Duration(o.cfg.ReplayRate)); err != nil {
+					return sent, rejected, err
+				}
+			}
+			switch err = send(ctx, rec.Metrics); {
+			case err == nil:
+				sent++
+			case errors.Is(err, ErrRejected):
+				rejected++
+			default:
+				return sent, rejected, err
+			}
+		}
+		if err = o.advance(at, n); err != nil {
+			return sent, rejected, err
+		}
+	}
+}
+
+// tooOld returns true if the batch is older than the age limit.
+func (o *Outbox) tooOld(rec *record) bool {
+	return o.cfg.MaxAge > 0 && o.now().Sub(time.UnixMilli(rec.Time)) > o.cfg.MaxAge
+}
+
+// next returns the batch at the cursor with the cursor and the size of the batch line,
+// the batch is nil if it is malformed, io.EOF is returned if there are no batches.
+//
+// The fully replayed closed segments are removed.
+func (o *Outbox) next() (*record, cursor, int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for len(o.segments) > 0 {
+		s := o.segments[0]
+		if o.cursor.Offset < s.size {
+			line, err := o.readLine(s.seq, o.cursor.Offset)
+			if err != nil {
+				return nil, o.cursor, 0, err
+			}
+			var rec record
+			if err = json.Unmarshal(line, &rec); err != nil {
+				return nil, o.cursor, int64(len(line)), nil
+			}
+			return &rec, o.cursor, int64(len(line)), nil
+		}
+		if o.current != nil && len(o.segments) == 1 {
+			break
+		}
+		if err := o.dropOldest(); err != nil {
+			return nil, o.cursor, 0, err
+		}
+		o.cursor = cursor{Segment: s.seq + 1}
+		o.fixCursor()
+		if err := o.saveCursor(); err != nil {
+			return nil, o.cursor, 0, err
+		}
+	}
+	return nil, o.cursor, 0, io.EOF
+}
+
+// readLine reads the batch line of the segment at the offset, the torn last line is returned without the line end.
+func (o *Outbox) readLine(seq uint64, offset int64) ([]byte, error) {
+	f, err := os.Open(o.segmentPath(seq))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek segment: %w", err)
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+		return nil, fmt.Errorf("failed to read segment: %w", err)
+	}
+	return line, nil
+}
+
+// advance moves the cursor past the replayed batch and saves it,
+// the cursor is left unchanged if the segment of the batch is dropped meanwhile.
+func (o *Outbox) advance(at cursor, n int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.cursor != at {
+		return nil
+	}
+	o.cursor.Offset += n
+	return o.saveCursor()
+}
+
+// saveCursor writes the cursor file atomically.
+func (o *Outbox) saveCursor() error {
+	data, err := json.Marshal(o.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox cursor: %w", err)
+	}
+	tmp := filepath.Join(o.cfg.Dir, cursorFile+".tmp")
+	if err = os.WriteFile(tmp, data, filePerm); err != nil {
+		return fmt.Errorf("failed to write outbox cursor: %w", err)
+	}
+	if err = os.Rename(tmp, filepath.Join(o.cfg.Dir, cursorFile)); err != nil {
+		return fmt.Errorf("failed to write outbox cursor: %w", err)
+	}
+	return nil
+}
+
+// Close closes the current segment, the batches are kept for the next run.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.closeCurrent()
+}
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		//nolint:wrapcheck // ignore
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
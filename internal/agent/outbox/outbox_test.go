@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batch returns the batch with the counter of the given delta
+func batch(delta int64) []*model.Metric {
+	return []*model.Metric{model.NewMetricCounter("c", delta)}
+}
+
+// collect returns the send function recording the deltas of the sent batches,
+// it fails after failAfter batches if failAfter is positive
+func collect(sent *[]int64, failAfter int) func(context.Context, []*model.Metric) error {
+	return func(_ context.Context, ms []*model.Metric) error {
+		if failAfter > 0 && len(*sent) == failAfter {
+			return errors.New("server is unavailable")
+		}
+		*sent = append(*sent, *ms[0].Delta)
+		return nil
+	}
+}
+
+func newTestOutbox(t *testing.T, cfg *Config) *Outbox {
+	t.Helper()
+	o, err := New(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = o.Close() })
+	return o
+}
+
+func TestOutbox_Drain(t *testing.T) {
+	dir := t.TempDir()
+	o := newTestOutbox(t, &Config{Dir: dir, MaxSize: 1 << 20})
+	for i := range 5 {
+		require.NoError(t, o.Append(batch(int64(i))))
+	}
+	assert.Positive(t, o.Pending())
+
+	var sent []int64
+	n, _, err := o.Drain(context.TODO(), collect(&sent, 2))
+	require.Error(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{0, 1}, sent)
+
+	n, _, err = o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, sent)
+	assert.Zero(t, o.Pending())
+
+	n, _, err = o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestOutbox_DrainRejected(t *testing.T) {
+	o := newTestOutbox(t, &Config{Dir: t.TempDir(), MaxSize: 1 << 20})
+	for i := range 3 {
+		require.NoError(t, o.Append(batch(int64(i))))
+	}
+	var sent []int64
+	send := collect(&sent, 0)
+	n, rejected, err := o.Drain(context.TODO(), func(ctx context.Context, ms []*model.Metric) error {
+		if *ms[0].Delta == 1 {
+			return fmt.Errorf("%w: status 400", ErrRejected)
+		}
+		return send(ctx, ms)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 1, rejected)
+	assert.Equal(t, []int64{0, 2}, sent, "the rejected batch is dropped and the replay goes on")
+	assert.Zero(t, o.Pending())
+}
+
+func TestOutbox_DrainGauges(t *testing.T) {
+	o := newTestOutbox(t, &Config{Dir: t.TempDir(), MaxSize: 1 << 20})
+	first, second := model.NewMetricGauge("g", 1), model.NewMetricGauge("g", 2)
+	first.Timestamp, second.Timestamp = 100, 200
+	counter := model.NewMetricCounter("c", 3)
+	require.NoError(t, o.Append([]*model.Metric{first}))
+	require.NoError(t, o.Append([]*model.Metric{second, counter}))
+	var sent [][]*model.Metric
+	n, _, err := o.Drain(context.TODO(), func(_ context.Context, ms []*model.Metric) error {
+		sent = append(sent, ms)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, [][]*model.Metric{{first}, {second, counter}}, sent,
+		"the gauges are replayed in order with the timestamps of their samples")
+}
+
+func TestOutbox_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	o, err := New(&Config{Dir: dir, MaxSize: 1 << 20})
+	require.NoError(t, err)
+	for i := range 4 {
+		require.NoError(t, o.Append(batch(int64(i))))
+	}
+	var sent []int64
+	_, _, err = o.Drain(context.TODO(), collect(&sent, 1))
+	require.Error(t, err)
+	require.NoError(t, o.Close())
+
+	o = newTestOutbox(t, &Config{Dir: dir, MaxSize: 1 << 20})
+	require.NoError(t, o.Append(batch(4)))
+	_, _, err = o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1, 2, 3, 4}, sent, "the sent batch is not replayed after the restart")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the replayed closed segment is removed")
+}
+
+func TestOutbox_TornBatch(t *testing.T) {
+	dir := t.TempDir()
+	o, err := New(&Config{Dir: dir, MaxSize: 1 << 20})
+	require.NoError(t, err)
+	require.NoError(t, o.Append(batch(1)))
+	require.NoError(t, o.Close())
+	f, err := os.OpenFile(o.segmentPath(0), os.O_WRONLY|os.O_APPEND, filePerm)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"metrics":[{"id":"c","type":"coun`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	o = newTestOutbox(t, &Config{Dir: dir, MaxSize: 1 << 20})
+	require.NoError(t, o.Append(batch(2)))
+	var sent []int64
+	_, _, err = o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, sent)
+}
+
+func TestOutbox_MaxSize(t *testing.T) {
+	line := int64(len(`{"metrics":[{"type":"counter","id":"c","delta":0}],"time":0000000000000}`) + 1)
+	o := newTestOutbox(t, &Config{Dir: t.TempDir(), MaxSize: 16 * line})
+	for i := range 40 {
+		require.NoError(t, o.Append(batch(int64(i))))
+	}
+	assert.LessOrEqual(t, o.size, o.cfg.MaxSize)
+	var sent []int64
+	_, _, err := o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	require.NotEmpty(t, sent)
+	assert.Less(t, len(sent), 40, "the oldest batches are dropped")
+	assert.Equal(t, int64(39), sent[len(sent)-1])
+	for i := 1; i < len(sent); i++ {
+		assert.Equal(t, sent[i-1]+1, sent[i], "the batches are replayed in order")
+	}
+}
+
+func TestOutbox_MaxAge(t *testing.T) {
+	now := time.Now()
+	o := newTestOutbox(t, &Config{Dir: t.TempDir(), MaxSize: 1 << 20, MaxAge: time.Hour})
+	o.now = func() time.Time { return now }
+	require.NoError(t, o.Append(batch(1)))
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, o.Append(batch(2)))
+	now = now.Add(45 * time.Minute)
+	var sent []int64
+	_, _, err := o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, sent, "the batch older than the age limit is dropped")
+}
+
+func TestOutbox_ReplayRate(t *testing.T) {
+	o := newTestOutbox(t, &Config{Dir: t.TempDir(), MaxSize: 1 << 20, ReplayRate: 4})
+	var waits []time.Duration
+	o.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	for i := range 3 {
+		require.NoError(t, o.Append(batch(int64(i))))
+	}
+	var sent []int64
+	_, _, err := o.Drain(context.TODO(), collect(&sent, 0))
+	require.NoError(t, err)
+	assert.Len(t, sent, 3)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, waits)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	o.sleep = sleep
+	require.NoError(t, o.Append(batch(3)))
+	require.NoError(t, o.Append(batch(4)))
+	n, _, err := o.Drain(ctx, collect(&sent, 0))
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, n)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "valid", cfg: Config{Dir: "outbox", MaxSize: 1, MaxAge: time.Hour, ReplayRate: 1}},
+		{name: "no dir", cfg: Config{MaxSize: 1}, wantErr: true},
+		{name: "no size", cfg: Config{Dir: "outbox"}, wantErr: true},
+		{name: "negative age", cfg: Config{Dir: "outbox", MaxSize: 1, MaxAge: -time.Second}, wantErr: true},
+		{name: "negative rate", cfg: Config{Dir: "outbox", MaxSize: 1, ReplayRate: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr {
+				assert.Error(t, tt.cfg.Validate())
+			} else {
+				assert.NoError(t, tt.cfg.Validate())
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package sender
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned without sending while the circuit breaker is open.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed passes the requests.
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen passes the single probe request, the other requests fail fast.
+	BreakerHalfOpen
+	// BreakerOpen fails the requests fast until the cool-down passes.
+	BreakerOpen
+)
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+// BreakerConfig is the config of the circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold is the number of the consecutive failed sends opening the breaker, 0 - no breaker.
+	FailureThreshold int
+	// CoolDown is the time the breaker stays open before the probe request.
+	CoolDown time.Duration
+}
+
+// breaker is the circuit breaker of the sends.
+//
+// The closed breaker is opened by FailureThreshold consecutive failures. The open breaker fails the sends fast,
+// after CoolDown it becomes half-open and passes a single probe: the breaker is closed if the probe succeeds
+// and is opened again if it fails. The state changes are passed to onChange.
+type breaker struct {
+	openedAt time.Time
+	now      func() time.Time
+	onChange func(from, to BreakerState)
+	cfg      BreakerConfig
+	state    BreakerState
+	failures int
+	probing  bool
+	mu       sync.Mutex
+}
+
+// newBreaker returns the closed breaker.
+func newBreaker(cfg BreakerConfig, onChange func(from, to BreakerState)) *breaker {
+	return &breaker{cfg: cfg, now: time.Now, onChange: onChange}
+}
+
+// allow returns ErrBreakerOpen if the send must fail fast, otherwise the send is allowed
+// and its result must be passed to done.
+func (b *breaker) allow() error {
+	if b.cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cfg.CoolDown:
+		b.state = BreakerHalfOpen
+		b.probing = true
+	case b.state == BreakerOpen || b.state == BreakerHalfOpen && b.probing:
+		b.mu.Unlock()
+		return ErrBreakerOpen
+	case b.state == BreakerHalfOpen:
+		b.probing = true
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+	return nil
+}
+
+// done records the result of the allowed send, the send which is not completed,
+// e.g. canceled by the context, is not counted.
+func (b *breaker) done(ok, completed bool) {
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	wasProbe := b.probing && b.state == BreakerHalfOpen
+	if wasProbe {
+		b.probing = false
+	}
+	switch {
+	case !completed:
+	case ok:
+		b.failures = 0
+		b.state = BreakerClosed
+	case wasProbe:
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	case b.state == BreakerClosed:
+		b.failures++
+		if b.failures >= b.cfg.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+}
+
+// changed passes the state change to onChange.
+func (b *breaker) changed(from, to BreakerState) {
+	if from != to && b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
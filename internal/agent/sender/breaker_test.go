@@ -0,0 +1,77 @@
+package sender
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	var changes []string
+	b := newBreaker(BreakerConfig{FailureThreshold: 2, CoolDown: time.Minute}, func(from, to BreakerState) {
+		changes = append(changes, from.String()+">"+to.String())
+	})
+	b.now = func() time.Time { return now }
+
+	require.NoError(t, b.allow())
+	b.done(false, true)
+	require.NoError(t, b.allow(), "the breaker is closed below the threshold")
+	b.done(false, true)
+	require.ErrorIs(t, b.allow(), ErrBreakerOpen)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.allow(), "the probe is passed after the cool-down")
+	require.ErrorIs(t, b.allow(), ErrBreakerOpen, "only a single probe is passed")
+	b.done(false, true)
+	require.ErrorIs(t, b.allow(), ErrBreakerOpen, "the failed probe opens the breaker again")
+
+	now = now.Add(time.Minute)
+	require.NoError(t, b.allow())
+	b.done(false, false)
+	require.NoError(t, b.allow(), "the canceled probe is not counted")
+	b.done(true, true)
+	require.NoError(t, b.allow())
+	require.NoError(t, b.allow())
+
+	assert.Equal(t, []string{
+		"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed",
+	}, changes)
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	b := newBreaker(BreakerConfig{}, nil)
+	for range 10 {
+		require.NoError(t, b.allow())
+		b.done(false, true)
+	}
+}
+
+func TestSender_Breaker(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	s := New(&Config{UpdatesURL: srv.URL, Breaker: BreakerConfig{FailureThreshold: 2, CoolDown: time.Hour}}, l)
+	rec := &countingRecorder{}
+	s.SetRecorder(rec)
+
+	for range 2 {
+		require.ErrorContains(t, s.SendBatchMetrics(context.Background(), nil), "unexpected status code received: 503")
+	}
+	require.ErrorIs(t, s.SendBatchMetrics(context.Background(), nil), ErrBreakerOpen)
+	assert.EqualValues(t, 2, calls.Load(), "the open breaker fails fast")
+	assert.Equal(t, []int{int(BreakerClosed), int(BreakerOpen)}, rec.states)
+}
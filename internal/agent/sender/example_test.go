@@ -39,12 +39,12 @@ func Example() {
 
 	// Create a Sender configuration
 	cfg := &sender.Config{
-		UpdateURL:   server.URL + "/update",
-		UpdatesURL:  server.URL + "/updates",
-		Timeout:     5 * time.Second,
-		RetryDelays: []time.Duration{1 * time.Second, 3 * time.Second},
-		Key:         []byte("secret-key"),
-		RateLimit:   2,
+		UpdateURL:  server.URL + "/update",
+		UpdatesURL: server.URL + "/updates",
+		Timeout:    5 * time.Second,
+		Retry:      sender.RetryPolicy{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+		Key:        []byte("secret-key"),
+		RateLimit:  2,
 	}
 
 	// Create a Sender
@@ -104,10 +104,10 @@ func ExampleSender_SendMetric() {
 
 	// Create a Sender configuration
 	cfg := &sender.Config{
-		UpdateURL:   server.URL + "/update",
-		Timeout:     5 * time.Second,
-		RetryDelays: []time.Duration{1 * time.Second},
-		Key:         []byte("secret-key"),
+		UpdateURL: server.URL + "/update",
+		Timeout:   5 * time.Second,
+		Retry:     sender.RetryPolicy{MaxRetries: 1, BaseDelay: time.Second},
+		Key:       []byte("secret-key"),
 	}
 
 	// Create a Sender
@@ -148,10 +148,10 @@ func ExampleSender_SendBatchMetrics() {
 
 	// Create a Sender configuration
 	cfg := &sender.Config{
-		UpdatesURL:  server.URL + "/updates",
-		Timeout:     5 * time.Second,
-		RetryDelays: []time.Duration{1 * time.Second},
-		Key:         []byte("secret-key"),
+		UpdatesURL: server.URL + "/updates",
+		Timeout:    5 * time.Second,
+		Retry:      sender.RetryPolicy{MaxRetries: 1, BaseDelay: time.Second},
+		Key:        []byte("secret-key"),
 	}
 
 	// Create a Sender
@@ -191,11 +191,11 @@ func ExampleSender_SendPoolMetrics() {
 
 	// Create a Sender configuration
 	cfg := &sender.Config{
-		UpdateURL:   server.URL + "/update",
-		Timeout:     5 * time.Second,
-		RetryDelays: []time.Duration{1 * time.Second},
-		Key:         []byte("secret-key"),
-		RateLimit:   2,
+		UpdateURL: server.URL + "/update",
+		Timeout:   5 * time.Second,
+		Retry:     sender.RetryPolicy{MaxRetries: 1, BaseDelay: time.Second},
+		Key:       []byte("secret-key"),
+		RateLimit: 2,
 	}
 
 	// Create a Sender
@@ -220,3 +220,89 @@ func ExampleSender_SendPoolMetrics() {
 	fmt.Printf("Successfully sent %d metrics\n", successCount)
 	// Output: Successfully sent 2 metrics
 }
+
+func ExampleSender_SendBatchMetrics_protobuf() {
+	// Create a logger
+	logger, err := logging.NewZapLogger(zap.InfoLevel)
+	if err != nil {
+		fmt.Printf("Error creating logger: %v\n", err)
+		return
+	}
+
+	// Create a mock server decoding the protobuf batch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mrs, err := model.UnmarshalMetricsRequestFromProtoReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("Content-Type: %s, received %d metrics\n", r.Header.Get("Content-Type"), len(mrs))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// Create a Sender configuration with the protobuf encoding
+	cfg := &sender.Config{
+		UpdatesURL: server.URL + "/updates",
+		Timeout:    5 * time.Second,
+		Retry:      sender.RetryPolicy{MaxRetries: 1, BaseDelay: time.Second},
+		Key:        []byte("secret-key"),
+		Protobuf:   true,
+	}
+
+	// Create a Sender
+	s := sender.New(cfg, logger)
+
+	// Send the batch
+	err = s.SendBatchMetrics(context.Background(), []*model.Metric{
+		{ID: "testGauge", MType: "gauge", Value: float64Ptr(42.0)},
+		{ID: "testCounter", MType: "counter", Delta: int64Ptr(100)},
+	})
+	if err != nil {
+		fmt.Printf("Error sending batch: %v\n", err)
+		return
+	}
+	// Output: Content-Type: application/x-protobuf, received 2 metrics
+}
+
+func ExampleSender_SendBatchMetrics_failover() {
+	// Create a logger
+	logger, err := logging.NewZapLogger(zap.ErrorLevel)
+	if err != nil {
+		fmt.Printf("Error creating logger: %v\n", err)
+		return
+	}
+
+	// Create the primary server which is down and the fallback server
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	primary.Close()
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Println("Received by the fallback server:", r.URL.Path)
+	}))
+	defer fallback.Close()
+
+	// Create a Sender configuration with the fallback server
+	cfg := &sender.Config{
+		UpdatesURL: primary.URL + "/updates/",
+		Fallbacks:  []sender.Endpoint{{UpdatesURL: fallback.URL + "/updates/"}},
+		Timeout:    5 * time.Second,
+	}
+
+	// Create a Sender
+	s := sender.New(cfg, logger)
+
+	// The batch is sent to the fallback server, the sender sticks to it
+	for range 2 {
+		err = s.SendBatchMetrics(context.Background(), []*model.Metric{
+			{ID: "testCounter", MType: "counter", Delta: int64Ptr(1)},
+		})
+		if err != nil {
+			fmt.Printf("Error sending batch: %v\n", err)
+			return
+		}
+	}
+
+	// Output:
+	// Received by the fallback server: /updates/
+	// Received by the fallback server: /updates/
+}
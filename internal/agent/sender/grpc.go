@@ -0,0 +1,249 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport is the protocol of sending the metrics to the server.
+type Transport string
+
+const (
+	// TransportHTTP sends the metrics to the HTTP server.
+	TransportHTTP Transport = "http"
+	// TransportGRPC sends the metrics to the gRPC server.
+	TransportGRPC Transport = "grpc"
+)
+
+// ParseTransport returns the transport by name, the empty name is TransportHTTP.
+func ParseTransport(s string) (Transport, error) {
+	switch t := Transport(s); t {
+	case "":
+		return TransportHTTP, nil
+	case TransportHTTP, TransportGRPC:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown transport %q", s)
+}
+
+const (
+	// signMetadataKey is the metadata key of the request signature.
+	signMetadataKey = "hashsha256"
+	// streamChunkSize is the number of metrics in a message of the stream, the larger batch is streamed,
+	// so its size is not limited by the maximum message size of the server.
+	streamChunkSize = 1000
+)
+
+// newConns creates the gRPC connections of the endpoints, the requests are signed and compressed with gzip.
+func (s *Sender) newConns() error {
+	for _, e := range s.endpoints {
+		conn, err := grpc.NewClient(e.Addr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)),
+			grpc.WithUnaryInterceptor(s.signUnary),
+			grpc.WithStreamInterceptor(s.signStream),
+		)
+		if err != nil {
+			err = errors.Join(fmt.Errorf("failed to create gRPC client of %s: %w", e.Addr, err), s.Close())
+			s.conns = nil
+			return err
+		}
+		s.conns = append(s.conns, conn)
+	}
+	return nil
+}
+
+// updateGRPC sends the metric with the Update call.
+func (s *Sender) updateGRPC(ctx context.Context, m *model.Metric) error {
+	p, err := m.ToProto()
+	if err != nil {
+		return fmt.Errorf("failed to convert metric: %w", err)
+	}
+	return s.invoke(ctx, func(ctx context.Context, c pb.MetricsClient) error {
+		_, err := c.Update(ctx, p)
+		//nolint:wrapcheck // ignore
+		return err
+	})
+}
+
+// updateBatchGRPC sends the batch with the UpdateBatch call, the batch larger than streamChunkSize
+// is sent in chunks with the StreamUpdates call.
+func (s *Sender) updateBatchGRPC(ctx context.Context, ms []*model.Metric) error {
+	batch, err := model.MetricsToProto(ms)
+	if err != nil {
+		return fmt.Errorf("failed to convert metrics: %w", err)
+	}
+	if len(ms) <= streamChunkSize {
+		return s.invoke(ctx, func(ctx context.Context, c pb.MetricsClient) error {
+			_, err := c.UpdateBatch(ctx, batch)
+			//nolint:wrapcheck // ignore
+			return err
+		})
+	}
+	return s.invoke(ctx, func(ctx context.Context, c pb.MetricsClient) error {
+		return streamUpdates(ctx, c, batch.GetMetrics())
+	})
+}
+
+// streamUpdates sends the metrics in chunks of streamChunkSize with the StreamUpdates call.
+func streamUpdates(ctx context.Context, c pb.MetricsClient, metrics []*pb.Metric) error {
+	stream, err := c.StreamUpdates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	for i := 0; i < len(metrics); i += streamChunkSize {
+		req := &pb.StreamUpdatesRequest{Batch: &pb.MetricBatch{Metrics: metrics[i:min(i+streamChunkSize, len(metrics))]}}
+		// the error of the stream is received by CloseAndRecv
+		if err = stream.Send(req); err != nil {
+			break
+		}
+	}
+	if _, err = stream.CloseAndRecv(); err != nil {
+		//nolint:wrapcheck // the status of the call is used to retry it
+		return err
+	}
+	return nil
+}
+
+// invoke calls the server with the retries, the servers are failed over like the HTTP servers.
+func (s *Sender) invoke(ctx context.Context, call func(context.Context, pb.MetricsClient) error) error {
+	if s.grpcErr != nil {
+		return s.grpcErr
+	}
+	return s.failover(ctx, addr, func(n int, _ string) error {
+		return s.callRetry(ctx, pb.NewMetricsClient(s.conns[n]), call)
+	})
+}
+
+// callRetry calls the server and retries the call according to the retry policy,
+// every attempt is limited by the timeout of the config.
+func (s *Sender) callRetry(ctx context.Context, c pb.MetricsClient, call func(context.Context, pb.MetricsClient) error) error {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err //nolint:wrapcheck // ignore
+		}
+		err := s.callTimeout(ctx, c, call)
+		if err == nil {
+			return nil
+		}
+		if status.Code(err) == codes.Aborted {
+			return fmt.Errorf("%w: %w: %w", ErrRejected, ErrPartiallyStored, err)
+		}
+		if isRejectedCode(status.Code(err)) {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+		delay, ok := s.cfg.Retry.delay(attempt, nil)
+		if !isRetryableCode(status.Code(err)) || !ok || ctx.Err() != nil {
+			return err
+		}
+		s.l.WarnCtx(ctx, "failed to call server, will retry",
+			zap.Int("attempt", attempt+1), zap.Duration("delay", delay), zap.Error(err))
+		s.rec.RecordRetry()
+		if !sleep(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// callTimeout calls the server within the timeout of the config, 0 - no timeout.
+func (s *Sender) callTimeout(ctx context.Context, c pb.MetricsClient, call func(context.Context, pb.MetricsClient) error) error {
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+	return call(ctx, c)
+}
+
+// isRetryableCode returns true if the call which failed with the code should be retried,
+// the codes match the network failures, the 5xx and 429 status codes of the HTTP transport.
+func isRetryableCode(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// isRejectedCode returns true if the server rejects the call which failed with the code,
+// the codes match the 4xx status codes of the HTTP transport.
+func isRejectedCode(code codes.Code) bool {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.NotFound, codes.Unauthenticated:
+		return true
+	}
+	return false
+}
+
+// signUnary is the interceptor signing the request in the metadata.
+func (s *Sender) signUnary(ctx context.Context, method string, req, reply any,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if msg, ok := req.(proto.Message); ok {
+		hash, err := s.hash(msg)
+		if err != nil {
+			return err
+		}
+		if hash != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, signMetadataKey, hash)
+		}
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// signStream is the interceptor signing the messages of the stream,
+// the messages can not be signed with the metadata, so the signature is set to the message.
+func (s *Sender) signStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		//nolint:wrapcheck // the status of the call is used to retry it
+		return nil, err
+	}
+	return &signedClientStream{ClientStream: cs, hash: s.hash}, nil
+}
+
+// signedClientStream is a grpc.ClientStream signing the sent batches.
+type signedClientStream struct {
+	grpc.ClientStream
+	hash func(proto.Message) (string, error)
+}
+
+// SendMsg signs the batch of the message and sends it.
+func (cs *signedClientStream) SendMsg(m any) error {
+	if req, ok := m.(*pb.StreamUpdatesRequest); ok {
+		hash, err := cs.hash(req.GetBatch())
+		if err != nil {
+			return err
+		}
+		req.Hash = hash
+	}
+	//nolint:wrapcheck // the status of the call is used to retry it
+	return cs.ClientStream.SendMsg(m)
+}
+
+// hash returns the signature of the deterministic encoding of the message with the key,
+// the empty string is returned if the key is not set.
+func (s *Sender) hash(msg proto.Message) (string, error) {
+	key := *s.key.Load()
+	if len(key) == 0 {
+		return "", nil
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return sign.MakeToString(data, key), nil
+}
@@ -0,0 +1,189 @@
+package sender
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseTransport(t *testing.T) {
+	for _, tt := range []struct {
+		s       string
+		want    Transport
+		wantErr bool
+	}{
+		{s: "", want: TransportHTTP},
+		{s: "http", want: TransportHTTP},
+		{s: "grpc", want: TransportGRPC},
+		{s: "udp", wantErr: true},
+	} {
+		got, err := ParseTransport(tt.s)
+		if tt.wantErr {
+			assert.Error(t, err, tt.s)
+			continue
+		}
+		require.NoError(t, err, tt.s)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestIsRetryableCode(t *testing.T) {
+	for _, code := range []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.ResourceExhausted} {
+		assert.True(t, isRetryableCode(code), code)
+		assert.False(t, isRejectedCode(code), code)
+	}
+	for _, code := range []codes.Code{codes.InvalidArgument, codes.FailedPrecondition, codes.NotFound, codes.Unauthenticated} {
+		assert.False(t, isRetryableCode(code), code)
+		assert.True(t, isRejectedCode(code), code)
+	}
+	assert.False(t, isRetryableCode(codes.Canceled))
+}
+
+// stubMetricsServer fails the first calls with the code, codes.Unavailable by default,
+// and keeps the signature of the last call
+type stubMetricsServer struct {
+	pb.UnimplementedMetricsServer
+	hash  atomic.Value
+	calls atomic.Int32
+	fail  int32
+	code  codes.Code
+}
+
+func (s *stubMetricsServer) UpdateBatch(ctx context.Context, batch *pb.MetricBatch) (*pb.MetricBatch, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		s.hash.Store(md.Get(signMetadataKey))
+	}
+	if s.calls.Add(1) <= s.fail {
+		if s.code != codes.OK {
+			return nil, status.Error(s.code, "failed")
+		}
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	return batch, nil
+}
+
+// startStubServer starts the gRPC server of the stub and returns its address
+func startStubServer(t *testing.T, srv pb.MetricsServer) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	pb.RegisterMetricsServer(s, srv)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func TestSender_gRPC(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	stub := &stubMetricsServer{fail: 2}
+	addr := startStubServer(t, stub)
+	s := New(&Config{
+		Addr:      addr,
+		Transport: TransportGRPC,
+		Key:       []byte("key"),
+		Retry:     RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+		Timeout:   time.Second,
+	}, l)
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	rec := &countingRecorder{}
+	s.SetRecorder(rec)
+
+	ms := []*model.Metric{model.NewMetricCounter("c", 1)}
+	require.NoError(t, s.SendBatchMetrics(t.Context(), ms))
+	assert.EqualValues(t, 3, stub.calls.Load())
+	assert.EqualValues(t, 2, rec.retries.Load())
+	batch, err := model.MetricsToProto(ms)
+	require.NoError(t, err)
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(batch)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sign.MakeToString(data, []byte("key"))}, stub.hash.Load())
+	require.ErrorIs(t, s.SendMeta(t.Context(), nil), ErrMetaNotSupported, "the metadata is not sent with gRPC")
+	assert.False(t, s.SupportsMeta())
+
+	s.SetKey(nil)
+	require.NoError(t, s.SendBatchMetrics(t.Context(), ms))
+	assert.Empty(t, stub.hash.Load(), "the request is not signed without the key")
+
+	stub.fail = stub.calls.Load() + 10
+	err = s.SendBatchMetrics(t.Context(), ms)
+	assert.Equal(t, codes.Unavailable, status.Code(err), err)
+}
+
+func TestSender_gRPC_Failover(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	down := &stubMetricsServer{fail: 1 << 30}
+	up := &stubMetricsServer{}
+	s := New(&Config{
+		Addr:      startStubServer(t, down),
+		Transport: TransportGRPC,
+		Fallbacks: []Endpoint{NewEndpoint(startStubServer(t, up))},
+	}, l)
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	require.NoError(t, s.SendBatchMetrics(t.Context(), []*model.Metric{model.NewMetricGauge("g", 1)}))
+	require.NoError(t, s.SendBatchMetrics(t.Context(), []*model.Metric{model.NewMetricGauge("g", 2)}))
+	assert.EqualValues(t, 1, down.calls.Load(), "the sender sticks to the server which succeeds")
+	assert.EqualValues(t, 2, up.calls.Load())
+}
+
+func TestSender_gRPC_PartiallyStored(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	srv := &stubMetricsServer{fail: 1 << 30, code: codes.Aborted}
+	s := New(&Config{
+		Addr:      startStubServer(t, srv),
+		Transport: TransportGRPC,
+		Retry:     RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+	}, l)
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	err = s.SendBatchMetrics(t.Context(), []*model.Metric{model.NewMetricCounter("c", 1)})
+	require.ErrorIs(t, err, ErrRejected)
+	require.ErrorIs(t, err, ErrPartiallyStored)
+	assert.EqualValues(t, 1, srv.calls.Load(), "the partially stored batch is not retried")
+}
+
+func TestSender_gRPC_Rejected(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	rejecting := &stubMetricsServer{fail: 1 << 30, code: codes.InvalidArgument}
+	fallback := &stubMetricsServer{}
+	s := New(&Config{
+		Addr:      startStubServer(t, rejecting),
+		Transport: TransportGRPC,
+		Fallbacks: []Endpoint{NewEndpoint(startStubServer(t, fallback))},
+		Retry:     RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+	}, l)
+	defer func() {
+		assert.NoError(t, s.Close())
+	}()
+	err = s.SendBatchMetrics(t.Context(), []*model.Metric{model.NewMetricGauge("g", 1)})
+	require.ErrorIs(t, err, ErrRejected)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), err)
+	assert.EqualValues(t, 1, rejecting.calls.Load(), "the rejected call is not retried")
+	assert.Zero(t, fallback.calls.Load(), "the rejected call is not sent to the next server")
+}
@@ -9,30 +9,119 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"syscall"
-	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
 )
 
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// endpointURL selects the URL of the endpoint.
+type endpointURL func(e *Endpoint) string
+
+func updateURL(e *Endpoint) string { return e.UpdateURL }
+
+func updatesURL(e *Endpoint) string { return e.UpdatesURL }
+
+func metaURL(e *Endpoint) string { return e.MetaURL }
+
+func addr(e *Endpoint) string { return e.Addr }
+
 // postData sends data to the server.
-func (s *Sender) postData(ctx context.Context, url string, data any) error {
-	b, hash, err := s.makeBodyWithHash(data)
-	if err != nil {
-		return fmt.Errorf("failed to make body with hash: %w", err)
+func (s *Sender) postData(ctx context.Context, url endpointURL, data any) error {
+	return s.sendData(ctx, http.MethodPost, url, data)
+}
+
+// sendData sends data encoded with JSON to the server with the given method.
+func (s *Sender) sendData(ctx context.Context, method string, url endpointURL, data any) error {
+	var body []byte
+	if data != nil {
+		var err error
+		if body, err = json.Marshal(data); err != nil {
+			return fmt.Errorf("failed to marshal data: %w", err)
+		}
 	}
-	postBody, err := makeGzipBuffer(b)
+	return s.sendBody(ctx, method, url, contentTypeJSON, body, true)
+}
+
+// postProto sends the protobuf message to the server.
+//
+// The message is not compressed: the protobuf encoding is compact already and gzip would cost
+// most of the CPU time saved on the encoding.
+func (s *Sender) postProto(ctx context.Context, url endpointURL, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("failed to make gzip buffer: %w", err)
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return s.sendBody(ctx, http.MethodPost, url, contentTypeProtobuf, body, false)
+}
+
+// sendBody sends the body signed with the key to the current server, the body is compressed with gzip
+// if gzipped is set. The next servers are tried by failover if the server fails.
+func (s *Sender) sendBody(ctx context.Context, method string, url endpointURL, contentType string, body []byte, gzipped bool) error {
+	hash := ""
+	if body != nil {
+		hash = sign.MakeToString(body, *s.key.Load())
+	}
+	return s.failover(ctx, url, func(_ int, u string) error {
+		return s.sendTo(ctx, method, u, contentType, hash, body, gzipped)
+	})
+}
+
+// failover sends with send to the target of the current server, if the server fails, the next servers
+// are tried in order, the server which succeeds becomes the current one. The rejected request
+// is not sent to the next servers.
+// ErrBreakerOpen is returned without sending while the circuit breaker is open.
+func (s *Sender) failover(ctx context.Context, target endpointURL, send func(n int, target string) error) error {
+	if err := s.breaker.allow(); err != nil {
+		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, postBody)
+	current := int(s.current.Load())
+	var err error
+	for i := range s.endpoints {
+		n := (current + i) % len(s.endpoints)
+		u := target(&s.endpoints[n])
+		// the server which rejects the request is available
+		if err = send(n, u); err == nil || errors.Is(err, ErrRejected) {
+			if n != current {
+				s.current.Store(int64(n))
+				s.l.InfoCtx(ctx, "switched to the server", zap.String("url", u))
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(s.endpoints)-1 {
+			s.l.WarnCtx(ctx, "the server failed, trying the next one", zap.String("url", u), zap.Error(err))
+		}
+	}
+	s.breaker.done(err == nil || errors.Is(err, ErrRejected), err == nil || ctx.Err() == nil)
+	return err
+}
+
+// sendTo sends the body with the hash to the URL.
+func (s *Sender) sendTo(ctx context.Context, method, url, contentType, hash string, body []byte, gzipped bool) error {
+	var reqBody io.Reader = bytes.NewReader(body)
+	if gzipped {
+		var err error
+		if reqBody, err = makeGzipBuffer(body); err != nil {
+			return fmt.Errorf("failed to make gzip buffer: %w", err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept-Encoding", "gzip")
-	req.Header.Set("Content-Encoding", "gzip")
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	if hash != "" {
 		req.Header.Set("HashSHA256", hash)
 	}
@@ -48,43 +137,49 @@ func (s *Sender) postData(ctx context.Context, url string, data any) error {
 	return nil
 }
 
-// doRetry retries the request.
-func (s *Sender) doRetry(ctx context.Context, req *http.Request) (resp *http.Response, err error) {
-	for i := 0; ; i++ {
-		if err = ctx.Err(); err != nil {
-			break
+// doRetry sends the request and retries it according to the retry policy,
+// the request body is rewound before every retry. The error is returned for any non-2xx response
+// which is still received after the last retry, ErrRejected is wrapped if the response is not retryable,
+// ErrPartiallyStored is wrapped too if the batch is partially stored.
+func (s *Sender) doRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err //nolint:wrapcheck // ignore
+		}
+		resp, err := s.client.Do(req)
+		retryable := isRetryable(resp, err)
+		if err == nil && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return resp, nil
 		}
-		resp, err = s.client.Do(req)
 		if err == nil {
-			if resp.StatusCode >= http.StatusOK || resp.StatusCode < http.StatusInternalServerError {
-				break
-			}
-			if err = resp.Body.Close(); err != nil {
-				s.l.WarnCtx(ctx, "failed to close body", zap.Error(err))
+			if errClose := resp.Body.Close(); errClose != nil {
+				s.l.WarnCtx(ctx, "failed to close body", zap.Error(errClose))
 			}
 			err = fmt.Errorf("unexpected status code received: %d", resp.StatusCode)
-		} else if !errors.Is(err, syscall.ECONNREFUSED) {
-			break
+			if resp.StatusCode == http.StatusUnprocessableEntity {
+				err = fmt.Errorf("%w: %w", ErrPartiallyStored, err)
+			}
+			if !retryable {
+				err = fmt.Errorf("%w: %w", ErrRejected, err)
+			}
 		}
-		if i == len(s.cfg.RetryDelays) || ctx.Err() != nil {
-			break
+		delay, ok := s.cfg.Retry.delay(attempt, resp)
+		if !retryable || !ok || ctx.Err() != nil {
+			return nil, err
+		}
+		s.l.WarnCtx(ctx, "failed to send request, will retry",
+			zap.Int("attempt", attempt+1), zap.Duration("delay", delay), zap.Error(err))
+		s.rec.RecordRetry()
+		// the retry is not awaited beyond the deadline, e.g. of the final flush on shutdown
+		if !sleep(ctx, delay) {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
 		}
-		s.l.WarnCtx(ctx, "failed to send request, will retry", zap.Int("attempt", i+1), zap.Error(err))
-		time.Sleep(s.cfg.RetryDelays[i])
-	}
-	return resp, err
-}
-
-// makeBodyWithHash makes the body with hash.
-func (s *Sender) makeBodyWithHash(data any) (dataBytes []byte, hash string, err error) {
-	if data == nil {
-		return nil, "", nil
-	}
-	dataBytes, err = json.Marshal(data)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to marshal data: %w", err)
 	}
-	return dataBytes, sign.MakeToString(dataBytes, s.cfg.Key), nil
 }
 
 // makeGzipBuffer makes the gzip buffer.
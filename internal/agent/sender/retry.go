@@ -0,0 +1,106 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrRejected is returned if the server rejects the request, e.g. with 400 or 409: the request is not retried
+// and is not sent to the other servers, since they reject it as well.
+var ErrRejected = errors.New("request is rejected by the server")
+
+// ErrPartiallyStored is returned with ErrRejected if the server stores a part of the batch before the failure,
+// e.g. with 422 or Aborted: the batch is not sent again, since the stored deltas would be applied twice.
+var ErrPartiallyStored = errors.New("batch is partially stored by the server")
+
+// RetryPolicy is the policy of retrying the failed requests with the exponential backoff with full jitter.
+//
+// The requests are retried on the connection refusals and resets, the timeouts and the responses
+// with the 5xx and 429 status codes. The delay before the retry n (from 0) is random in
+// [0, min(MaxDelay, BaseDelay*2^n)], the delay of the Retry-After header is used instead if it is set,
+// the request is not retried if the server asks to wait longer than MaxDelay.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of the retries of the request, 0 - no retries.
+	MaxRetries int
+	// BaseDelay is the maximum delay before the first retry.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum delay before a retry, 0 - no limit.
+	MaxDelay time.Duration
+}
+
+// backoff returns the random delay before the retry of the attempt (from 0).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay
+	for range attempt {
+		if (p.MaxDelay > 0 && ceiling >= p.MaxDelay) || ceiling > math.MaxInt64/2 {
+			break
+		}
+		ceiling *= 2
+	}
+	if p.MaxDelay > 0 {
+		ceiling = min(ceiling, p.MaxDelay)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1)) //nolint:gosec // the jitter does not need a secure random
+}
+
+// delay returns the delay before the retry of the attempt (from 0) which failed with the response
+// or false if the request is not retried anymore.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt >= p.MaxRetries {
+		return 0, false
+	}
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return d, p.MaxDelay <= 0 || d <= p.MaxDelay
+		}
+	}
+	return p.backoff(attempt), true
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds or as the HTTP date.
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// isRetryable returns true if the request which failed with the response or the error should be retried.
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.As(err, &netErr) && netErr.Timeout()
+	}
+	return resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// sleep waits for the delay, false is returned if the context is done before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
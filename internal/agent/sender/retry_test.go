@@ -0,0 +1,243 @@
+package sender
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{MaxRetries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, ceiling := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		for range 100 {
+			d := p.backoff(attempt)
+			assert.GreaterOrEqual(t, d, time.Duration(0))
+			assert.LessOrEqual(t, d, ceiling*time.Millisecond, "attempt %d", attempt)
+		}
+	}
+	assert.LessOrEqual(t, (&RetryPolicy{BaseDelay: time.Hour}).backoff(100), time.Duration(1<<63-1),
+		"the delay without the limit does not overflow")
+	assert.Zero(t, (&RetryPolicy{}).backoff(3))
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+	withRetryAfter := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+	tests := []struct {
+		resp    *http.Response
+		name    string
+		attempt int
+		want    time.Duration
+		wantOK  bool
+	}{
+		{name: "backoff", attempt: 1, want: 2 * time.Millisecond, wantOK: true},
+		{name: "retries exhausted", attempt: 2},
+		{name: "retry after seconds", resp: withRetryAfter("3"), want: 3 * time.Second, wantOK: true},
+		{name: "retry after too long", resp: withRetryAfter("60"), want: time.Minute},
+		{name: "malformed retry after", resp: withRetryAfter("soon"), want: time.Millisecond, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := p.delay(tt.attempt, tt.resp)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.LessOrEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{header: ""},
+		{header: "120", want: 2 * time.Minute, wantOK: true},
+		{header: "-1", wantOK: true},
+		{header: now.Add(30 * time.Second).Format(http.TimeFormat), want: 30 * time.Second, wantOK: true},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), wantOK: true},
+		{header: "tomorrow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, ok := retryAfter(tt.header, now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		code int
+		want bool
+	}{
+		{name: "ok", code: http.StatusOK},
+		{name: "bad request", code: http.StatusBadRequest},
+		{name: "too many requests", code: http.StatusTooManyRequests, want: true},
+		{name: "server error", code: http.StatusInternalServerError, want: true},
+		{name: "bad gateway", code: http.StatusBadGateway, want: true},
+		{name: "refused", err: fmt.Errorf("dial: %w", syscall.ECONNREFUSED), want: true},
+		{name: "reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "closed", err: fmt.Errorf("read: %w", io.EOF), want: true},
+		{name: "timeout", err: fmt.Errorf("read: %w", os.ErrDeadlineExceeded), want: true},
+		{name: "other", err: errors.New("unsupported protocol scheme")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.code}
+			}
+			assert.Equal(t, tt.want, isRetryable(resp, tt.err))
+		})
+	}
+}
+
+func TestSender_doRetry(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.ErrorLevel)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gz, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err) {
+			body, err := io.ReadAll(gz)
+			assert.NoError(t, err)
+			bodies = append(bodies, string(body))
+		}
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+	s := New(&Config{
+		UpdatesURL: srv.URL,
+		Retry:      RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	}, l)
+	rec := &countingRecorder{}
+	s.SetRecorder(rec)
+
+	require.NoError(t, s.SendBatchMetrics(context.Background(), []*model.Metric{model.NewMetricCounter("c", 1)}))
+	assert.EqualValues(t, 3, calls.Load())
+	require.Len(t, bodies, 3)
+	assert.Equal(t, bodies[0], bodies[1], "the body is rewound before the retry")
+	assert.Equal(t, bodies[0], bodies[2], "the body is rewound before the retry")
+	assert.EqualValues(t, 2, rec.retries.Load())
+}
+
+func TestSender_doRetry_Exhausted(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	s := New(&Config{UpdatesURL: srv.URL, Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}}, l)
+	err = s.SendBatchMetrics(context.Background(), nil)
+	require.ErrorContains(t, err, "unexpected status code received: 502")
+	assert.EqualValues(t, 3, calls.Load())
+}
+
+func TestSender_doRetry_Rejected(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	var fallbackCalls atomic.Int32
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackCalls.Add(1)
+	}))
+	defer fallback.Close()
+	s := New(&Config{
+		UpdatesURL: srv.URL,
+		Fallbacks:  []Endpoint{{UpdatesURL: fallback.URL}},
+		Retry:      RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	}, l)
+	err = s.SendBatchMetrics(context.Background(), []*model.Metric{model.NewMetricCounter("c", 1)})
+	require.ErrorIs(t, err, ErrRejected)
+	require.ErrorContains(t, err, "unexpected status code received: 400")
+	assert.EqualValues(t, 1, calls.Load(), "the rejected request is not retried")
+	assert.Zero(t, fallbackCalls.Load(), "the rejected request is not sent to the next server")
+}
+
+func TestSender_doRetry_PartiallyStored(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+	s := New(&Config{UpdatesURL: srv.URL, Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}}, l)
+	err = s.SendBatchMetrics(context.Background(), []*model.Metric{model.NewMetricCounter("c", 1)})
+	require.ErrorIs(t, err, ErrRejected)
+	require.ErrorIs(t, err, ErrPartiallyStored)
+	assert.EqualValues(t, 1, calls.Load(), "the partially stored batch is not retried")
+}
+
+func TestSender_doRetry_ContextDone(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	s := New(&Config{UpdatesURL: srv.URL, Retry: RetryPolicy{MaxRetries: 1, BaseDelay: time.Hour, MaxDelay: time.Hour}}, l)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, s.SendBatchMetrics(ctx, nil))
+	assert.Less(t, time.Since(start), time.Minute, "the delay is not awaited after the context is done")
+}
+
+// countingRecorder counts the retries and records the breaker states
+type countingRecorder struct {
+	states  []int
+	retries atomic.Int32
+	mu      sync.Mutex
+}
+
+func (r *countingRecorder) RecordSend(int, time.Duration, error) {}
+
+func (r *countingRecorder) RecordRetry() {
+	r.retries.Add(1)
+}
+
+func (r *countingRecorder) RecordBreakerState(_ string, state int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, state)
+}
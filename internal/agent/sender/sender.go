@@ -3,55 +3,251 @@ package sender
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
+// Mode is the mode of sending the metrics to several servers.
+type Mode string
+
+const (
+	// ModeFailover sends to one server and tries the next one when it fails.
+	ModeFailover Mode = "failover"
+	// ModeFanout delivers every batch to all servers.
+	ModeFanout Mode = "fanout"
+)
+
+// ParseMode returns the mode by name, the empty name is ModeFailover.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return ModeFailover, nil
+	case ModeFailover, ModeFanout:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown send mode %q", s)
+}
+
+// Endpoint contains the URLs of a server and its address used by the gRPC transport.
+type Endpoint struct {
+	UpdateURL  string
+	UpdatesURL string
+	MetaURL    string
+	Addr       string
+}
+
+// NewEndpoint returns the URLs of the server with the address host:port.
+func NewEndpoint(addr string) Endpoint {
+	baseURL := "http://" + addr
+	return Endpoint{
+		UpdateURL:  baseURL + "/update/",
+		UpdatesURL: baseURL + "/updates/",
+		MetaURL:    baseURL + "/meta/",
+		Addr:       addr,
+	}
+}
+
 // Config contains the configuration for the sender.
+//
+// The requests are sent to the server of the URLs, if the server fails after the retries,
+// the Fallbacks are tried in order and the sender sticks to the server which succeeds.
+// If the sends keep failing, the sender fails fast while the circuit breaker is open.
+// With TransportGRPC the metrics are sent to the gRPC server of Addr instead of the URLs.
 type Config struct {
-	UpdateURL   string
-	UpdatesURL  string
-	RetryDelays []time.Duration
-	Key         []byte
-	Timeout     time.Duration
-	RateLimit   int
+	UpdateURL  string
+	UpdatesURL string
+	MetaURL    string
+	Addr       string
+	Transport  Transport
+	Fallbacks  []Endpoint
+	Key        []byte
+	Retry      RetryPolicy
+	Breaker    BreakerConfig
+	Timeout    time.Duration
+	RateLimit  int
+	Protobuf   bool
+}
+
+// Recorder records the telemetry of the sender.
+type Recorder interface {
+	// RecordSend records the send of the metrics which took d and failed with err if it is not nil.
+	RecordSend(metrics int, d time.Duration, err error)
+	// RecordRetry records the retry of the request.
+	RecordRetry()
+	// RecordBreakerState records the state of the circuit breaker of the server: 0 - closed, 1 - half-open, 2 - open.
+	RecordBreakerState(server string, state int)
+}
+
+// nopRecorder is the recorder used if no recorder is set.
+type nopRecorder struct{}
+
+func (nopRecorder) RecordSend(int, time.Duration, error) {}
+
+func (nopRecorder) RecordRetry() {}
+
+func (nopRecorder) RecordBreakerState(string, int) {}
+
+// Endpoints returns the server of the URLs followed by the fallbacks.
+func (c *Config) Endpoints() []Endpoint {
+	return append([]Endpoint{{UpdateURL: c.UpdateURL, UpdatesURL: c.UpdatesURL, MetaURL: c.MetaURL, Addr: c.Addr}}, c.Fallbacks...)
 }
 
 // Sender sends metrics to the server.
 type Sender struct {
-	cfg    *Config
-	l      *logging.ZapLogger
-	client *http.Client
+	rec       Recorder
+	cfg       *Config
+	l         *logging.ZapLogger
+	client    *http.Client
+	breaker   *breaker
+	grpcErr   error
+	server    string
+	key       atomic.Pointer[[]byte]
+	endpoints []Endpoint
+	conns     []*grpc.ClientConn
+	current   atomic.Int64
 }
 
-// New creates a new sender.
+// New creates a new sender, the connections of the gRPC transport are established on the first send.
 func New(cfg *Config, l *logging.ZapLogger) *Sender {
-	return &Sender{cfg: cfg, l: l, client: &http.Client{
+	s := &Sender{rec: nopRecorder{}, cfg: cfg, l: l, endpoints: cfg.Endpoints(), client: &http.Client{
 		Timeout: cfg.Timeout,
 	}}
+	s.server = cfg.UpdatesURL
+	if u, err := url.Parse(cfg.UpdatesURL); err == nil && u.Host != "" {
+		s.server = u.Host
+	}
+	if cfg.Transport == TransportGRPC {
+		s.server = cfg.Addr
+		s.grpcErr = s.newConns()
+	}
+	s.breaker = newBreaker(cfg.Breaker, s.breakerChanged)
+	s.SetKey(cfg.Key)
+	return s
+}
+
+// Close closes the gRPC connections and the idle HTTP connections.
+func (s *Sender) Close() error {
+	s.client.CloseIdleConnections()
+	var errs []error
+	for _, conn := range s.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close connection: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// breakerChanged logs and records the state change of the circuit breaker.
+func (s *Sender) breakerChanged(from, to BreakerState) {
+	s.l.WarnCtx(context.Background(), "circuit breaker state changed",
+		zap.String("server", s.server), zap.Stringer("from", from), zap.Stringer("to", to))
+	s.rec.RecordBreakerState(s.server, int(to))
+}
+
+// SetKey changes the key signing the requests.
+func (s *Sender) SetKey(key []byte) {
+	s.key.Store(&key)
+}
+
+// Current returns the index of the current server in Endpoints, the requests are sent to it first.
+func (s *Sender) Current() int {
+	return int(s.current.Load())
+}
+
+// SetRecorder sets the recorder of the sends, the retries and the breaker states, it must be called before sending.
+func (s *Sender) SetRecorder(rec Recorder) {
+	s.rec = rec
+	if s.cfg.Breaker.FailureThreshold > 0 {
+		rec.RecordBreakerState(s.server, int(BreakerClosed))
+	}
 }
 
 // SendMetric sends a metric to the server.
-func (s *Sender) SendMetric(ctx context.Context, m *model.Metric) error {
-	if err := s.postData(ctx, s.cfg.UpdateURL, m); err != nil {
+func (s *Sender) SendMetric(ctx context.Context, m *model.Metric) (err error) {
+	defer s.recordSend(1, time.Now(), &err)
+	if s.cfg.Transport == TransportGRPC {
+		if err := s.updateGRPC(ctx, m); err != nil {
+			return fmt.Errorf("failed to send metric: %w", err)
+		}
+		return nil
+	}
+	if s.cfg.Protobuf {
+		p, err := m.ToProto()
+		if err == nil {
+			err = s.postProto(ctx, updateURL, p)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send metric: %w", err)
+		}
+		return nil
+	}
+	if err := s.postData(ctx, updateURL, m); err != nil {
 		return fmt.Errorf("failed to send metric: %w", err)
 	}
 	return nil
 }
 
 // SendBatchMetrics sends a batch of metrics to the server.
-func (s *Sender) SendBatchMetrics(ctx context.Context, ms []*model.Metric) error {
-	if err := s.postData(ctx, s.cfg.UpdatesURL, ms); err != nil {
+func (s *Sender) SendBatchMetrics(ctx context.Context, ms []*model.Metric) (err error) {
+	defer s.recordSend(len(ms), time.Now(), &err)
+	if s.cfg.Transport == TransportGRPC {
+		if err := s.updateBatchGRPC(ctx, ms); err != nil {
+			return fmt.Errorf("failed to send metric: %w", err)
+		}
+		return nil
+	}
+	if s.cfg.Protobuf {
+		batch, err := model.MetricsToProto(ms)
+		if err == nil {
+			err = s.postProto(ctx, updatesURL, batch)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send metric: %w", err)
+		}
+		return nil
+	}
+	if err := s.postData(ctx, updatesURL, ms); err != nil {
 		return fmt.Errorf("failed to send metric: %w", err)
 	}
 	return nil
 }
 
+// ErrMetaNotSupported is returned by SendMeta with the gRPC transport, the gRPC service has no metadata.
+var ErrMetaNotSupported = errors.New("metadata is not supported by the gRPC transport")
+
+// SupportsMeta returns true if the metadata can be sent with the transport of the sender.
+func (s *Sender) SupportsMeta() bool {
+	return s.cfg.Transport != TransportGRPC
+}
+
+// SendMeta sends the metrics metadata to the server.
+//
+// ErrMetaNotSupported is returned with the gRPC transport, nothing is sent.
+func (s *Sender) SendMeta(ctx context.Context, metas []*model.Meta) error {
+	if !s.SupportsMeta() {
+		return ErrMetaNotSupported
+	}
+	if err := s.sendData(ctx, http.MethodPut, metaURL, metas); err != nil {
+		return fmt.Errorf("failed to send meta: %w", err)
+	}
+	return nil
+}
+
+// recordSend records the send of the metrics started at start and failed with *err if it is not nil.
+func (s *Sender) recordSend(metrics int, start time.Time, err *error) {
+	s.rec.RecordSend(metrics, time.Since(start), *err)
+}
+
 // JobResult contains the result of a job.
 type JobResult struct {
 	*model.Metric
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
+)
+
+// GaugeAggregation defines how the gauges polled over the report window are reported
+type GaugeAggregation string
+
+const (
+	// GaugeAggregationNone reports the last polled value of the gauge only
+	GaugeAggregationNone GaugeAggregation = "none"
+	// GaugeAggregationDerived reports the minimum, maximum, average and the number of the polled values
+	// as the gauges ID.min, ID.max, ID.avg and ID.count besides the last value
+	GaugeAggregationDerived GaugeAggregation = "derived"
+	// GaugeAggregationSummary reports the quantiles and the number of the polled values as the gauges
+	// ID.p50, ID.p90, ID.p99 and ID.count besides the last value. The quantiles are estimated
+	// over the report window by the agent, the server replaces the gauges, so they are not merged
+	// over the windows as the summaries are
+	GaugeAggregationSummary GaugeAggregation = "summary"
+)
+
+// derived gauge suffixes
+const (
+	suffixMin   = ".min"
+	suffixMax   = ".max"
+	suffixAvg   = ".avg"
+	suffixCount = ".count"
+)
+
+// windowQuantile is the quantile of the polled values reported as the gauge ID+suffix by the summary aggregation
+type windowQuantile struct {
+	suffix string
+	help   string
+	q      float64
+}
+
+// windowQuantiles are the quantiles reported by the summary aggregation
+var windowQuantiles = []windowQuantile{
+	{suffix: ".p50", help: "Median over the report window: ", q: 0.5},
+	{suffix: ".p90", help: "90th percentile over the report window: ", q: 0.9},
+	{suffix: ".p99", help: "99th percentile over the report window: ", q: 0.99},
+}
+
+// ParseGaugeAggregation returns the gauge aggregation by its name, the empty name is GaugeAggregationNone
+func ParseGaugeAggregation(s string) (GaugeAggregation, error) {
+	switch a := GaugeAggregation(s); a {
+	case "":
+		return GaugeAggregationNone, nil
+	case GaugeAggregationNone, GaugeAggregationDerived, GaugeAggregationSummary:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown gauge aggregation %q", s)
+}
+
+// gaugeWindow is the aggregate of the values of the gauge polled over the report window
+type gaugeWindow struct {
+	gauge  *model.Metric
+	digest *tdigest.TDigest
+	min    float64
+	max    float64
+	sum    float64
+	count  int64
+}
+
+// newGaugeWindow returns the window with the single value of the gauge,
+// the digest of the values is kept for the summary aggregation
+func newGaugeWindow(m *model.Metric, mode GaugeAggregation) *gaugeWindow {
+	w := &gaugeWindow{gauge: m, min: *m.Value, max: *m.Value, sum: *m.Value, count: 1}
+	if mode == GaugeAggregationSummary {
+		w.digest = tdigest.New(tdigest.DefaultCompression)
+		w.digest.Add(*m.Value)
+	}
+	return w
+}
+
+// merge adds the values of the later window to the window
+func (w *gaugeWindow) merge(o *gaugeWindow) {
+	w.gauge = o.gauge
+	w.min = min(w.min, o.min)
+	w.max = max(w.max, o.max)
+	w.sum += o.sum
+	w.count += o.count
+	if w.digest != nil && o.digest != nil {
+		w.digest.Merge(o.digest)
+	}
+}
+
+// metrics returns the aggregates of the window as the metrics labeled and stamped like the last value
+func (w *gaugeWindow) metrics() []*model.Metric {
+	if w.digest != nil {
+		ms := make([]*model.Metric, 0, len(windowQuantiles)+1)
+		for _, wq := range windowQuantiles {
+			ms = append(ms, w.derived(model.NewMetricGauge(w.gauge.ID+wq.suffix, w.digest.Quantile(wq.q))))
+		}
+		return append(ms, w.derived(model.NewMetricGauge(w.gauge.ID+suffixCount, float64(w.count))))
+	}
+	return []*model.Metric{
+		w.derived(model.NewMetricGauge(w.gauge.ID+suffixMin, w.min)),
+		w.derived(model.NewMetricGauge(w.gauge.ID+suffixMax, w.max)),
+		w.derived(model.NewMetricGauge(w.gauge.ID+suffixAvg, w.sum/float64(w.count))),
+		w.derived(model.NewMetricGauge(w.gauge.ID+suffixCount, float64(w.count))),
+	}
+}
+
+// derived sets the labels and the timestamp of the last value to the derived metric
+func (w *gaugeWindow) derived(m *model.Metric) *model.Metric {
+	m.Labels = w.gauge.Labels.Clone()
+	m.Timestamp = w.gauge.Timestamp
+	return m
+}
+
+// gaugeWindows are the windows of the gauges by key
+type gaugeWindows map[string]*gaugeWindow
+
+// observe adds the polled gauges to the windows
+func (ws gaugeWindows) observe(data []*model.Metric, mode GaugeAggregation) {
+	for _, m := range data {
+		if m.MType != model.TypeGauge || m.Value == nil {
+			continue
+		}
+		w := newGaugeWindow(m, mode)
+		if old, ok := ws[m.Key()]; ok {
+			old.merge(w)
+			continue
+		}
+		ws[m.Key()] = w
+	}
+}
+
+// windowMeta returns the metadata of the aggregates of the gauge by the metadata of the gauge
+func windowMeta(base *model.Meta, mode GaugeAggregation) []*model.Meta {
+	count := model.NewMeta(model.TypeGauge, base.ID+suffixCount, "", "Number of polls over the report window: "+base.Help)
+	if mode == GaugeAggregationSummary {
+		metas := make([]*model.Meta, 0, len(windowQuantiles)+1)
+		for _, wq := range windowQuantiles {
+			metas = append(metas, model.NewMeta(model.TypeGauge, base.ID+wq.suffix, base.Unit, wq.help+base.Help))
+		}
+		return append(metas, count)
+	}
+	return []*model.Meta{
+		model.NewMeta(model.TypeGauge, base.ID+suffixMin, base.Unit, "Minimum over the report window: "+base.Help),
+		model.NewMeta(model.TypeGauge, base.ID+suffixMax, base.Unit, "Maximum over the report window: "+base.Help),
+		model.NewMeta(model.TypeGauge, base.ID+suffixAvg, base.Unit, "Average over the report window: "+base.Help),
+		count,
+	}
+}
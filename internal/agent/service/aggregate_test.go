@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGaugeAggregation(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    GaugeAggregation
+		wantErr bool
+	}{
+		{name: "empty", s: "", want: GaugeAggregationNone},
+		{name: "none", s: "none", want: GaugeAggregationNone},
+		{name: "derived", s: "derived", want: GaugeAggregationDerived},
+		{name: "summary", s: "summary", want: GaugeAggregationSummary},
+		{name: "unknown", s: "avg", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGaugeAggregation(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// pollGauges collects the gauge "g" with the label and the given values one by one
+func pollGauges(t *testing.T, s *Source, c *stubCollector, values ...float64) {
+	t.Helper()
+	for _, v := range values {
+		m := model.NewMetricGauge("g", v)
+		m.Labels = model.Labels{"host": "a"}
+		c.data = []*model.Metric{m}
+		require.NoError(t, s.Collect(context.TODO()))
+	}
+}
+
+func TestSource_GaugeAggregation_Derived(t *testing.T) {
+	c := &stubCollector{name: "stub"}
+	s := NewSource(c)
+	s.SetGaugeAggregation(GaugeAggregationDerived)
+	pollGauges(t, s, c, 3, 9, 1, 5)
+
+	data, delta := s.Get()
+	got := keyed(data)
+	assert.Equal(t, map[string]string{
+		`gauge:g{host="a"}`:       "5",
+		`gauge:g.min{host="a"}`:   "1",
+		`gauge:g.max{host="a"}`:   "9",
+		`gauge:g.avg{host="a"}`:   "4.5",
+		`gauge:g.count{host="a"}`: "4",
+		"counter:PollCount":       "4",
+	}, got)
+
+	pollGauges(t, s, c, 20)
+	data, _ = s.Get()
+	got = keyed(data)
+	assert.Equal(t, "20", got[`gauge:g.max{host="a"}`], "the uncommitted window is reported again")
+	assert.Equal(t, "5", got[`gauge:g.count{host="a"}`])
+
+	s.Commit(delta)
+	pollGauges(t, s, c, 2)
+	data, _ = s.Get()
+	got = keyed(data)
+	assert.Equal(t, "2", got[`gauge:g.min{host="a"}`], "the window is reset by Commit")
+	assert.Equal(t, "1", got[`gauge:g.count{host="a"}`])
+}
+
+func TestSource_GaugeAggregation_Summary(t *testing.T) {
+	c := &stubCollector{name: "stub"}
+	s := NewSource(c)
+	s.SetGaugeAggregation(GaugeAggregationSummary)
+	pollGauges(t, s, c, 3, 9, 1)
+
+	data, delta := s.Get()
+	got := keyed(data)
+	assert.Equal(t, "3", got[`gauge:g.p50{host="a"}`])
+	assert.Equal(t, "9", got[`gauge:g.p99{host="a"}`])
+	assert.Equal(t, "3", got[`gauge:g.count{host="a"}`])
+	assert.Len(t, data, 6)
+	for _, m := range data {
+		assert.NotEqual(t, model.TypeSummary, m.MType, "the quantiles are reported as gauges, so they are not merged on the server")
+	}
+
+	s.Commit(delta)
+	data, _ = s.Get()
+	assert.Len(t, data, 2, "no polls since Commit")
+
+	pollGauges(t, s, c, 20)
+	data, _ = s.Get()
+	got = keyed(data)
+	assert.Equal(t, "20", got[`gauge:g.p50{host="a"}`], "the quantiles are over the window since Commit")
+	assert.Equal(t, "1", got[`gauge:g.count{host="a"}`])
+}
+
+func TestSource_GaugeAggregation_None(t *testing.T) {
+	for _, mode := range []GaugeAggregation{GaugeAggregationNone, ""} {
+		c := &stubCollector{name: "stub"}
+		s := NewSource(c)
+		s.SetGaugeAggregation(mode)
+		pollGauges(t, s, c, 3, 9)
+		data, _ := s.Get()
+		assert.Len(t, data, 2, mode)
+	}
+}
+
+func TestSource_Meta_GaugeAggregation(t *testing.T) {
+	s := NewSource(&stubCollector{name: "stub", data: []*model.Metric{model.NewMetricGauge("Alloc", 1)}})
+	s.SetGaugeAggregation(GaugeAggregationDerived)
+	require.NoError(t, s.Collect(context.TODO()))
+	metas := make(map[string]*model.Meta)
+	for _, meta := range s.Meta() {
+		metas[meta.Key()] = meta
+	}
+	require.Contains(t, metas, model.TypeGauge+":Alloc.max")
+	assert.Equal(t, unitBytes, metas[model.TypeGauge+":Alloc.max"].Unit)
+	require.Contains(t, metas, model.TypeGauge+":Alloc.count")
+	assert.Empty(t, metas[model.TypeGauge+":Alloc.count"].Unit)
+
+	s.SetGaugeAggregation(GaugeAggregationSummary)
+	metas = make(map[string]*model.Meta)
+	for _, meta := range s.Meta() {
+		metas[meta.Key()] = meta
+	}
+	require.Contains(t, metas, model.TypeGauge+":Alloc.p99")
+	assert.Equal(t, unitBytes, metas[model.TypeGauge+":Alloc.p99"].Unit)
+	assert.Contains(t, metas, model.TypeGauge+":Alloc.count")
+	assert.NotContains(t, metas, model.TypeGauge+":Alloc.max")
+}
+
+func TestSource_GaugeAggregation_CommitSent(t *testing.T) {
+	c := &stubCollector{name: "stub"}
+	s := NewSource(c)
+	s.SetGaugeAggregation(GaugeAggregationDerived)
+	pollGauges(t, s, c, 3, 9)
+
+	data, _ := s.Get()
+	var sent []*model.Metric
+	for _, m := range data {
+		if m.ID != "g"+suffixMax {
+			sent = append(sent, m)
+		}
+	}
+	s.CommitSent(sent)
+	pollGauges(t, s, c, 5)
+	data, _ = s.Get()
+	got := keyed(data)
+	assert.Equal(t, "9", got[`gauge:g.max{host="a"}`], "the window is kept until all its aggregates are sent")
+	assert.Equal(t, "3", got[`gauge:g.count{host="a"}`])
+
+	s.CommitSent(data)
+	pollGauges(t, s, c, 1)
+	data, _ = s.Get()
+	assert.Equal(t, "1", keyed(data)[`gauge:g.max{host="a"}`], "the window is reset when all its aggregates are sent")
+}
@@ -22,12 +22,13 @@ func BenchmarkSource_Collect(b *testing.B) {
 
 // BenchmarkSource_Get измеряет производительность метода Get.
 func BenchmarkSource_Get(b *testing.B) {
-	s := NewSource()
 	// Подготовка данных
-	s.data = make([]*model.Metric, 100)
+	data := make([]*model.Metric, 100)
 	for i := 0; i < 100; i++ {
-		s.data[i] = model.NewMetricGauge(fmt.Sprintf("Metric%d", i), float64(i))
+		data[i] = model.NewMetricGauge(fmt.Sprintf("Metric%d", i), float64(i))
 	}
+	s := NewSource(&stubCollector{name: "stub", data: data})
+	assert.NoError(b, s.Collect(b.Context()))
 	*s.pollCount.Delta = 5
 
 	b.ResetTimer()
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+const (
+	// GopsutilCollectorName is the name of the collector of the system memory and CPU metrics
+	GopsutilCollectorName = "gopsutil"
+)
+
+// DefaultCollectors are the names of the collectors enabled by default, the net collector is not enabled,
+// since listing the TCP connections on every poll is expensive on the hosts with many connections
+var DefaultCollectors = []string{RuntimeCollectorName, GopsutilCollectorName, DiskCollectorName}
+
+// CollectorsConfig is the config of the collectors
+type CollectorsConfig struct {
+	Statsd  StatsdConfig
+	Process ProcessConfig
+	Disk    DiskConfig
+	Net     NetConfig
+	Exec    ExecConfig
+	Runtime RuntimeConfig
+}
+
+// Collector collects a group of metrics
+type Collector interface {
+	// Name returns the name of the collector, it is used to enable the collector in the agent config
+	Name() string
+	// Collect returns the collected metrics, the metrics returned with the error are the partial result
+	// which replaces the previous one, without them the previous result is kept
+	Collect(ctx context.Context) ([]*model.Metric, error)
+	// Interval returns the minimum interval between collections, 0 - on every poll
+	Interval() time.Duration
+}
+
+// collectorFactory returns the collector with the given interval and config
+type collectorFactory func(interval time.Duration, cfg *CollectorsConfig) (Collector, error)
+
+// collectorFactories are the collectors available in the agent config by name
+var collectorFactories = map[string]collectorFactory{
+	RuntimeCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		return NewRuntimeCollector(interval, cfg.Runtime), nil
+	},
+	GopsutilCollectorName: func(interval time.Duration, _ *CollectorsConfig) (Collector, error) {
+		return NewGopsutilCollector(interval), nil
+	},
+	DiskCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		if err := cfg.Disk.Validate(); err != nil {
+			return nil, err
+		}
+		return NewDiskCollector(interval, cfg.Disk), nil
+	},
+	NetCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		if err := cfg.Net.Validate(); err != nil {
+			return nil, err
+		}
+		return NewNetCollector(interval, cfg.Net), nil
+	},
+	ProcessCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		if err := cfg.Process.Validate(); err != nil {
+			return nil, err
+		}
+		return NewProcessCollector(interval, cfg.Process), nil
+	},
+	ExecCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		if err := cfg.Exec.Validate(); err != nil {
+			return nil, err
+		}
+		return NewExecCollector(interval, cfg.Exec), nil
+	},
+	StatsdCollectorName: func(interval time.Duration, cfg *CollectorsConfig) (Collector, error) {
+		if err := cfg.Statsd.Validate(); err != nil {
+			return nil, err
+		}
+		return NewStatsdCollector(interval, cfg.Statsd)
+	},
+}
+
+// NewCollectors returns the collectors by the specs "name" or "name:interval", e.g. "gopsutil:10s".
+//
+// The collectors without the interval in the spec use the given interval.
+func NewCollectors(specs []string, interval time.Duration, cfg *CollectorsConfig) ([]Collector, error) {
+	collectors := make([]Collector, 0, len(specs))
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		name, rawInterval, hasInterval := strings.Cut(strings.TrimSpace(spec), ":")
+		factory, ok := collectorFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate collector %q", name)
+		}
+		seen[name] = true
+		ci := interval
+		if hasInterval {
+			var err error
+			if ci, err = time.ParseDuration(rawInterval); err != nil {
+				return nil, fmt.Errorf("invalid interval of collector %q: %w", name, err)
+			}
+			if ci < 0 {
+				return nil, fmt.Errorf("invalid interval of collector %q: must not be negative", name)
+			}
+		}
+		c, err := factory(ci, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create collector %q: %w", name, err)
+		}
+		collectors = append(collectors, c)
+	}
+	if len(collectors) == 0 {
+		return nil, errors.New("no collectors")
+	}
+	return collectors, nil
+}
+
+// gopsutilCollector collects the system memory and CPU metrics
+type gopsutilCollector struct {
+	interval time.Duration
+}
+
+// NewGopsutilCollector returns the collector of the system memory and CPU metrics
+func NewGopsutilCollector(interval time.Duration) Collector {
+	return &gopsutilCollector{interval: interval}
+}
+
+// Name returns the name of the collector
+func (c *gopsutilCollector) Name() string {
+	return GopsutilCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *gopsutilCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect returns the system memory and CPU metrics
+func (c *gopsutilCollector) Collect(ctx context.Context) ([]*model.Metric, error) {
+	var data []*model.Metric
+	for m := range genGopsutilMetrics(ctx) {
+		if m.err != nil {
+			return nil, m.err
+		}
+		data = append(data, m.m)
+	}
+	return data, nil
+}
+
+// validatePatterns returns an error if a pattern is malformed, the patterns are matched with path.Match
+func validatePatterns(patterns ...[]string) error {
+	for _, list := range patterns {
+		for _, pattern := range list {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchPatterns returns true if the name matches an include pattern or there are none,
+// and the name matches no exclude pattern
+func matchPatterns(include, exclude []string, name string) bool {
+	included := len(include) == 0
+	for _, pattern := range include {
+		if ok, _ := path.Match(pattern, name); ok {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newLabeledGauge returns the gauge with the given labels
+func newLabeledGauge(id string, labels model.Labels, v uint64) *model.Metric {
+	m := model.NewMetricGauge(id, float64(v))
+	m.Labels = labels.Clone()
+	return m
+}
+
+// newCounterDelta returns the counter with the delta of the given cumulative values,
+// the delta is the current value if the counter was reset
+func newCounterDelta(id string, labels model.Labels, cur, prev uint64) *model.Metric {
+	delta := cur
+	if cur >= prev {
+		delta = cur - prev
+	}
+	m := model.NewMetricCounter(id, int64(delta)) //nolint:gosec // ignore
+	m.Labels = labels.Clone()
+	return m
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCollectors(t *testing.T) {
+	tests := []struct {
+		name          string
+		specs         []string
+		wantNames     []string
+		wantIntervals []time.Duration
+		cfg           CollectorsConfig
+		wantErr       bool
+	}{
+		{
+			name:          "default",
+			specs:         DefaultCollectors,
+			wantNames:     []string{RuntimeCollectorName, GopsutilCollectorName, DiskCollectorName},
+			wantIntervals: []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:          "interval",
+			specs:         []string{" gopsutil:10s", "runtime"},
+			wantNames:     []string{GopsutilCollectorName, RuntimeCollectorName},
+			wantIntervals: []time.Duration{10 * time.Second, time.Second},
+		},
+		{name: "unknown", specs: []string{"unknown"}, wantErr: true},
+		{name: "duplicate", specs: []string{"runtime", "runtime:5s"}, wantErr: true},
+		{name: "invalid interval", specs: []string{"runtime:5"}, wantErr: true},
+		{name: "negative interval", specs: []string{"runtime:-5s"}, wantErr: true},
+		{name: "empty", specs: nil, wantErr: true},
+		{
+			name:    "invalid disk pattern",
+			specs:   []string{"disk"},
+			cfg:     CollectorsConfig{Disk: DiskConfig{Exclude: []string{"/mnt/["}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid net pattern",
+			specs:   []string{"net"},
+			cfg:     CollectorsConfig{Net: NetConfig{Include: []string{"eth["}}},
+			wantErr: true,
+		},
+		{name: "process without selector", specs: []string{"process"}, wantErr: true},
+		{
+			name:          "process",
+			specs:         []string{"process:30s"},
+			cfg:           CollectorsConfig{Process: ProcessConfig{Name: "^nginx$"}},
+			wantNames:     []string{ProcessCollectorName},
+			wantIntervals: []time.Duration{30 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCollectors(tt.specs, time.Second, &tt.cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, got, len(tt.wantNames))
+			for i, c := range got {
+				assert.Equal(t, tt.wantNames[i], c.Name())
+				assert.Equal(t, tt.wantIntervals[i], c.Interval())
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// DiskCollectorName is the name of the collector of the filesystem usage and disk I/O metrics
+const DiskCollectorName = "disk"
+
+// DiskConfig is the config of the disk collector
+type DiskConfig struct {
+	// Include are the mount point patterns of the reported filesystems, all filesystems are reported if it is empty
+	Include []string
+	// Exclude are the mount point patterns of the filesystems which are not reported
+	Exclude []string
+}
+
+// Validate returns an error if a pattern is malformed
+func (c *DiskConfig) Validate() error {
+	return validatePatterns(c.Include, c.Exclude)
+}
+
+// matchMount returns true if the mount point is included and is not excluded
+func (c *DiskConfig) matchMount(mountpoint string) bool {
+	return matchPatterns(c.Include, c.Exclude, mountpoint)
+}
+
+// diskCollector collects the usage of the mounted filesystems and the I/O counters of the disks.
+//
+// The usage is reported with gauges labeled with the mount point, the I/O counters are reported
+// with counter deltas since the previous collection labeled with the device.
+type diskCollector struct {
+	partitions func(ctx context.Context, all bool) ([]disk.PartitionStat, error)
+	usage      func(ctx context.Context, path string) (*disk.UsageStat, error)
+	ioCounters func(ctx context.Context, names ...string) (map[string]disk.IOCountersStat, error)
+	prev       map[string]disk.IOCountersStat
+	cfg        DiskConfig
+	interval   time.Duration
+	mu         sync.Mutex
+}
+
+// NewDiskCollector returns the collector of the filesystem usage and disk I/O metrics
+func NewDiskCollector(interval time.Duration, cfg DiskConfig) Collector {
+	return &diskCollector{
+		partitions: disk.PartitionsWithContext,
+		usage:      disk.UsageWithContext,
+		ioCounters: disk.IOCountersWithContext,
+		cfg:        cfg,
+		interval:   interval,
+	}
+}
+
+// Name returns the name of the collector
+func (c *diskCollector) Name() string {
+	return DiskCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *diskCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect returns the filesystem usage and disk I/O metrics
+//
+// The first collection only remembers the I/O counters, the deltas are reported starting from the second one.
+func (c *diskCollector) Collect(ctx context.Context) ([]*model.Metric, error) {
+	data, err := c.collectUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counters, err := c.ioCounters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk I/O counters: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prev != nil {
+		for name, cur := range counters {
+			prev, ok := c.prev[name]
+			if !ok {
+				continue
+			}
+			labels := model.Labels{"device": name}
+			data = append(data,
+				newCounterDelta("DiskReadBytes", labels, cur.ReadBytes, prev.ReadBytes),
+				newCounterDelta("DiskWriteBytes", labels, cur.WriteBytes, prev.WriteBytes),
+				newCounterDelta("DiskReadCount", labels, cur.ReadCount, prev.ReadCount),
+				newCounterDelta("DiskWriteCount", labels, cur.WriteCount, prev.WriteCount),
+			)
+		}
+	}
+	c.prev = counters
+	return data, nil
+}
+
+// collectUsage returns the usage gauges of the matching filesystems,
+// the filesystems which can not be stat, e.g. because of permissions, are skipped
+func (c *diskCollector) collectUsage(ctx context.Context) ([]*model.Metric, error) {
+	partitions, err := c.partitions(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get partitions: %w", err)
+	}
+	var data []*model.Metric
+	seen := make(map[string]bool, len(partitions))
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || !c.cfg.matchMount(p.Mountpoint) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+		u, err := c.usage(ctx, p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		labels := model.Labels{"mount": p.Mountpoint}
+		data = append(data,
+			newLabeledGauge("DiskUsed", labels, u.Used),
+			newLabeledGauge("DiskFree", labels, u.Free),
+			newLabeledGauge("DiskInodesUsed", labels, u.InodesUsed),
+			newLabeledGauge("DiskInodesFree", labels, u.InodesFree),
+		)
+	}
+	return data, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskConfig_matchMount(t *testing.T) {
+	tests := []struct {
+		name       string
+		mountpoint string
+		cfg        DiskConfig
+		want       bool
+	}{
+		{name: "no patterns", mountpoint: "/", want: true},
+		{name: "included", cfg: DiskConfig{Include: []string{"/", "/data*"}}, mountpoint: "/data1", want: true},
+		{name: "not included", cfg: DiskConfig{Include: []string{"/data*"}}, mountpoint: "/", want: false},
+		{name: "excluded", cfg: DiskConfig{Exclude: []string{"/snap/*"}}, mountpoint: "/snap/core", want: false},
+		{
+			name:       "included and excluded",
+			cfg:        DiskConfig{Include: []string{"/data*"}, Exclude: []string{"/data2"}},
+			mountpoint: "/data2",
+			want:       false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cfg.matchMount(tt.mountpoint))
+		})
+	}
+}
+
+// newTestDiskCollector returns the disk collector with the stubbed gopsutil functions
+func newTestDiskCollector(cfg DiskConfig, counters *map[string]disk.IOCountersStat) *diskCollector {
+	c := NewDiskCollector(0, cfg).(*diskCollector)
+	c.partitions = func(context.Context, bool) ([]disk.PartitionStat, error) {
+		return []disk.PartitionStat{
+			{Device: "/dev/sda1", Mountpoint: "/"},
+			{Device: "/dev/sdb1", Mountpoint: "/data"},
+			{Device: "/dev/sdb1", Mountpoint: "/data"},
+			{Device: "/dev/sdc1", Mountpoint: "/secret"},
+		}, nil
+	}
+	c.usage = func(_ context.Context, path string) (*disk.UsageStat, error) {
+		if path == "/secret" {
+			return nil, errors.New("permission denied")
+		}
+		return &disk.UsageStat{Path: path, Used: 10, Free: 20, InodesUsed: 1, InodesFree: 2}, nil
+	}
+	c.ioCounters = func(context.Context, ...string) (map[string]disk.IOCountersStat, error) {
+		return *counters, nil
+	}
+	return c
+}
+
+func TestDiskCollector_Collect(t *testing.T) {
+	counters := map[string]disk.IOCountersStat{
+		"sda": {Name: "sda", ReadBytes: 100, WriteBytes: 200, ReadCount: 1, WriteCount: 2},
+	}
+	c := newTestDiskCollector(DiskConfig{Exclude: []string{"/data"}}, &counters)
+
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Metric{
+		{MType: model.TypeGauge, ID: "DiskUsed", Value: ptr(10.0), Labels: model.Labels{"mount": "/"}},
+		{MType: model.TypeGauge, ID: "DiskFree", Value: ptr(20.0), Labels: model.Labels{"mount": "/"}},
+		{MType: model.TypeGauge, ID: "DiskInodesUsed", Value: ptr(1.0), Labels: model.Labels{"mount": "/"}},
+		{MType: model.TypeGauge, ID: "DiskInodesFree", Value: ptr(2.0), Labels: model.Labels{"mount": "/"}},
+	}, data, "the first collection has no I/O deltas")
+
+	counters = map[string]disk.IOCountersStat{
+		"sda": {Name: "sda", ReadBytes: 150, WriteBytes: 200, ReadCount: 3, WriteCount: 1},
+		"sdb": {Name: "sdb", ReadBytes: 1},
+	}
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, data, 8)
+	labels := model.Labels{"device": "sda"}
+	assert.Equal(t, []*model.Metric{
+		{MType: model.TypeCounter, ID: "DiskReadBytes", Delta: ptr(int64(50)), Labels: labels},
+		{MType: model.TypeCounter, ID: "DiskWriteBytes", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "DiskReadCount", Delta: ptr(int64(2)), Labels: labels},
+		{MType: model.TypeCounter, ID: "DiskWriteCount", Delta: ptr(int64(1)), Labels: labels},
+	}, data[4:], "the reset counter reports its current value")
+}
+
+func TestDiskCollector_Collect_Error(t *testing.T) {
+	counters := map[string]disk.IOCountersStat{}
+	c := newTestDiskCollector(DiskConfig{}, &counters)
+	c.partitions = func(context.Context, bool) ([]disk.PartitionStat, error) {
+		return nil, errors.New("partitions error")
+	}
+	_, err := c.Collect(context.TODO())
+	require.Error(t, err)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
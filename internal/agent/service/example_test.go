@@ -65,10 +65,10 @@ func ExampleSource_Collect() {
 		return
 	}
 
-	// Get metrics to verify collection
+	// Get metrics to verify collection, the number of the runtime metrics depends on the Go version
 	metrics, _ := source.Get()
-	fmt.Printf("Collected %d metrics\n", len(metrics))
-	// Output: Collected 32 metrics
+	fmt.Printf("Collected more than 32 metrics: %v\n", len(metrics) > 32)
+	// Output: Collected more than 32 metrics: true
 }
 
 func ExampleSource_Get() {
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// ExecCollectorName is the name of the collector of the metrics printed by the external commands
+const ExecCollectorName = "exec"
+
+const (
+	// execErrorsID is the id of the counter of the failed runs of the command
+	execErrorsID = "ExecErrors"
+	// maxExecOutput is the maximum size of the command output
+	maxExecOutput = 1 << 20
+	// execWaitDelay is the time given to the command to close its output after it was killed
+	execWaitDelay = time.Second
+)
+
+// ExecConfig is the config of the exec collector
+type ExecConfig struct {
+	// Commands are the command lines, the program and its arguments are separated by spaces
+	Commands []string
+	// Timeout is the maximum run time of a command
+	Timeout time.Duration
+}
+
+// Validate returns an error if there are no commands or the timeout is not positive
+func (c *ExecConfig) Validate() error {
+	if len(c.Commands) == 0 {
+		return errors.New("no commands")
+	}
+	for _, command := range c.Commands {
+		if len(strings.Fields(command)) == 0 {
+			return errors.New("empty command")
+		}
+	}
+	if c.Timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+	return nil
+}
+
+// execCollector runs the commands and collects the metrics printed to stdout.
+//
+// The output is either the JSON array of the metrics as accepted by /updates/ or the lines "name type value",
+// the empty lines and the lines starting with # are skipped. The value of the line is parsed like the value
+// of /update/{type}/{name}/{value}. A command which fails, times out or prints malformed output reports
+// no metrics, the failure is counted by ExecErrors labeled with the command, so the other commands
+// are not affected. The failures are returned with the metrics as the partial result.
+type execCollector struct {
+	run      func(ctx context.Context, args []string) ([]byte, error)
+	commands []string
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// NewExecCollector returns the collector of the metrics printed by the commands, the config must be valid
+func NewExecCollector(interval time.Duration, cfg ExecConfig) Collector {
+	commands := make([]string, len(cfg.Commands))
+	for i, command := range cfg.Commands {
+		commands[i] = strings.TrimSpace(command)
+	}
+	return &execCollector{
+		run:      runCommand,
+		commands: commands,
+		timeout:  cfg.Timeout,
+		interval: interval,
+	}
+}
+
+// Name returns the name of the collector
+func (c *execCollector) Name() string {
+	return ExecCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *execCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect runs the commands concurrently and returns the metrics printed by them and the error counters,
+// the errors of the failed commands are joined
+func (c *execCollector) Collect(ctx context.Context) ([]*model.Metric, error) {
+	results := make([][]*model.Metric, len(c.commands))
+	errs := make([]error, len(c.commands))
+	var wg sync.WaitGroup
+	for i, command := range c.commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var failures int64
+			data, err := c.collectCommand(ctx, command)
+			if err != nil {
+				data, failures = nil, 1
+				errs[i] = fmt.Errorf("command %q: %w", command, err)
+			}
+			errorsCounter := model.NewMetricCounter(execErrorsID, failures)
+			errorsCounter.Labels = model.Labels{"command": command}
+			results[i] = append(data, errorsCounter)
+		}()
+	}
+	wg.Wait()
+	var data []*model.Metric
+	for _, r := range results {
+		data = append(data, r...)
+	}
+	return data, errors.Join(errs...)
+}
+
+// collectCommand runs the command with the timeout and parses its output
+func (c *execCollector) collectCommand(ctx context.Context, command string) ([]*model.Metric, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	out, err := c.run(ctx, strings.Fields(command))
+	if err != nil {
+		return nil, err
+	}
+	return parseExecOutput(out)
+}
+
+// runCommand runs the command and returns its stdout, the output larger than maxExecOutput is an error
+func runCommand(ctx context.Context, args []string) ([]byte, error) {
+	//nolint:gosec // the commands come from the agent config
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.WaitDelay = execWaitDelay
+	stdout := &limitedBuffer{limit: maxExecOutput}
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to run %q: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("failed to run %q: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer is the buffer failing the write beyond the limit,
+// bytes.Buffer is not embedded, so its ReadFrom can not bypass the limit
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+// Write appends the data to the buffer, an error is returned if the limit is exceeded
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.limit)
+	}
+	//nolint:wrapcheck // ignore
+	return b.buf.Write(p)
+}
+
+// Bytes returns the written data
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// parseExecOutput parses the JSON array of the metrics or the lines "name type value"
+func parseExecOutput(out []byte) ([]*model.Metric, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if out[0] == '[' {
+		return parseExecJSON(out)
+	}
+	return parseExecLines(out)
+}
+
+// parseExecJSON parses the JSON array of the metrics
+func parseExecJSON(out []byte) ([]*model.Metric, error) {
+	var mrs []*model.MetricRequest
+	if err := json.Unmarshal(out, &mrs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal output: %w", err)
+	}
+	data := make([]*model.Metric, 0, len(mrs))
+	for i, mr := range mrs {
+		if mr == nil || mr.Metric == nil || mr.ID == "" {
+			return nil, fmt.Errorf("metric %d: %w", i, model.ErrMetricNotFound)
+		}
+		if err := mr.RequiredValue(); err != nil {
+			return nil, fmt.Errorf("metric %d: %w", i, err)
+		}
+		data = append(data, mr.Metric)
+	}
+	return data, nil
+}
+
+// parseExecLines parses the lines "name type value"
+func parseExecLines(out []byte) ([]*model.Metric, error) {
+	const lineFields = 3
+	var data []*model.Metric
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != lineFields {
+			return nil, fmt.Errorf("line %d: expected \"name type value\"", n)
+		}
+		mr, err := model.NewMetricRequest(fields[1], fields[0], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		data = append(data, mr.Metric)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read output: %w", err)
+	}
+	return data, nil
+}
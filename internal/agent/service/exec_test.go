@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExecOutput(t *testing.T) {
+	tests := []struct {
+		want    map[string]string
+		name    string
+		out     string
+		wantErr bool
+	}{
+		{
+			name: "lines",
+			out:  "# app stats\nQueueSize gauge 12.5\n\n  Requests counter 7\n",
+			want: map[string]string{"gauge:QueueSize": "12.5", "counter:Requests": "7"},
+		},
+		{
+			name: "json",
+			out:  ` [{"id":"QueueSize","type":"gauge","value":3},{"id":"Requests","type":"counter","delta":2,"labels":{"a":"b"}}]`,
+			want: map[string]string{"gauge:QueueSize": "3", `counter:Requests{a="b"}`: "2"},
+		},
+		{
+			name: "empty",
+			out:  " \n",
+			want: map[string]string{},
+		},
+		{
+			name: "empty json",
+			out:  "[]",
+			want: map[string]string{},
+		},
+		{
+			name:    "invalid line",
+			out:     "QueueSize gauge\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			out:     "Requests counter 1.5\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			out:     "QueueSize meter 1\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid json",
+			out:     `[{"id":"QueueSize","type":"gauge"`,
+			wantErr: true,
+		},
+		{
+			name:    "json without value",
+			out:     `[{"id":"QueueSize","type":"gauge"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "json without id",
+			out:     `[{"type":"gauge","value":1}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := parseExecOutput([]byte(tt.out))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, keyed(data))
+		})
+	}
+}
+
+func TestExecCollector_Collect(t *testing.T) {
+	cfg := ExecConfig{Commands: []string{"ok.sh", " bad.sh -v ", "slow.sh"}, Timeout: 10 * time.Millisecond}
+	require.NoError(t, cfg.Validate())
+	c := NewExecCollector(0, cfg).(*execCollector)
+	c.run = func(ctx context.Context, a []string) ([]byte, error) {
+		switch a[0] {
+		case "ok.sh":
+			return []byte("QueueSize gauge 3\n"), nil
+		case "bad.sh":
+			assert.Equal(t, []string{"bad.sh", "-v"}, a)
+			return []byte("QueueSize"), nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	data, err := c.Collect(t.Context())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), `command "bad.sh -v"`)
+	assert.Contains(t, err.Error(), `command "slow.sh"`)
+	assert.NotContains(t, err.Error(), "ok.sh")
+	assert.Equal(t, map[string]string{
+		"gauge:QueueSize":                         "3",
+		`counter:ExecErrors{command="ok.sh"}`:     "0",
+		`counter:ExecErrors{command="bad.sh -v"}`: "1",
+		`counter:ExecErrors{command="slow.sh"}`:   "1",
+	}, keyed(data))
+}
+
+// keyed returns the values of the metrics by type and key
+func keyed(data []*model.Metric) map[string]string {
+	got := make(map[string]string, len(data))
+	for _, m := range data {
+		got[m.MType+":"+m.Key()] = fmt.Sprint(m.AnyValue())
+	}
+	return got
+}
+
+func TestRunCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	out, err := runCommand(t.Context(), []string{"sh", "-c", "echo 'Up gauge 1'"})
+	require.NoError(t, err)
+	assert.Equal(t, "Up gauge 1\n", string(out))
+
+	_, err = runCommand(t.Context(), []string{"sh", "-c", "echo oops >&2; exit 3"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oops")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = runCommand(ctx, []string{"sh", "-c", "sleep 10"})
+	require.Error(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	_, err = runCommand(t.Context(), []string{"sh", "-c", "head -c 1048577 /dev/zero"})
+	require.Error(t, err)
+
+	_, err = runCommand(t.Context(), []string{"no-such-command-for-exec-test"})
+	var execErr *exec.Error
+	assert.True(t, errors.As(err, &execErr))
+}
+
+func TestExecConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ExecConfig
+		wantErr bool
+	}{
+		{name: "valid", cfg: ExecConfig{Commands: []string{"stats.sh -v"}, Timeout: time.Second}},
+		{name: "no commands", cfg: ExecConfig{Timeout: time.Second}, wantErr: true},
+		{name: "empty command", cfg: ExecConfig{Commands: []string{"stats.sh", " "}, Timeout: time.Second}, wantErr: true},
+		{name: "no timeout", cfg: ExecConfig{Commands: []string{"stats.sh"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr {
+				assert.Error(t, tt.cfg.Validate())
+			} else {
+				assert.NoError(t, tt.cfg.Validate())
+			}
+		})
+	}
+}
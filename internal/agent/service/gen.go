@@ -36,7 +36,7 @@ func genGopsutilMetrics(ctx context.Context) <-chan genResult {
 		}
 		for i, pct := range pcts {
 			out <- genResult{
-				m: model.NewMetricGauge("CPUutilization"+strconv.Itoa(i+1), pct),
+				m: model.NewMetricGauge(cpuUtilizationPrefix+strconv.Itoa(i+1), pct),
 			}
 		}
 	}()
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+const (
+	unitBytes   = "bytes"
+	unitNs      = "ns"
+	unitPercent = "percent"
+	unitRatio   = "ratio"
+	unitSeconds = "seconds"
+)
+
+// cpuUtilizationPrefix is the name prefix of the per CPU utilization metrics
+const cpuUtilizationPrefix = "CPUutilization"
+
+// builtinMeta is the metadata of the metrics produced by the built-in collectors
+var builtinMeta = map[string]*model.Meta{
+	"Alloc":         model.NewMeta(model.TypeGauge, "Alloc", unitBytes, "Bytes of allocated heap objects"),
+	"BuckHashSys":   model.NewMeta(model.TypeGauge, "BuckHashSys", unitBytes, "Bytes of memory in profiling bucket hash tables"),
+	"Frees":         model.NewMeta(model.TypeGauge, "Frees", "", "Cumulative count of heap objects freed"),
+	"GCCPUFraction": model.NewMeta(model.TypeGauge, "GCCPUFraction", unitRatio, "Fraction of available CPU time used by the GC"),
+	"GCSys":         model.NewMeta(model.TypeGauge, "GCSys", unitBytes, "Bytes of memory in garbage collection metadata"),
+	"HeapAlloc":     model.NewMeta(model.TypeGauge, "HeapAlloc", unitBytes, "Bytes of allocated heap objects"),
+	"HeapIdle":      model.NewMeta(model.TypeGauge, "HeapIdle", unitBytes, "Bytes in idle (unused) spans"),
+	"HeapInuse":     model.NewMeta(model.TypeGauge, "HeapInuse", unitBytes, "Bytes in in-use spans"),
+	"HeapObjects":   model.NewMeta(model.TypeGauge, "HeapObjects", "", "Number of allocated heap objects"),
+	"HeapReleased":  model.NewMeta(model.TypeGauge, "HeapReleased", unitBytes, "Bytes of physical memory returned to the OS"),
+	"HeapSys":       model.NewMeta(model.TypeGauge, "HeapSys", unitBytes, "Bytes of heap memory obtained from the OS"),
+	"LastGC":        model.NewMeta(model.TypeGauge, "LastGC", unitNs, "Time the last garbage collection finished, since 1970"),
+	"Lookups":       model.NewMeta(model.TypeGauge, "Lookups", "", "Number of pointer lookups performed by the runtime"),
+	"MCacheInuse":   model.NewMeta(model.TypeGauge, "MCacheInuse", unitBytes, "Bytes of allocated mcache structures"),
+	"MCacheSys":     model.NewMeta(model.TypeGauge, "MCacheSys", unitBytes, "Bytes of memory obtained from the OS for mcache structures"),
+	"MSpanInuse":    model.NewMeta(model.TypeGauge, "MSpanInuse", unitBytes, "Bytes of allocated mspan structures"),
+	"MSpanSys":      model.NewMeta(model.TypeGauge, "MSpanSys", unitBytes, "Bytes of memory obtained from the OS for mspan structures"),
+	"Mallocs":       model.NewMeta(model.TypeGauge, "Mallocs", "", "Cumulative count of heap objects allocated"),
+	"NextGC":        model.NewMeta(model.TypeGauge, "NextGC", unitBytes, "Target heap size of the next GC cycle"),
+	"NumForcedGC":   model.NewMeta(model.TypeGauge, "NumForcedGC", "", "Number of GC cycles forced by the application"),
+	"NumGC":         model.NewMeta(model.TypeGauge, "NumGC", "", "Number of completed GC cycles"),
+	"OtherSys":      model.NewMeta(model.TypeGauge, "OtherSys", unitBytes, "Bytes of memory in miscellaneous off-heap runtime allocations"),
+	"PauseTotalNs":  model.NewMeta(model.TypeGauge, "PauseTotalNs", unitNs, "Cumulative time spent in GC stop-the-world pauses"),
+	"StackInuse":    model.NewMeta(model.TypeGauge, "StackInuse", unitBytes, "Bytes in stack spans"),
+	"StackSys":      model.NewMeta(model.TypeGauge, "StackSys", unitBytes, "Bytes of stack memory obtained from the OS"),
+	"Sys":           model.NewMeta(model.TypeGauge, "Sys", unitBytes, "Total bytes of memory obtained from the OS"),
+	"TotalAlloc":    model.NewMeta(model.TypeGauge, "TotalAlloc", unitBytes, "Cumulative bytes allocated for heap objects"),
+	"RandomValue":   model.NewMeta(model.TypeGauge, "RandomValue", "", "Random value from 0 to 1"),
+	"TotalMemory":   model.NewMeta(model.TypeGauge, "TotalMemory", unitBytes, "Total amount of RAM"),
+	"FreeMemory":    model.NewMeta(model.TypeGauge, "FreeMemory", unitBytes, "Amount of RAM available for programs"),
+	"PollCount":     model.NewMeta(model.TypeCounter, "PollCount", "", "Number of metrics collections"),
+
+	// disk collector
+	"DiskUsed":       model.NewMeta(model.TypeGauge, "DiskUsed", unitBytes, "Bytes used on the filesystem"),
+	"DiskFree":       model.NewMeta(model.TypeGauge, "DiskFree", unitBytes, "Bytes available on the filesystem"),
+	"DiskInodesUsed": model.NewMeta(model.TypeGauge, "DiskInodesUsed", "", "Number of inodes used on the filesystem"),
+	"DiskInodesFree": model.NewMeta(model.TypeGauge, "DiskInodesFree", "", "Number of inodes available on the filesystem"),
+	"DiskReadBytes":  model.NewMeta(model.TypeCounter, "DiskReadBytes", unitBytes, "Bytes read from the disk"),
+	"DiskWriteBytes": model.NewMeta(model.TypeCounter, "DiskWriteBytes", unitBytes, "Bytes written to the disk"),
+	"DiskReadCount":  model.NewMeta(model.TypeCounter, "DiskReadCount", "", "Number of read operations of the disk"),
+	"DiskWriteCount": model.NewMeta(model.TypeCounter, "DiskWriteCount", "", "Number of write operations of the disk"),
+
+	// net collector
+	"NetBytesSent":   model.NewMeta(model.TypeCounter, "NetBytesSent", unitBytes, "Bytes sent by the network interface"),
+	"NetBytesRecv":   model.NewMeta(model.TypeCounter, "NetBytesRecv", unitBytes, "Bytes received by the network interface"),
+	"NetPacketsSent": model.NewMeta(model.TypeCounter, "NetPacketsSent", "", "Packets sent by the network interface"),
+	"NetPacketsRecv": model.NewMeta(model.TypeCounter, "NetPacketsRecv", "", "Packets received by the network interface"),
+	"NetErrIn":       model.NewMeta(model.TypeCounter, "NetErrIn", "", "Errors while receiving by the network interface"),
+	"NetErrOut":      model.NewMeta(model.TypeCounter, "NetErrOut", "", "Errors while sending by the network interface"),
+	"NetDropIn":      model.NewMeta(model.TypeCounter, "NetDropIn", "", "Incoming packets dropped by the network interface"),
+	"NetDropOut":     model.NewMeta(model.TypeCounter, "NetDropOut", "", "Outgoing packets dropped by the network interface"),
+	"TCPConnections": model.NewMeta(model.TypeGauge, "TCPConnections", "", "Number of TCP connections in the state"),
+
+	// process collector
+	"ProcessCPUPercent": model.NewMeta(model.TypeGauge, "ProcessCPUPercent", unitPercent, "CPU usage of the process since the previous poll"),
+	"ProcessRSS":        model.NewMeta(model.TypeGauge, "ProcessRSS", unitBytes, "Resident set size of the process"),
+	"ProcessOpenFDs":    model.NewMeta(model.TypeGauge, "ProcessOpenFDs", "", "Number of file descriptors opened by the process"),
+	"ProcessThreads":    model.NewMeta(model.TypeGauge, "ProcessThreads", "", "Number of threads of the process"),
+	"ProcessUptime":     model.NewMeta(model.TypeGauge, "ProcessUptime", unitSeconds, "Time since the process start"),
+
+	// exec collector
+	"ExecErrors": model.NewMeta(model.TypeCounter, "ExecErrors", "", "Number of failed runs of the command"),
+
+	// statsd collector
+	"StatsdErrors":  model.NewMeta(model.TypeCounter, "StatsdErrors", "", "Number of malformed StatsD metrics"),
+	"StatsdDropped": model.NewMeta(model.TypeCounter, "StatsdDropped", "", "Number of StatsD metrics dropped beyond the limit"),
+
+	// agent self-telemetry
+	telemetrySendsAttemptedID:  model.NewMeta(model.TypeCounter, telemetrySendsAttemptedID, "", "Number of sends of the metrics"),
+	telemetrySendsSucceededID:  model.NewMeta(model.TypeCounter, telemetrySendsSucceededID, "", "Number of successful sends of the metrics"),
+	telemetrySendsFailedID:     model.NewMeta(model.TypeCounter, telemetrySendsFailedID, "", "Number of failed sends of the metrics"),
+	telemetrySendRetriesID:     model.NewMeta(model.TypeCounter, telemetrySendRetriesID, "", "Number of retried requests to the server"),
+	telemetrySendLatencyID:     model.NewMeta(model.TypeHistogram, telemetrySendLatencyID, unitSeconds, "Duration of the sends with retries"),
+	telemetryBatchSizeID:       model.NewMeta(model.TypeHistogram, telemetryBatchSizeID, "", "Number of metrics in the sends"),
+	telemetryCollectDurationID: model.NewMeta(model.TypeGauge, telemetryCollectDurationID, unitSeconds, "Duration of the last collection"),
+	telemetryPollBacklogID:     model.NewMeta(model.TypeGauge, telemetryPollBacklogID, "", "Number of polls which are not committed"),
+	telemetryBreakerStateID:    model.NewMeta(model.TypeGauge, telemetryBreakerStateID, "", "Breaker state: 0 closed, 1 half-open, 2 open"),
+}
+
+// metaFor returns the built-in metadata of the metric or nil if it is unknown
+func metaFor(m *model.Metric) *model.Meta {
+	if meta, ok := builtinMeta[m.ID]; ok && meta.MType == m.MType {
+		return meta.Clone()
+	}
+	if meta, ok := runtimeMeta[m.ID]; ok && meta.MType == m.MType {
+		return meta.Clone()
+	}
+	if cpu, ok := strings.CutPrefix(m.ID, cpuUtilizationPrefix); ok && m.MType == model.TypeGauge {
+		return model.NewMeta(model.TypeGauge, m.ID, unitPercent, "Utilization of CPU "+cpu)
+	}
+	return nil
+}
+
+// Meta returns the built-in metadata of the collected metrics
+func (s *Source) Meta() []*model.Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var metas []*model.Meta
+	seen := make(map[string]bool)
+	for _, cs := range s.collectors {
+		for _, m := range cs.data {
+			// the metrics differing only by labels share the metadata
+			meta := metaFor(m)
+			if meta == nil || seen[meta.Key()] {
+				continue
+			}
+			seen[meta.Key()] = true
+			metas = append(metas, meta)
+			if meta.MType == model.TypeGauge && s.aggregation != GaugeAggregationNone {
+				metas = append(metas, windowMeta(meta, s.aggregation)...)
+			}
+		}
+	}
+	if meta := metaFor(s.pollCount); meta != nil {
+		metas = append(metas, meta)
+	}
+	return metas
+}
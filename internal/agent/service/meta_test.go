@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource_Meta(t *testing.T) {
+	s := NewSource()
+	require.NoError(t, s.Collect(context.TODO()))
+	metas := s.Meta()
+	assert.Len(t, metas, len(collected(s))+1)
+	metaMap := make(map[string]*model.Meta, len(metas))
+	for _, meta := range metas {
+		require.NoError(t, meta.Validate())
+		assert.NotEmpty(t, meta.Help, meta.ID)
+		metaMap[meta.Key()] = meta
+	}
+	for name := range getRuntimeMetrics() {
+		assert.Contains(t, metaMap, model.TypeGauge+":"+name)
+	}
+	for _, name := range testMetricNames {
+		assert.Contains(t, metaMap, model.TypeGauge+":"+name)
+	}
+	assert.Contains(t, metaMap, model.TypeCounter+":PollCount")
+	assert.Equal(t, unitBytes, metaMap[model.TypeGauge+":Alloc"].Unit)
+}
+
+func TestMetaFor(t *testing.T) {
+	meta := metaFor(model.NewMetricGauge("CPUutilization2", 1))
+	require.NotNil(t, meta)
+	assert.Equal(t, unitPercent, meta.Unit)
+	assert.Equal(t, "CPUutilization2", meta.ID)
+	assert.Nil(t, metaFor(model.NewMetricGauge("Unknown", 1)))
+	assert.Nil(t, metaFor(model.NewMetricCounter("Alloc", 1)))
+
+	meta = metaFor(model.NewMetricGauge("Alloc", 1))
+	meta.Unit = "changed"
+	assert.Equal(t, unitBytes, builtinMeta["Alloc"].Unit)
+}
+
+func TestSource_Meta_Labels(t *testing.T) {
+	s := NewSource(&stubCollector{name: "stub", data: []*model.Metric{
+		{MType: model.TypeGauge, ID: "DiskUsed", Value: ptr(1.0), Labels: model.Labels{"mount": "/"}},
+		{MType: model.TypeGauge, ID: "DiskUsed", Value: ptr(2.0), Labels: model.Labels{"mount": "/data"}},
+	}})
+	require.NoError(t, s.Collect(context.TODO()))
+	metas := s.Meta()
+	require.Len(t, metas, 2)
+	assert.Equal(t, "DiskUsed", metas[0].ID)
+	assert.Equal(t, "PollCount", metas[1].ID)
+}
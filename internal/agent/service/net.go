@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+// NetCollectorName is the name of the collector of the network interface and TCP connection metrics
+const NetCollectorName = "net"
+
+// tcpStates are the TCP connection states reported even if there are no connections in them
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// NetConfig is the config of the net collector
+type NetConfig struct {
+	// Include are the patterns of the reported interfaces, all interfaces are reported if it is empty
+	Include []string
+	// Exclude are the patterns of the interfaces which are not reported
+	Exclude []string
+}
+
+// Validate returns an error if a pattern is malformed
+func (c *NetConfig) Validate() error {
+	return validatePatterns(c.Include, c.Exclude)
+}
+
+// matchInterface returns true if the interface is included and is not excluded
+func (c *NetConfig) matchInterface(name string) bool {
+	return matchPatterns(c.Include, c.Exclude, name)
+}
+
+// netCollector collects the I/O counters of the network interfaces and the number of TCP connections.
+//
+// The I/O counters are reported with counter deltas since the previous collection labeled with the interface,
+// the connections are reported with gauges labeled with the TCP state. Listing the connections is expensive
+// on the busy hosts, so the collector is not enabled by default and is better run with a longer interval.
+type netCollector struct {
+	ioCounters  func(ctx context.Context, pernic bool) ([]net.IOCountersStat, error)
+	connections func(ctx context.Context, kind string) ([]net.ConnectionStat, error)
+	prev        map[string]net.IOCountersStat
+	cfg         NetConfig
+	interval    time.Duration
+	mu          sync.Mutex
+}
+
+// NewNetCollector returns the collector of the network interface and TCP connection metrics
+func NewNetCollector(interval time.Duration, cfg NetConfig) Collector {
+	return &netCollector{
+		ioCounters:  net.IOCountersWithContext,
+		connections: net.ConnectionsWithContext,
+		cfg:         cfg,
+		interval:    interval,
+	}
+}
+
+// Name returns the name of the collector
+func (c *netCollector) Name() string {
+	return NetCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *netCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect returns the network interface and TCP connection metrics
+//
+// The first collection only remembers the I/O counters, the deltas are reported starting from the second one.
+func (c *netCollector) Collect(ctx context.Context) ([]*model.Metric, error) {
+	stats, err := c.ioCounters(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network I/O counters: %w", err)
+	}
+	conns, err := c.connections(ctx, "tcp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TCP connections: %w", err)
+	}
+	data := c.collectCounters(stats)
+	counts := make(map[string]uint64, len(tcpStates))
+	for _, state := range tcpStates {
+		counts[state] = 0
+	}
+	for _, conn := range conns {
+		if conn.Status != "" {
+			counts[conn.Status]++
+		}
+	}
+	for _, state := range tcpStates {
+		data = append(data, newLabeledGauge("TCPConnections", model.Labels{"state": state}, counts[state]))
+		delete(counts, state)
+	}
+	// the states which are specific for the OS
+	for state, n := range counts {
+		data = append(data, newLabeledGauge("TCPConnections", model.Labels{"state": state}, n))
+	}
+	return data, nil
+}
+
+// collectCounters returns the deltas of the I/O counters of the matching interfaces
+func (c *netCollector) collectCounters(stats []net.IOCountersStat) []*model.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var data []*model.Metric
+	counters := make(map[string]net.IOCountersStat, len(stats))
+	for _, cur := range stats {
+		if !c.cfg.matchInterface(cur.Name) {
+			continue
+		}
+		counters[cur.Name] = cur
+		prev, ok := c.prev[cur.Name]
+		if !ok {
+			continue
+		}
+		labels := model.Labels{"interface": cur.Name}
+		data = append(data,
+			newCounterDelta("NetBytesSent", labels, cur.BytesSent, prev.BytesSent),
+			newCounterDelta("NetBytesRecv", labels, cur.BytesRecv, prev.BytesRecv),
+			newCounterDelta("NetPacketsSent", labels, cur.PacketsSent, prev.PacketsSent),
+			newCounterDelta("NetPacketsRecv", labels, cur.PacketsRecv, prev.PacketsRecv),
+			newCounterDelta("NetErrIn", labels, cur.Errin, prev.Errin),
+			newCounterDelta("NetErrOut", labels, cur.Errout, prev.Errout),
+			newCounterDelta("NetDropIn", labels, cur.Dropin, prev.Dropin),
+			newCounterDelta("NetDropOut", labels, cur.Dropout, prev.Dropout),
+		)
+	}
+	c.prev = counters
+	return data
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetCollector_Collect(t *testing.T) {
+	stats := []net.IOCountersStat{
+		{Name: "lo", BytesSent: 1000},
+		{Name: "eth0", BytesSent: 100, BytesRecv: 200, PacketsSent: 1, PacketsRecv: 2, Errin: 1, Dropout: 5},
+	}
+	c := NewNetCollector(0, NetConfig{Exclude: []string{"lo"}}).(*netCollector)
+	c.ioCounters = func(context.Context, bool) ([]net.IOCountersStat, error) {
+		return stats, nil
+	}
+	c.connections = func(_ context.Context, kind string) ([]net.ConnectionStat, error) {
+		assert.Equal(t, "tcp", kind)
+		return []net.ConnectionStat{{Status: "ESTABLISHED"}, {Status: "ESTABLISHED"}, {Status: "LISTEN"}, {Status: "BOUND"}}, nil
+	}
+
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, data, len(tcpStates)+1, "the first collection has no I/O deltas")
+	conns := make(map[string]float64)
+	for _, m := range data {
+		assert.Equal(t, "TCPConnections", m.ID)
+		assert.Equal(t, model.TypeGauge, m.MType)
+		conns[m.Labels["state"]] = *m.Value
+	}
+	assert.Equal(t, 2.0, conns["ESTABLISHED"])
+	assert.Equal(t, 1.0, conns["LISTEN"])
+	assert.Equal(t, 1.0, conns["BOUND"])
+	assert.Equal(t, 0.0, conns["TIME_WAIT"])
+
+	stats = []net.IOCountersStat{
+		{Name: "lo", BytesSent: 2000},
+		{Name: "eth0", BytesSent: 150, BytesRecv: 200, PacketsSent: 3, PacketsRecv: 2, Errin: 1, Dropout: 2},
+	}
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	labels := model.Labels{"interface": "eth0"}
+	assert.Equal(t, []*model.Metric{
+		{MType: model.TypeCounter, ID: "NetBytesSent", Delta: ptr(int64(50)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetBytesRecv", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetPacketsSent", Delta: ptr(int64(2)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetPacketsRecv", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetErrIn", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetErrOut", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetDropIn", Delta: ptr(int64(0)), Labels: labels},
+		{MType: model.TypeCounter, ID: "NetDropOut", Delta: ptr(int64(2)), Labels: labels},
+	}, data[:8], "the reset counter reports its current value")
+	assert.Len(t, data, 8+len(tcpStates)+1)
+}
+
+func TestNetCollector_Collect_Error(t *testing.T) {
+	c := NewNetCollector(0, NetConfig{}).(*netCollector)
+	c.ioCounters = func(context.Context, bool) ([]net.IOCountersStat, error) {
+		return nil, nil
+	}
+	c.connections = func(context.Context, string) ([]net.ConnectionStat, error) {
+		return nil, errors.New("connections error")
+	}
+	_, err := c.Collect(context.TODO())
+	require.Error(t, err)
+}
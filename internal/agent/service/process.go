@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// ProcessCollectorName is the name of the collector of the per process resource metrics
+const ProcessCollectorName = "process"
+
+// ProcessConfig is the config of the process collector, the process is selected if it matches any of the selectors
+type ProcessConfig struct {
+	// Name is the regular expression matched against the process name
+	Name string
+	// Cmdline is the regular expression matched against the process command line
+	Cmdline string
+	// PidFiles are the files containing the pid of the process
+	PidFiles []string
+}
+
+// Validate returns an error if there is no selector or a regular expression is malformed
+func (c *ProcessConfig) Validate() error {
+	if c.Name == "" && c.Cmdline == "" && len(c.PidFiles) == 0 {
+		return errors.New("no process selector")
+	}
+	if _, err := compileOptional(c.Name); err != nil {
+		return fmt.Errorf("invalid process name pattern: %w", err)
+	}
+	if _, err := compileOptional(c.Cmdline); err != nil {
+		return fmt.Errorf("invalid process cmdline pattern: %w", err)
+	}
+	return nil
+}
+
+// compileOptional compiles the regular expression, the empty expression gives nil
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	//nolint:wrapcheck // ignore
+	return regexp.Compile(expr)
+}
+
+// processHandle is the part of *process.Process used by the collector
+type processHandle interface {
+	NameWithContext(ctx context.Context) (string, error)
+	CmdlineWithContext(ctx context.Context) (string, error)
+	CreateTimeWithContext(ctx context.Context) (int64, error)
+	PercentWithContext(ctx context.Context, interval time.Duration) (float64, error)
+	MemoryInfoWithContext(ctx context.Context) (*process.MemoryInfoStat, error)
+	NumFDsWithContext(ctx context.Context) (int32, error)
+	NumThreadsWithContext(ctx context.Context) (int32, error)
+}
+
+// trackedProcess is the selected process seen on the previous collection
+type trackedProcess struct {
+	handle     processHandle
+	name       string
+	createTime int64
+	// cpuBaseline is true if the CPU times are taken, the CPU usage is reported since the next collection
+	cpuBaseline bool
+}
+
+// processCollector collects the CPU usage, RSS, open file descriptors, threads and uptime of the selected processes.
+//
+// The metrics are labeled with the process name and pid. The processes are tracked between collections
+// by pid and create time, so a reused pid is recognized as a new process. The processes which disappear
+// are dropped silently.
+type processCollector struct {
+	pids     func(ctx context.Context) ([]int32, error)
+	open     func(ctx context.Context, pid int32) (processHandle, error)
+	readFile func(name string) ([]byte, error)
+	now      func() time.Time
+	tracked  map[int32]*trackedProcess
+	name     *regexp.Regexp
+	cmdline  *regexp.Regexp
+	pidFiles []string
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// NewProcessCollector returns the collector of the per process resource metrics,
+// the config must be valid
+func NewProcessCollector(interval time.Duration, cfg ProcessConfig) Collector {
+	name, _ := compileOptional(cfg.Name)
+	cmdline, _ := compileOptional(cfg.Cmdline)
+	return &processCollector{
+		pids: process.PidsWithContext,
+		open: func(ctx context.Context, pid int32) (processHandle, error) {
+			//nolint:wrapcheck // ignore
+			return process.NewProcessWithContext(ctx, pid)
+		},
+		readFile: os.ReadFile,
+		now:      time.Now,
+		name:     name,
+		cmdline:  cmdline,
+		pidFiles: cfg.PidFiles,
+		interval: interval,
+	}
+}
+
+// Name returns the name of the collector
+func (c *processCollector) Name() string {
+	return ProcessCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *processCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect returns the resource metrics of the selected processes
+func (c *processCollector) Collect(ctx context.Context) ([]*model.Metric, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	candidates, err := c.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alive := make(map[int32]*trackedProcess, len(candidates))
+	var data []*model.Metric
+	for _, pid := range candidates {
+		if _, ok := alive[pid]; ok {
+			continue
+		}
+		tp := c.track(ctx, pid)
+		if tp == nil {
+			continue
+		}
+		metrics, err := c.processMetrics(ctx, pid, tp)
+		if err != nil {
+			// the process has exited
+			continue
+		}
+		alive[pid] = tp
+		data = append(data, metrics...)
+	}
+	c.tracked = alive
+	return data, nil
+}
+
+// candidates returns the pids of the selected processes: the pids from the pid files
+// and the pids matching the name or cmdline patterns, the missing pid files are skipped
+func (c *processCollector) candidates(ctx context.Context) ([]int32, error) {
+	var pids []int32
+	for _, name := range c.pidFiles {
+		data, err := c.readFile(name)
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+		if err != nil || pid <= 0 {
+			continue
+		}
+		pids = append(pids, int32(pid))
+	}
+	if c.name == nil && c.cmdline == nil {
+		return pids, nil
+	}
+	all, err := c.pids(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pids: %w", err)
+	}
+	for _, pid := range all {
+		if c.match(ctx, pid) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// match returns true if the name or the cmdline of the process matches the patterns
+func (c *processCollector) match(ctx context.Context, pid int32) bool {
+	h, err := c.open(ctx, pid)
+	if err != nil {
+		return false
+	}
+	if c.name != nil {
+		if name, err := h.NameWithContext(ctx); err == nil && c.name.MatchString(name) {
+			return true
+		}
+	}
+	if c.cmdline != nil {
+		if cmdline, err := h.CmdlineWithContext(ctx); err == nil && c.cmdline.MatchString(cmdline) {
+			return true
+		}
+	}
+	return false
+}
+
+// track returns the tracked process with the pid, the process is tracked anew if the pid is reused,
+// nil is returned if the process has exited
+func (c *processCollector) track(ctx context.Context, pid int32) *trackedProcess {
+	h, err := c.open(ctx, pid)
+	if err != nil {
+		return nil
+	}
+	createTime, err := h.CreateTimeWithContext(ctx)
+	if err != nil {
+		return nil
+	}
+	if tp, ok := c.tracked[pid]; ok && tp.createTime == createTime {
+		return tp
+	}
+	name, err := h.NameWithContext(ctx)
+	if err != nil {
+		return nil
+	}
+	return &trackedProcess{handle: h, name: name, createTime: createTime}
+}
+
+// processMetrics returns the metrics of the process, an error is returned if the process has exited.
+//
+// The metrics which can not be read for other reasons, e.g. open file descriptors of the process
+// of another user, are skipped.
+func (c *processCollector) processMetrics(ctx context.Context, pid int32, tp *trackedProcess) ([]*model.Metric, error) {
+	labels := model.Labels{"process": tp.name, "pid": strconv.Itoa(int(pid))}
+	var data []*model.Metric
+	gauge := func(id string, v float64) {
+		m := model.NewMetricGauge(id, v)
+		m.Labels = labels.Clone()
+		data = append(data, m)
+	}
+	pct, err := tp.handle.PercentWithContext(ctx, 0)
+	if isProcessGone(err) {
+		return nil, err
+	}
+	if err == nil {
+		if tp.cpuBaseline {
+			gauge("ProcessCPUPercent", pct)
+		}
+		tp.cpuBaseline = true
+	}
+	mem, err := tp.handle.MemoryInfoWithContext(ctx)
+	if isProcessGone(err) {
+		return nil, err
+	}
+	if err == nil {
+		gauge("ProcessRSS", float64(mem.RSS))
+	}
+	fds, err := tp.handle.NumFDsWithContext(ctx)
+	if isProcessGone(err) {
+		return nil, err
+	}
+	if err == nil {
+		gauge("ProcessOpenFDs", float64(fds))
+	}
+	threads, err := tp.handle.NumThreadsWithContext(ctx)
+	if isProcessGone(err) {
+		return nil, err
+	}
+	if err == nil {
+		gauge("ProcessThreads", float64(threads))
+	}
+	gauge("ProcessUptime", c.now().Sub(time.UnixMilli(tp.createTime)).Seconds())
+	return data, nil
+}
+
+// isProcessGone returns true if the error means that the process has exited
+func isProcessGone(err error) bool {
+	return errors.Is(err, process.ErrorProcessNotRunning) || errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/shirou/gopsutil/v4/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubProcess is the process handle with the given values
+type stubProcess struct {
+	fdsErr     error
+	name       string
+	cmdline    string
+	createTime int64
+	percent    float64
+	rss        uint64
+	fds        int32
+	threads    int32
+	gone       bool
+}
+
+func (p *stubProcess) err() error {
+	if p.gone {
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+func (p *stubProcess) NameWithContext(context.Context) (string, error) {
+	return p.name, p.err()
+}
+
+func (p *stubProcess) CmdlineWithContext(context.Context) (string, error) {
+	return p.cmdline, p.err()
+}
+
+func (p *stubProcess) CreateTimeWithContext(context.Context) (int64, error) {
+	return p.createTime, p.err()
+}
+
+func (p *stubProcess) PercentWithContext(context.Context, time.Duration) (float64, error) {
+	return p.percent, p.err()
+}
+
+func (p *stubProcess) MemoryInfoWithContext(context.Context) (*process.MemoryInfoStat, error) {
+	return &process.MemoryInfoStat{RSS: p.rss}, p.err()
+}
+
+func (p *stubProcess) NumFDsWithContext(context.Context) (int32, error) {
+	if p.fdsErr != nil {
+		return 0, p.fdsErr
+	}
+	return p.fds, p.err()
+}
+
+func (p *stubProcess) NumThreadsWithContext(context.Context) (int32, error) {
+	return p.threads, p.err()
+}
+
+// newTestProcessCollector returns the process collector over the stubbed processes
+func newTestProcessCollector(t *testing.T, cfg ProcessConfig, procs map[int32]*stubProcess) *processCollector {
+	t.Helper()
+	require.NoError(t, cfg.Validate())
+	c := NewProcessCollector(0, cfg).(*processCollector)
+	c.pids = func(context.Context) ([]int32, error) {
+		pids := make([]int32, 0, len(procs))
+		for pid := range procs {
+			pids = append(pids, pid)
+		}
+		return pids, nil
+	}
+	c.open = func(_ context.Context, pid int32) (processHandle, error) {
+		p, ok := procs[pid]
+		if !ok || p.gone {
+			return nil, process.ErrorProcessNotRunning
+		}
+		return p, nil
+	}
+	c.readFile = func(name string) ([]byte, error) {
+		if name == "/run/db.pid" {
+			return []byte("30\n"), nil
+		}
+		return nil, fs.ErrNotExist
+	}
+	c.now = func() time.Time {
+		return time.UnixMilli(100_000)
+	}
+	return c
+}
+
+// byPid groups the metrics by the pid label and the metric ID
+func byPid(data []*model.Metric) map[string]map[string]float64 {
+	res := make(map[string]map[string]float64)
+	for _, m := range data {
+		pid := m.Labels["pid"]
+		if res[pid] == nil {
+			res[pid] = make(map[string]float64)
+		}
+		res[pid][m.ID] = *m.Value
+	}
+	return res
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	procs := map[int32]*stubProcess{
+		10: {name: "nginx", cmdline: "nginx -g daemon off;", createTime: 40_000, percent: 5, rss: 1024, fds: 7, threads: 2},
+		20: {name: "python3", cmdline: "python3 /opt/app/worker.py", createTime: 90_000, percent: 1, rss: 2048, fds: 3, threads: 4},
+		30: {name: "postgres", createTime: 10_000, percent: 2, rss: 4096, fdsErr: errors.New("permission denied"), threads: 8},
+		40: {name: "bash", cmdline: "bash"},
+	}
+	c := newTestProcessCollector(t, ProcessConfig{
+		Name:     "^nginx$",
+		Cmdline:  `worker\.py`,
+		PidFiles: []string{"/run/db.pid", "/run/missing.pid"},
+	}, procs)
+
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	got := byPid(data)
+	require.Len(t, got, 3)
+	assert.Equal(t, map[string]float64{
+		"ProcessRSS": 1024, "ProcessOpenFDs": 7, "ProcessThreads": 2, "ProcessUptime": 60,
+	}, got["10"], "the CPU usage is not reported on the first collection")
+	assert.Equal(t, map[string]float64{
+		"ProcessRSS": 4096, "ProcessThreads": 8, "ProcessUptime": 90,
+	}, got["30"], "the metric which can not be read is skipped")
+	for _, m := range data {
+		if m.Labels["pid"] == "20" {
+			assert.Equal(t, "python3", m.Labels["process"])
+		}
+	}
+
+	procs[20].gone = true
+	procs[10] = &stubProcess{name: "nginx", createTime: 95_000, percent: 3}
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	got = byPid(data)
+	require.Len(t, got, 2, "the exited process is dropped")
+	assert.NotContains(t, got["10"], "ProcessCPUPercent", "the reused pid is a new process")
+	assert.Equal(t, 2.0, got["30"]["ProcessCPUPercent"])
+	assert.Len(t, c.tracked, 2)
+}
+
+func TestProcessConfig_Validate(t *testing.T) {
+	assert.Error(t, (&ProcessConfig{}).Validate())
+	assert.Error(t, (&ProcessConfig{Name: "("}).Validate())
+	assert.Error(t, (&ProcessConfig{Cmdline: "["}).Validate())
+	assert.NoError(t, (&ProcessConfig{PidFiles: []string{"/run/app.pid"}}).Validate())
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// RuntimeCollectorName is the name of the collector of the Go runtime metrics
+const RuntimeCollectorName = "runtime"
+
+// runtimeMetricPrefix is the prefix of the IDs of the metrics read with runtime/metrics
+const runtimeMetricPrefix = "go"
+
+// RuntimeConfig is the config of the runtime collector
+type RuntimeConfig struct {
+	// MemStats enables the legacy metrics read with runtime.ReadMemStats, e.g. Alloc or NumGC,
+	// and the RandomValue metric. ReadMemStats stops the world, so they are disabled by default.
+	MemStats bool
+}
+
+// runtimeCollector collects the Go runtime metrics with runtime/metrics.
+//
+// All supported metrics are read. The metric ID is made of the metric name,
+// e.g. /gc/heap/allocs:bytes is go_gc_heap_allocs_bytes. The cumulative integer metrics are reported
+// with counter deltas since the previous collection, the cumulative distributions, e.g. GC pauses
+// and scheduler latencies, are reported with histogram deltas, the other metrics are reported with gauges.
+type runtimeCollector struct {
+	prevCounters   map[string]uint64
+	prevHistograms map[string][]uint64
+	descs          []metrics.Description
+	samples        []metrics.Sample
+	cfg            RuntimeConfig
+	interval       time.Duration
+	mu             sync.Mutex
+}
+
+// NewRuntimeCollector returns the collector of the Go runtime metrics
+func NewRuntimeCollector(interval time.Duration, cfg RuntimeConfig) Collector {
+	c := &runtimeCollector{
+		prevCounters:   make(map[string]uint64),
+		prevHistograms: make(map[string][]uint64),
+		cfg:            cfg,
+		interval:       interval,
+	}
+	for _, d := range metrics.All() {
+		if d.Kind == metrics.KindBad || d.Kind == metrics.KindFloat64Histogram && !d.Cumulative {
+			// the distributions which are not cumulative can not be reported with deltas
+			continue
+		}
+		c.descs = append(c.descs, d)
+		c.samples = append(c.samples, metrics.Sample{Name: d.Name})
+	}
+	return c
+}
+
+// Name returns the name of the collector
+func (c *runtimeCollector) Name() string {
+	return RuntimeCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *runtimeCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Collect returns the Go runtime metrics
+//
+// The first collection reports the counters and the distributions since the start of the agent.
+func (c *runtimeCollector) Collect(_ context.Context) ([]*model.Metric, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metrics.Read(c.samples)
+	data := make([]*model.Metric, 0, len(c.samples))
+	for i, s := range c.samples {
+		d := c.descs[i]
+		id := runtimeMetricID(d.Name)
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := s.Value.Uint64()
+			if !d.Cumulative {
+				data = append(data, model.NewMetricGauge(id, float64(v)))
+				continue
+			}
+			data = append(data, newCounterDelta(id, nil, v, c.prevCounters[id]))
+			c.prevCounters[id] = v
+		case metrics.KindFloat64:
+			data = append(data, model.NewMetricGauge(id, s.Value.Float64()))
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			data = append(data, model.NewMetricHistogram(id, histogramDelta(h, c.prevHistograms[id])))
+			c.prevHistograms[id] = append(c.prevHistograms[id][:0], h.Counts...)
+		default:
+		}
+	}
+	if c.cfg.MemStats {
+		for m := range genPullMetrics() {
+			data = append(data, m)
+		}
+	}
+	return data, nil
+}
+
+// histogramDelta returns the histogram of the observations since the previous bucket counts.
+//
+// The bucket [b[n], b[n+1]) of the runtime histogram is mapped to the bucket with the upper bound b[n+1].
+// The runtime does not track the sum, so it is estimated by the bucket midpoints.
+func histogramDelta(h *metrics.Float64Histogram, prev []uint64) *model.Histogram {
+	uppers := h.Buckets[1:]
+	bounds := uppers
+	if math.IsInf(uppers[len(uppers)-1], 1) {
+		bounds = uppers[:len(uppers)-1]
+	}
+	res := model.NewHistogram(bounds)
+	reset := len(prev) != len(h.Counts)
+	for i, count := range h.Counts {
+		if !reset && count >= prev[i] {
+			count -= prev[i]
+		}
+		res.Counts[i] = count
+		res.Sum += float64(count) * bucketMidpoint(h.Buckets[i], h.Buckets[i+1])
+	}
+	return res
+}
+
+// bucketMidpoint returns the midpoint of the bucket, the finite bound for the unbounded bucket
+func bucketMidpoint(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1) && math.IsInf(upper, 1):
+		return 0
+	case math.IsInf(lower, -1):
+		return upper
+	case math.IsInf(upper, 1):
+		return lower
+	}
+	return (lower + upper) / 2 //nolint:mnd // ignore
+}
+
+// runtimeMetricID returns the metric ID for the runtime/metrics name:
+// the runtimeMetricPrefix followed by the name with the characters other than letters and digits replaced with "_"
+func runtimeMetricID(name string) string {
+	return runtimeMetricPrefix + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// runtimeMeta is the metadata of the metrics read with runtime/metrics by ID
+var runtimeMeta = newRuntimeMeta()
+
+// newRuntimeMeta returns the metadata of the metrics read with runtime/metrics,
+// the unit is taken from the name and the help is the first sentence of the description
+func newRuntimeMeta() map[string]*model.Meta {
+	descs := metrics.All()
+	res := make(map[string]*model.Meta, len(descs))
+	for _, d := range descs {
+		t := model.TypeGauge
+		switch {
+		case d.Kind == metrics.KindFloat64Histogram:
+			t = model.TypeHistogram
+		case d.Kind == metrics.KindUint64 && d.Cumulative:
+			t = model.TypeCounter
+		}
+		id := runtimeMetricID(d.Name)
+		_, unit, _ := strings.Cut(d.Name, ":")
+		meta := model.NewMeta(t, id, unit, d.Description)
+		if help, _, ok := strings.Cut(d.Description, ". "); ok {
+			meta.Help = help + "."
+			meta.Description = d.Description
+		}
+		res[id] = meta
+	}
+	return res
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeMetricID(t *testing.T) {
+	assert.Equal(t, "go_gc_heap_allocs_bytes", runtimeMetricID("/gc/heap/allocs:bytes"))
+	assert.Equal(t, "go_cpu_classes_gc_mark_assist_cpu_seconds", runtimeMetricID("/cpu/classes/gc/mark/assist:cpu-seconds"))
+}
+
+func TestRuntimeCollector_Collect(t *testing.T) {
+	c := NewRuntimeCollector(0, RuntimeConfig{})
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	byID := make(map[string]*model.Metric, len(data))
+	for _, m := range data {
+		require.NoError(t, (&model.MetricRequest{Metric: m}).RequiredValue(), m.ID)
+		byID[m.ID] = m
+	}
+	assert.NotContains(t, byID, "Alloc")
+	assert.NotContains(t, byID, "RandomValue")
+	require.Contains(t, byID, "go_gc_heap_allocs_bytes")
+	assert.Equal(t, model.TypeCounter, byID["go_gc_heap_allocs_bytes"].MType)
+	require.Contains(t, byID, "go_sched_latencies_seconds")
+	assert.Equal(t, model.TypeHistogram, byID["go_sched_latencies_seconds"].MType)
+	require.Contains(t, byID, "go_memory_classes_heap_objects_bytes")
+	assert.Equal(t, model.TypeGauge, byID["go_memory_classes_heap_objects_bytes"].MType)
+	for _, m := range data {
+		meta := metaFor(m)
+		require.NotNil(t, meta, m.ID)
+		assert.NotEmpty(t, meta.Unit, m.ID)
+	}
+
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	for _, m := range data {
+		if m.ID == "go_gc_heap_allocs_bytes" {
+			assert.Less(t, uint64(*m.Delta), sample[0].Value.Uint64(), "the second collection reports the delta")
+		}
+	}
+}
+
+func TestRuntimeCollector_Collect_MemStats(t *testing.T) {
+	data, err := NewRuntimeCollector(0, RuntimeConfig{MemStats: true}).Collect(context.TODO())
+	require.NoError(t, err)
+	ids := make(map[string]bool, len(data))
+	for _, m := range data {
+		ids[m.ID] = true
+	}
+	for _, name := range testMetricNames[:len(getRuntimeMetrics())+1] {
+		assert.True(t, ids[name], name)
+	}
+}
+
+func TestHistogramDelta(t *testing.T) {
+	tests := []struct {
+		h    *metrics.Float64Histogram
+		want *model.Histogram
+		name string
+		prev []uint64
+	}{
+		{
+			name: "unbounded",
+			h:    &metrics.Float64Histogram{Buckets: []float64{math.Inf(-1), 1, 2, math.Inf(1)}, Counts: []uint64{1, 2, 3}},
+			prev: []uint64{0, 1, 1},
+			want: &model.Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 1, 2}, Sum: 6.5},
+		},
+		{
+			name: "bounded",
+			h:    &metrics.Float64Histogram{Buckets: []float64{0, 1, 2}, Counts: []uint64{1, 1}},
+			want: &model.Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 1, 0}, Sum: 2},
+		},
+		{
+			name: "reset",
+			h:    &metrics.Float64Histogram{Buckets: []float64{0, 1, 2}, Counts: []uint64{1, 1}},
+			prev: []uint64{5, 0},
+			want: &model.Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 1, 0}, Sum: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := histogramDelta(tt.h, tt.prev)
+			require.NoError(t, got.Validate())
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
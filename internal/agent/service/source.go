@@ -1,82 +1,361 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
-	"golang.org/x/sync/errgroup"
 )
 
+// collectorState is the registered collector with the result of its last successful collection
+type collectorState struct {
+	collector Collector
+	last      time.Time
+	data      []*model.Metric
+}
+
+// isDue returns true if the interval of the collector has passed since its last successful collection
+func (cs *collectorState) isDue(now time.Time) bool {
+	return cs.last.IsZero() || now.Sub(cs.last) >= cs.collector.Interval()
+}
+
+// store replaces the stored metrics with the collected ones,
+// the uncommitted deltas of the stored counters, histograms and sets are added to the collected ones,
+// the uncommitted deltas which are not collected anymore are kept until they are committed
+func (cs *collectorState) store(data []*model.Metric) {
+	uncommitted := make(map[string]*model.Metric)
+	for _, m := range cs.data {
+		if isDelta(m) && !isEmptyDelta(m) {
+			uncommitted[deltaKey(m)] = m
+		}
+	}
+	for _, m := range data {
+		if old, ok := uncommitted[deltaKey(m)]; ok && isDelta(m) {
+			// the old deltas are dropped if the histogram bounds are changed
+			_ = m.Merge(old)
+			delete(uncommitted, deltaKey(m))
+		}
+	}
+	for _, m := range cs.data {
+		if _, ok := uncommitted[deltaKey(m)]; ok {
+			data = append(data, m)
+		}
+	}
+	cs.data = data
+}
+
+// commit subtracts the reported deltas from the stored counters and histograms,
+// the reported sets are dropped unless they are changed since they were reported
+func (cs *collectorState) commit(reported map[string]*model.Metric) {
+	data := cs.data[:0]
+	for _, m := range cs.data {
+		if r, ok := reported[deltaKey(m)]; ok && isDelta(m) {
+			if m.MType == model.TypeSet && sameSet(m, r) {
+				continue
+			}
+			subtractDelta(m, r)
+		}
+		data = append(data, m)
+	}
+	clear(cs.data[len(data):])
+	cs.data = data
+}
+
+// isDelta returns true if the metric value is the delta since the previous collection
+func isDelta(m *model.Metric) bool {
+	switch m.MType {
+	case model.TypeCounter:
+		return m.Delta != nil
+	case model.TypeHistogram:
+		return m.Histogram != nil
+	case model.TypeSet:
+		return m.Set != nil || len(m.Members) > 0
+	}
+	return false
+}
+
+// isEmptyDelta returns true if the counter or the histogram delta is zero
+func isEmptyDelta(m *model.Metric) bool {
+	switch m.MType {
+	case model.TypeCounter:
+		return *m.Delta == 0
+	case model.TypeHistogram:
+		return m.Histogram.Count() == 0 && m.Histogram.Sum == 0
+	}
+	return false
+}
+
+// sameSet returns true if the set metrics have the same value
+func sameSet(m, r *model.Metric) bool {
+	m.FoldMembers()
+	r.FoldMembers()
+	if m.Set == nil || r.Set == nil {
+		return m.Set == r.Set
+	}
+	a, errA := m.Set.MarshalBinary()
+	b, errB := r.Set.MarshalBinary()
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// deltaKey returns the key of the delta metric
+func deltaKey(m *model.Metric) string {
+	return m.MType + ":" + m.Key()
+}
+
+// subtractDelta subtracts the reported delta from the delta metric of the same type,
+// the sets can not be subtracted, the union is sent again
+func subtractDelta(m, r *model.Metric) {
+	switch m.MType {
+	case model.TypeCounter:
+		*m.Delta -= *r.Delta
+	case model.TypeHistogram:
+		if len(m.Histogram.Counts) != len(r.Histogram.Counts) {
+			return
+		}
+		for i, c := range r.Histogram.Counts {
+			m.Histogram.Counts[i] -= min(c, m.Histogram.Counts[i])
+		}
+		m.Histogram.Sum -= r.Histogram.Sum
+	}
+}
+
 // Source is a structure that provides a source of metrics
+//
+// The counters, histograms and sets returned by collectors are deltas: they are accumulated between collections
+// until they are committed. The polled gauges are aggregated over the report window if the gauge aggregation
+// is set, the window is reset by Commit.
 type Source struct {
-	pollCount *model.Metric
-	data      []*model.Metric
-	mu        sync.RWMutex
+	pollCount       *model.Metric
+	reported        map[string]*model.Metric
+	windows         gaugeWindows
+	reportedWindows gaugeWindows
+	telemetry       *Telemetry
+	aggregation     GaugeAggregation
+	collectors      []*collectorState
+	mu              sync.RWMutex
 }
 
-// NewSource returns a new instance of Source
-func NewSource() *Source {
-	return &Source{
-		pollCount: model.NewMetricCounter("PollCount", 0),
+// NewSource returns a new instance of Source with the given collectors,
+// the runtime collector with the legacy MemStats metrics and the gopsutil collector are used if none is given
+func NewSource(collectors ...Collector) *Source {
+	if len(collectors) == 0 {
+		collectors = []Collector{NewRuntimeCollector(0, RuntimeConfig{MemStats: true}), NewGopsutilCollector(0)}
 	}
+	s := &Source{
+		pollCount:   model.NewMetricCounter("PollCount", 0),
+		windows:     make(gaugeWindows),
+		aggregation: GaugeAggregationNone,
+		collectors:  make([]*collectorState, len(collectors)),
+	}
+	for i, c := range collectors {
+		s.collectors[i] = &collectorState{collector: c}
+	}
+	return s
+}
+
+// SetGaugeAggregation sets the aggregation of the gauges polled over the report window,
+// the empty mode is GaugeAggregationNone
+func (s *Source) SetGaugeAggregation(mode GaugeAggregation) {
+	if mode == "" {
+		mode = GaugeAggregationNone
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aggregation = mode
+	s.windows = make(gaugeWindows)
+	s.reportedWindows = nil
+}
+
+// SetTelemetry sets the telemetry recording the collection durations and the uncommitted PollCount,
+// the telemetry is reported only if it is one of the collectors
+func (s *Source) SetTelemetry(t *Telemetry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.telemetry = t
 }
 
 // Collect collects metrics
+//
+// The collectors whose interval has passed are run concurrently. All collected metrics are stamped
+// with the collection time. If a collector fails, its previous metrics are kept and it is retried
+// on the next call, the results of the other collectors are stored anyway.
 func (s *Source) Collect(ctx context.Context) error {
-	finalCh := make(chan *model.Metric)
-	g := new(errgroup.Group)
-	g.Go(func() error {
-		for m := range genPullMetrics() {
-			finalCh <- m
+	now := time.Now()
+	ts := now.UnixMilli()
+	s.mu.RLock()
+	due := make([]bool, len(s.collectors))
+	for i, cs := range s.collectors {
+		due[i] = cs.isDue(now)
+	}
+	telemetry := s.telemetry
+	if telemetry != nil {
+		telemetry.recordPollBacklog(*s.pollCount.Delta + 1)
+	}
+	s.mu.RUnlock()
+	results := make([][]*model.Metric, len(s.collectors))
+	errs := make([]error, len(s.collectors))
+	durations := make([]time.Duration, len(s.collectors))
+	var wg sync.WaitGroup
+	for i, cs := range s.collectors {
+		if !due[i] {
+			continue
 		}
-		return nil
-	})
-	g.Go(func() error {
-		for m := range genGopsutilMetrics(ctx) {
-			if m.err != nil {
-				return m.err
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			data, err := cs.collector.Collect(ctx)
+			durations[i] = time.Since(start)
+			if err != nil {
+				errs[i] = fmt.Errorf("collector %s: %w", cs.collector.Name(), err)
+			}
+			if err != nil && data == nil {
+				return
+			}
+			for _, m := range data {
+				m.Timestamp = ts
+			}
+			results[i] = data
+		}()
+	}
+	wg.Wait()
+	if telemetry != nil {
+		for i, cs := range s.collectors {
+			if due[i] {
+				telemetry.recordCollect(cs.collector.Name(), durations[i])
 			}
-			finalCh <- m.m
 		}
-		return nil
-	})
-	doneCh := make(chan struct{})
-	go func() {
-		defer close(doneCh)
-		s.mu.RLock()
-		data := make([]*model.Metric, 0, len(s.data))
-		s.mu.RUnlock()
-		for m := range finalCh {
-			data = append(data, m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, cs := range s.collectors {
+		if due[i] && (errs[i] == nil || results[i] != nil) {
+			if s.aggregation != GaugeAggregationNone {
+				s.windows.observe(results[i], s.aggregation)
+			}
+			cs.store(results[i])
+			cs.last = now
 		}
-		s.mu.Lock()
-		defer s.mu.Unlock()
-		s.data = data
-		*s.pollCount.Delta++
-	}()
-	err := g.Wait()
-	close(finalCh)
-	<-doneCh
-	return err
+	}
+	*s.pollCount.Delta++
+	s.pollCount.Timestamp = ts
+	return errors.Join(errs...)
 }
 
 // Get returns metrics
+//
+// The returned deltas of the counters and histograms are remembered to be subtracted by the next Commit.
+// The aggregates of the gauges polled since the last Commit are returned too.
 func (s *Source) Get() (data []*model.Metric, delta int64) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	data = make([]*model.Metric, len(s.data)+1)
-	for i, m := range s.data {
-		data[i] = m.Clone()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := 1
+	for _, cs := range s.collectors {
+		size += len(cs.data)
 	}
-	data[len(data)-1] = s.pollCount.Clone()
+	data = make([]*model.Metric, 0, size)
+	s.reported = make(map[string]*model.Metric)
+	for _, cs := range s.collectors {
+		for _, m := range cs.data {
+			c := m.Clone()
+			if isDelta(m) {
+				s.reported[deltaKey(m)] = c.Clone()
+			}
+			data = append(data, c)
+		}
+	}
+	data = append(data, s.windowMetrics()...)
+	data = append(data, s.pollCount.Clone())
 	delta = *s.pollCount.Delta
 	return data, delta
 }
 
-// Commit commits metrics
+// Commit commits metrics: the poll count delta and the deltas of the counters, histograms and sets returned by the last Get
 func (s *Source) Commit(delta int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	*s.pollCount.Delta -= delta
+	for _, cs := range s.collectors {
+		cs.commit(s.reported)
+	}
+	s.reported = nil
+	s.reportedWindows = nil
+}
+
+// CommitSent commits the sent metrics of the metrics returned by the last Get: the poll count delta,
+// the deltas of the counters, histograms and sets and the gauge windows whose aggregates are all sent,
+// the metrics which are not sent are reported again by the next Get
+func (s *Source) CommitSent(sent []*model.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pollCountKey := deltaKey(s.pollCount)
+	keys := make(map[string]struct{}, len(sent))
+	for _, m := range sent {
+		key := deltaKey(m)
+		keys[key] = struct{}{}
+		if key == pollCountKey && m.Delta != nil {
+			*s.pollCount.Delta -= *m.Delta
+		}
+	}
+	reported := make(map[string]*model.Metric)
+	for key, r := range s.reported {
+		if _, ok := keys[key]; ok {
+			reported[key] = r
+			delete(s.reported, key)
+		}
+	}
+	for _, cs := range s.collectors {
+		cs.commit(reported)
+	}
+	for key, w := range s.reportedWindows {
+		if !slices.ContainsFunc(w.metrics(), func(m *model.Metric) bool {
+			_, ok := keys[deltaKey(m)]
+			return !ok
+		}) {
+			delete(s.reportedWindows, key)
+		}
+	}
+}
+
+// windowMetrics adds the windows polled since the last Get to the uncommitted ones and returns their aggregates
+func (s *Source) windowMetrics() []*model.Metric {
+	if s.aggregation == GaugeAggregationNone {
+		return nil
+	}
+	if s.reportedWindows == nil {
+		s.reportedWindows = s.windows
+	} else {
+		for key, w := range s.windows {
+			if r, ok := s.reportedWindows[key]; ok {
+				r.merge(w)
+			} else {
+				s.reportedWindows[key] = w
+			}
+		}
+	}
+	s.windows = make(gaugeWindows)
+	data := make([]*model.Metric, 0, len(s.reportedWindows))
+	for _, w := range s.reportedWindows {
+		data = append(data, w.metrics()...)
+	}
+	return data
+}
+
+// Close stops the collectors which hold resources, e.g. the listeners
+func (s *Source) Close() error {
+	var errs []error
+	for _, cs := range s.collectors {
+		if c, ok := cs.collector.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"testing"
+	"time"
 )
 
 var testMetricNames = []string{"Alloc", "BuckHashSys", "Frees", "GCCPUFraction", "GCSys", "HeapAlloc",
@@ -19,9 +23,12 @@ func TestSource_NewGaugeSource_Collect_GetDataForSend(t *testing.T) {
 	s := NewSource()
 	err := s.Collect(context.TODO())
 	assert.NoError(t, err)
-	assert.GreaterOrEqual(t, len(s.data), len(testMetricNames)+1)
+	assert.GreaterOrEqual(t, len(collected(s)), len(testMetricNames)+1)
 	gaugesMap := make(map[string]*model.Metric)
-	for _, m := range s.data {
+	for _, m := range collected(s) {
+		if strings.HasPrefix(m.ID, runtimeMetricPrefix+"_") {
+			continue
+		}
 		switch m.MType {
 		case model.TypeGauge:
 			gaugesMap[m.ID] = m
@@ -43,6 +50,9 @@ func TestSource_NewGaugeSource_Collect_GetDataForSend(t *testing.T) {
 	gaugesMap = make(map[string]*model.Metric)
 	counterMap := make(map[string]*model.Metric)
 	for _, m := range data {
+		if strings.HasPrefix(m.ID, runtimeMetricPrefix+"_") {
+			continue
+		}
 		switch m.MType {
 		case model.TypeGauge:
 			gaugesMap[m.ID] = m
@@ -66,16 +76,16 @@ func TestSource_Collect(t *testing.T) {
 		s := NewSource()
 		err := s.Collect(context.TODO())
 		require.NoError(t, err)
-		assert.NotEmpty(t, s.data)
+		assert.NotEmpty(t, collected(s))
 		assert.Equal(t, int64(1), *s.pollCount.Delta)
 	})
 }
 
 func TestSource_Get(t *testing.T) {
-	s := NewSource()
-	s.data = []*model.Metric{
+	s := NewSource(&stubCollector{name: "stub", data: []*model.Metric{
 		model.NewMetricGauge("TestMetric", 42.0),
-	}
+	}})
+	require.NoError(t, s.Collect(context.TODO()))
 	*s.pollCount.Delta = 5
 
 	data, delta := s.Get()
@@ -91,7 +101,7 @@ func TestSource_Get(t *testing.T) {
 
 	t.Run("Deep copy", func(t *testing.T) {
 		*data[0].Value = 100.0
-		assert.Equal(t, 42.0, *s.data[0].Value)
+		assert.Equal(t, 42.0, *collected(s)[0].Value)
 	})
 }
 
@@ -109,3 +119,200 @@ func TestSource_Commit(t *testing.T) {
 		assert.Equal(t, int64(-3), *s.pollCount.Delta)
 	})
 }
+
+func TestSource_Collect_Timestamp(t *testing.T) {
+	s := NewSource()
+	before := time.Now().UnixMilli()
+	require.NoError(t, s.Collect(context.TODO()))
+	after := time.Now().UnixMilli()
+	data, _ := s.Get()
+	require.NotEmpty(t, data)
+	ts := data[0].Timestamp
+	assert.GreaterOrEqual(t, ts, before)
+	assert.LessOrEqual(t, ts, after)
+	for _, m := range data {
+		assert.Equal(t, ts, m.Timestamp, m.ID)
+	}
+}
+
+// stubCollector is the collector returning the given metrics or error
+type stubCollector struct {
+	err      error
+	name     string
+	data     []*model.Metric
+	partial  []*model.Metric
+	interval time.Duration
+	calls    int
+}
+
+func (c *stubCollector) Name() string {
+	return c.name
+}
+
+func (c *stubCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *stubCollector) Collect(context.Context) ([]*model.Metric, error) {
+	c.calls++
+	if c.err != nil {
+		return c.partial, c.err
+	}
+	data := make([]*model.Metric, len(c.data))
+	for i, m := range c.data {
+		data[i] = m.Clone()
+	}
+	return data, nil
+}
+
+// collected returns the metrics stored by the collectors of the source
+func collected(s *Source) []*model.Metric {
+	var data []*model.Metric
+	for _, cs := range s.collectors {
+		data = append(data, cs.data...)
+	}
+	return data
+}
+
+func TestSource_Collect_FailedCollector(t *testing.T) {
+	failing := &stubCollector{name: "failing", data: []*model.Metric{model.NewMetricGauge("f", 1)}}
+	ok := &stubCollector{name: "ok", data: []*model.Metric{model.NewMetricGauge("a", 1)}}
+	s := NewSource(failing, ok)
+	require.NoError(t, s.Collect(context.TODO()))
+
+	failing.err = errors.New("collect error")
+	ok.data = []*model.Metric{model.NewMetricGauge("a", 2)}
+	err := s.Collect(context.TODO())
+	require.ErrorIs(t, err, failing.err)
+	assert.Contains(t, err.Error(), "failing")
+
+	data, delta := s.Get()
+	assert.Equal(t, int64(2), delta)
+	require.Len(t, data, 3)
+	assert.Equal(t, "f", data[0].ID, "previous result of the failed collector is kept")
+	assert.Equal(t, "a", data[1].ID)
+	assert.Equal(t, 2.0, *data[1].Value)
+
+	failing.partial = []*model.Metric{model.NewMetricGauge("p", 1)}
+	require.ErrorIs(t, s.Collect(context.TODO()), failing.err)
+	data, _ = s.Get()
+	require.Len(t, data, 3)
+	assert.Equal(t, "p", data[0].ID, "the partial result replaces the previous one")
+}
+
+func TestSource_Collect_Interval(t *testing.T) {
+	slow := &stubCollector{name: "slow", interval: time.Hour, data: []*model.Metric{model.NewMetricGauge("s", 1)}}
+	fast := &stubCollector{name: "fast", data: []*model.Metric{model.NewMetricGauge("f", 1)}}
+	s := NewSource(slow, fast)
+	for range 3 {
+		require.NoError(t, s.Collect(context.TODO()))
+	}
+	assert.Equal(t, 1, slow.calls)
+	assert.Equal(t, 3, fast.calls)
+	data, _ := s.Get()
+	assert.Len(t, data, 3)
+}
+
+func TestSource_Collect_AccumulatesCounters(t *testing.T) {
+	c := &stubCollector{name: "stub", data: []*model.Metric{model.NewMetricCounter("c", 2)}}
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	require.NoError(t, s.Collect(context.TODO()))
+	data, delta := s.Get()
+	assert.Equal(t, int64(4), *data[0].Delta)
+
+	require.NoError(t, s.Collect(context.TODO()))
+	s.Commit(delta)
+	data, _ = s.Get()
+	assert.Equal(t, int64(2), *data[0].Delta, "the delta collected after Get is not committed")
+}
+
+func TestSource_Collect_AccumulatesHistograms(t *testing.T) {
+	c := &stubCollector{name: "stub", data: []*model.Metric{
+		model.NewMetricHistogram("h", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4}),
+	}}
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	require.NoError(t, s.Collect(context.TODO()))
+	data, delta := s.Get()
+	assert.Equal(t, &model.Histogram{Bounds: []float64{1}, Counts: []uint64{2, 4}, Sum: 8}, data[0].Histogram)
+
+	require.NoError(t, s.Collect(context.TODO()))
+	s.Commit(delta)
+	data, _ = s.Get()
+	assert.Equal(t, &model.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4}, data[0].Histogram)
+}
+
+func TestSource_Collect_KeepsUncommittedDeltas(t *testing.T) {
+	c := &stubCollector{name: "stub", data: []*model.Metric{model.NewMetricCounter("c", 2), model.NewMetricGauge("g", 1)}}
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	c.data = []*model.Metric{model.NewMetricGauge("g", 2)}
+	require.NoError(t, s.Collect(context.TODO()))
+	data, delta := s.Get()
+	require.Len(t, data, 3)
+	assert.Equal(t, "g", data[0].ID)
+	assert.Equal(t, "c", data[1].ID, "the counter not collected anymore is kept until committed")
+	assert.Equal(t, int64(2), *data[1].Delta)
+
+	s.Commit(delta)
+	require.NoError(t, s.Collect(context.TODO()))
+	data, _ = s.Get()
+	require.Len(t, data, 2)
+	assert.Equal(t, "g", data[0].ID, "the committed counter is dropped")
+}
+
+func TestSource_Collect_AccumulatesSets(t *testing.T) {
+	newSet := func(members ...string) *model.Metric {
+		m := model.NewMetricSet("s", hll.New(hll.DefaultPrecision))
+		for _, v := range members {
+			m.Set.Add(v)
+		}
+		return m
+	}
+	c := &stubCollector{name: "stub", data: []*model.Metric{newSet("a", "b")}}
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	c.data = []*model.Metric{newSet("b", "c")}
+	require.NoError(t, s.Collect(context.TODO()))
+	data, delta := s.Get()
+	require.Len(t, data, 2)
+	assert.Equal(t, uint64(3), data[0].Set.Count())
+
+	s.Commit(delta)
+	data, _ = s.Get()
+	assert.Len(t, data, 1, "the committed set is dropped")
+
+	require.NoError(t, s.Collect(context.TODO()))
+	_, delta = s.Get()
+	c.data = []*model.Metric{newSet("d")}
+	require.NoError(t, s.Collect(context.TODO()))
+	s.Commit(delta)
+	data, _ = s.Get()
+	require.Len(t, data, 2)
+	assert.Equal(t, uint64(3), data[0].Set.Count(), "the set changed after Get is sent again")
+}
+
+func TestSource_CommitSent(t *testing.T) {
+	c := &stubCollector{name: "stub", data: []*model.Metric{model.NewMetricCounter("a", 1), model.NewMetricCounter("b", 2)}}
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	data, _ := s.Get()
+	require.Len(t, data, 3)
+	s.CommitSent([]*model.Metric{data[1]})
+
+	require.NoError(t, s.Collect(context.TODO()))
+	data, _ = s.Get()
+	got := make(map[string]int64)
+	for _, m := range data {
+		got[m.ID] = *m.Delta
+	}
+	assert.Equal(t, map[string]int64{"a": 2, "b": 2, "PollCount": 2}, got,
+		"only the delta of the sent metric is committed")
+
+	s.CommitSent(data)
+	data, _ = s.Get()
+	for _, m := range data {
+		assert.Zero(t, *m.Delta, m.ID)
+	}
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+)
+
+// StatsdCollectorName is the name of the collector of the metrics received by the StatsD listener
+const StatsdCollectorName = "statsd"
+
+const (
+	// statsdErrorsID is the id of the counter of the malformed StatsD metrics
+	statsdErrorsID = "StatsdErrors"
+	// statsdDroppedID is the id of the counter of the new StatsD metrics dropped beyond maxStatsdMetrics
+	statsdDroppedID = "StatsdDropped"
+	// maxStatsdPacket is the maximum size of the UDP packet
+	maxStatsdPacket = 1 << 16
+	// maxStatsdMetrics is the maximum number of the aggregated metrics, the new metrics beyond it are dropped
+	maxStatsdMetrics = 10000
+	// statsdGaugeIdleCollections is the number of the collections after which the gauge which is not updated
+	// is evicted, so the gauges of the gone sources do not fill the limit
+	statsdGaugeIdleCollections = 10
+)
+
+// errStatsdFull is returned when the new metric is dropped beyond maxStatsdMetrics
+var errStatsdFull = errors.New("too many metrics")
+
+// statsdTimerBounds are the bucket bounds of the timer histograms in milliseconds
+var statsdTimerBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// StatsdConfig is the config of the StatsD listener
+type StatsdConfig struct {
+	// Addr is the UDP address to listen on
+	Addr string
+}
+
+// Validate returns an error if the address is not set
+func (c *StatsdConfig) Validate() error {
+	if c.Addr == "" {
+		return errors.New("no statsd address")
+	}
+	return nil
+}
+
+// statsdGauge is the gauge with the number of the collections since its last update
+type statsdGauge struct {
+	metric *model.Metric
+	idle   int
+}
+
+// statsdCounter is the aggregated counter, the fractional part of the sampled values is kept for the next collection
+type statsdCounter struct {
+	metric *model.Metric
+	value  float64
+}
+
+// statsdCollector listens for the StatsD metrics over UDP and aggregates them between collections.
+//
+// The lines "name:value|type[|@rate][|#tag:value,...]" are accepted, a packet may contain several lines.
+// The types are mapped to the metric types:
+//   - c - counter, the value divided by the sample rate is added to the delta;
+//   - g - gauge, the value with the sign is added to the current value, the gauge is kept between collections
+//     until it is not updated for statsdGaugeIdleCollections collections;
+//   - ms, h - histogram of the timings in milliseconds, the observation is counted 1/rate times;
+//   - s - set of the unique values.
+//
+// The tags become the labels. Collect returns the gauges and the counters, histograms and sets aggregated
+// since the previous collection. The malformed lines are counted by StatsdErrors, the new metrics
+// beyond maxStatsdMetrics are dropped and counted by StatsdDropped.
+type statsdCollector struct {
+	conn     net.PacketConn
+	counters map[string]*statsdCounter
+	gauges   map[string]*statsdGauge
+	timers   map[string]*model.Metric
+	sets     map[string]*model.Metric
+	done     chan struct{}
+	errors   int64
+	dropped  int64
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// NewStatsdCollector returns the collector listening on the configured address, the config must be valid.
+//
+// The listener is stopped by Close.
+func NewStatsdCollector(interval time.Duration, cfg StatsdConfig) (Collector, error) {
+	conn, err := net.ListenPacket("udp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+	}
+	c := newStatsdCollector(interval)
+	c.conn = conn
+	go c.listen()
+	return c, nil
+}
+
+// newStatsdCollector returns the collector without the listener
+func newStatsdCollector(interval time.Duration) *statsdCollector {
+	return &statsdCollector{
+		counters: make(map[string]*statsdCounter),
+		gauges:   make(map[string]*statsdGauge),
+		timers:   make(map[string]*model.Metric),
+		sets:     make(map[string]*model.Metric),
+		done:     make(chan struct{}),
+		interval: interval,
+	}
+}
+
+// Name returns the name of the collector
+func (c *statsdCollector) Name() string {
+	return StatsdCollectorName
+}
+
+// Interval returns the minimum interval between collections
+func (c *statsdCollector) Interval() time.Duration {
+	return c.interval
+}
+
+// Addr returns the address of the listener
+func (c *statsdCollector) Addr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// Close stops the listener
+func (c *statsdCollector) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	if err != nil {
+		return fmt.Errorf("failed to close statsd listener: %w", err)
+	}
+	return nil
+}
+
+// listen reads the packets until the connection is closed
+func (c *statsdCollector) listen() {
+	defer close(c.done)
+	buf := make([]byte, maxStatsdPacket)
+	for {
+		n, _, err := c.conn.ReadFrom(buf)
+		if n > 0 {
+			c.handle(buf[:n])
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+// handle aggregates the lines of the packet
+func (c *statsdCollector) handle(packet []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for line := range strings.SplitSeq(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := c.add(line); errors.Is(err, errStatsdFull) {
+			c.dropped++
+		} else if err != nil {
+			c.errors++
+		}
+	}
+}
+
+// add aggregates the line
+func (c *statsdCollector) add(line string) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return errors.New("no name")
+	}
+	if err := model.ValidateID(name); err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	parts := strings.Split(rest, "|")
+	const minParts = 2
+	if len(parts) < minParts {
+		return errors.New("no type")
+	}
+	value, typ := parts[0], parts[1]
+	rate := 1.0
+	var labels model.Labels
+	for _, opt := range parts[minParts:] {
+		var err error
+		switch {
+		case strings.HasPrefix(opt, "@"):
+			rate, err = strconv.ParseFloat(opt[1:], 64)
+			if err == nil && (rate <= 0 || rate > 1) {
+				err = errors.New("sample rate out of range")
+			}
+		case strings.HasPrefix(opt, "#"):
+			labels, err = parseStatsdTags(opt[1:])
+		default:
+			err = fmt.Errorf("unknown option %q", opt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	key := model.Metric{ID: name, Labels: labels}
+	switch typ {
+	case "c":
+		return c.addCounter(key, value, rate)
+	case "g":
+		return c.addGauge(key, value)
+	case "ms", "h":
+		return c.addTimer(key, value, rate)
+	case "s":
+		return c.addSet(key, value)
+	}
+	return fmt.Errorf("unknown type %q", typ)
+}
+
+// parseStatsdTags parses the tags "name:value,name" into the labels, the tag without the value has the empty value
+func parseStatsdTags(tags string) (model.Labels, error) {
+	labels := make(model.Labels)
+	for tag := range strings.SplitSeq(tags, ",") {
+		name, value, _ := strings.Cut(tag, ":")
+		labels[name] = value
+	}
+	if err := labels.Validate(); err != nil {
+		//nolint:wrapcheck // ignore
+		return nil, err
+	}
+	return labels, nil
+}
+
+// full returns true if no more metrics can be aggregated
+func (c *statsdCollector) full() bool {
+	return len(c.counters)+len(c.gauges)+len(c.timers)+len(c.sets) >= maxStatsdMetrics
+}
+
+// addCounter adds the sampled value to the counter
+func (c *statsdCollector) addCounter(key model.Metric, value string, rate float64) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	counter, ok := c.counters[key.Key()]
+	if !ok {
+		if c.full() {
+			return errStatsdFull
+		}
+		m := model.NewMetricCounter(key.ID, 0)
+		m.Labels = key.Labels
+		counter = &statsdCounter{metric: m}
+		c.counters[key.Key()] = counter
+	}
+	counter.value += v / rate
+	return nil
+}
+
+// addGauge sets the gauge, the value with the sign is added to the current value
+func (c *statsdCollector) addGauge(key model.Metric, value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	gauge, ok := c.gauges[key.Key()]
+	if !ok {
+		if c.full() {
+			return errStatsdFull
+		}
+		m := model.NewMetricGauge(key.ID, 0)
+		m.Labels = key.Labels
+		gauge = &statsdGauge{metric: m}
+		c.gauges[key.Key()] = gauge
+	}
+	gauge.idle = 0
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+		*gauge.metric.Value += v
+	} else {
+		*gauge.metric.Value = v
+	}
+	return nil
+}
+
+// addTimer counts the timing in the histogram 1/rate times
+func (c *statsdCollector) addTimer(key model.Metric, value string, rate float64) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	timer, ok := c.timers[key.Key()]
+	if !ok {
+		if c.full() {
+			return errStatsdFull
+		}
+		timer = model.NewMetricHistogram(key.ID, model.NewHistogram(statsdTimerBounds))
+		timer.Labels = key.Labels
+		c.timers[key.Key()] = timer
+	}
+	n := math.Round(1 / rate)
+	timer.Histogram.Counts[sort.SearchFloat64s(timer.Histogram.Bounds, v)] += uint64(n)
+	timer.Histogram.Sum += v * n
+	return nil
+}
+
+// addSet adds the value to the set
+func (c *statsdCollector) addSet(key model.Metric, value string) error {
+	set, ok := c.sets[key.Key()]
+	if !ok {
+		if c.full() {
+			return errStatsdFull
+		}
+		set = model.NewMetricSet(key.ID, hll.New(hll.DefaultPrecision))
+		set.Labels = key.Labels
+		c.sets[key.Key()] = set
+	}
+	set.Set.Add(value)
+	return nil
+}
+
+// Collect returns the gauges and the metrics aggregated since the previous collection,
+// the gauges which are not updated for statsdGaugeIdleCollections collections are returned the last time
+func (c *statsdCollector) Collect(context.Context) ([]*model.Metric, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := make([]*model.Metric, 0, len(c.counters)+len(c.gauges)+len(c.timers)+len(c.sets)+2)
+	for key, counter := range c.counters {
+		delta := math.Round(counter.value)
+		counter.value -= delta
+		m := counter.metric.Clone()
+		*m.Delta = int64(delta)
+		data = append(data, m)
+		if counter.value == 0 {
+			delete(c.counters, key)
+		}
+	}
+	for key, gauge := range c.gauges {
+		data = append(data, gauge.metric.Clone())
+		if gauge.idle++; gauge.idle >= statsdGaugeIdleCollections {
+			delete(c.gauges, key)
+		}
+	}
+	for _, timer := range c.timers {
+		data = append(data, timer)
+	}
+	clear(c.timers)
+	for _, set := range c.sets {
+		data = append(data, set)
+	}
+	clear(c.sets)
+	data = append(data, model.NewMetricCounter(statsdErrorsID, c.errors), model.NewMetricCounter(statsdDroppedID, c.dropped))
+	c.errors, c.dropped = 0, 0
+	return data, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdCollector_Collect(t *testing.T) {
+	c := newStatsdCollector(0)
+	c.handle([]byte("requests:1|c\nrequests:2|c|@0.5\nrequests:1|c|#route:/api\n" +
+		"temp:20|g\ntemp:+3|g\ntemp:-1.5|g\n" +
+		"latency:7|ms\nlatency:300|ms|@0.5\nlatency:20|h|#route:/api\n" +
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n"))
+	c.handle([]byte("bad\nrequests:x|c\nrequests:1|z\nrequests:1|c|@2\nrequests:1|c|#bad-tag:1\n" +
+		"requests{route=\"/api\"}:1|c\n"))
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	got := make(map[string]*model.Metric, len(data))
+	for _, m := range data {
+		got[m.MType+":"+m.Key()] = m
+	}
+	require.Len(t, got, 8)
+	assert.Equal(t, int64(5), *got["counter:requests"].Delta)
+	assert.Equal(t, int64(1), *got[`counter:requests{route="/api"}`].Delta)
+	assert.Equal(t, 21.5, *got["gauge:temp"].Value)
+	assert.Equal(t, []uint64{0, 1, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0}, got["histogram:latency"].Histogram.Counts)
+	assert.Equal(t, 607.0, got["histogram:latency"].Histogram.Sum)
+	assert.Equal(t, uint64(1), got[`histogram:latency{route="/api"}`].Histogram.Count())
+	assert.Equal(t, uint64(2), got["set:users"].Set.Count())
+	assert.Equal(t, int64(6), *got["counter:StatsdErrors"].Delta)
+	assert.Equal(t, int64(0), *got["counter:StatsdDropped"].Delta)
+
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, data, 3, "only the gauge and the error counters are kept")
+	assert.Equal(t, "temp", data[0].ID)
+	assert.Equal(t, int64(0), *data[1].Delta)
+	assert.Equal(t, int64(0), *data[2].Delta)
+}
+
+func TestStatsdCollector_CounterFraction(t *testing.T) {
+	c := newStatsdCollector(0)
+	c.handle([]byte("hits:1|c|@0.3"))
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *data[0].Delta)
+	c.handle([]byte("hits:1|c|@0.3"))
+	data, err = c.Collect(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), *data[0].Delta, "the fractional part is carried over")
+}
+
+func TestStatsdCollector_TooManyMetrics(t *testing.T) {
+	c := newStatsdCollector(0)
+	for i := range maxStatsdMetrics {
+		require.NoError(t, c.add(fmt.Sprintf("g%d:1|g", i)))
+	}
+	require.ErrorIs(t, c.add("one_more:1|c"), errStatsdFull)
+	require.NoError(t, c.add("g0:2|g"), "the existing metric is updated")
+	c.handle([]byte("one_more:1|c\nbad\n"))
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	got := keyed(data)
+	assert.Equal(t, "1", got["counter:StatsdErrors"])
+	assert.Equal(t, "1", got["counter:StatsdDropped"])
+}
+
+func TestStatsdCollector_GaugeEviction(t *testing.T) {
+	c := newStatsdCollector(0)
+	c.handle([]byte("idle:1|g\nbusy:1|g"))
+	for i := range statsdGaugeIdleCollections {
+		c.handle([]byte("busy:2|g"))
+		data, err := c.Collect(context.TODO())
+		require.NoError(t, err)
+		assert.Contains(t, keyed(data), "gauge:idle", "collection %d", i)
+	}
+	c.handle([]byte("busy:3|g"))
+	data, err := c.Collect(context.TODO())
+	require.NoError(t, err)
+	got := keyed(data)
+	assert.NotContains(t, got, "gauge:idle", "the gauge which is not updated is evicted")
+	assert.Equal(t, "3", got["gauge:busy"])
+}
+
+func TestNewStatsdCollector(t *testing.T) {
+	c, err := NewStatsdCollector(0, StatsdConfig{Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	sc := c.(*statsdCollector)
+	conn, err := net.Dial("udp", sc.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	_, err = conn.Write([]byte("requests:3|c"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		sc.mu.Lock()
+		defer sc.mu.Unlock()
+		return len(sc.counters) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	s := NewSource(c)
+	require.NoError(t, s.Collect(context.TODO()))
+	require.NoError(t, s.Close())
+	data, _ := s.Get()
+	assert.Equal(t, "requests", data[0].ID)
+	assert.Equal(t, int64(3), *data[0].Delta)
+
+	_, err = NewStatsdCollector(0, StatsdConfig{Addr: "bad address"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// TelemetryCollectorName is the name of the collector of the agent self-telemetry
+const TelemetryCollectorName = "agent"
+
+// TelemetryPrefix is the reserved prefix of the IDs of the agent self-telemetry metrics
+const TelemetryPrefix = "agent_"
+
+// the IDs of the agent self-telemetry metrics
+const (
+	telemetrySendsAttemptedID  = TelemetryPrefix + "sends_attempted"
+	telemetrySendsSucceededID  = TelemetryPrefix + "sends_succeeded"
+	telemetrySendsFailedID     = TelemetryPrefix + "sends_failed"
+	telemetrySendRetriesID     = TelemetryPrefix + "send_retries"
+	telemetrySendLatencyID     = TelemetryPrefix + "send_latency_seconds"
+	telemetryBatchSizeID       = TelemetryPrefix + "batch_size"
+	telemetryCollectDurationID = TelemetryPrefix + "collect_duration_seconds"
+	telemetryPollBacklogID     = TelemetryPrefix + "poll_backlog"
+	telemetryBreakerStateID    = TelemetryPrefix + "breaker_state"
+)
+
+var (
+	// telemetryLatencyBounds are the bucket bounds of the send latency histogram in seconds
+	telemetryLatencyBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// telemetryBatchSizeBounds are the bucket bounds of the batch size histogram
+	telemetryBatchSizeBounds = []float64{1, 10, 50, 100, 500, 1000, 5000, 10000}
+)
+
+// Telemetry is the collector of the agent self-telemetry.
+//
+// The sender records the sends and the retries, the source records the collection durations
+// and the uncommitted PollCount. Collect returns the counters and histograms recorded since
+// the previous collection and the gauges of the last recorded values, the collection durations
+// are the durations of the previous poll labeled with the collector, the circuit breaker states
+// are labeled with the server.
+type Telemetry struct {
+	latency          *model.Histogram
+	batchSize        *model.Histogram
+	collectDurations map[string]time.Duration
+	breakerStates    map[string]int
+	attempted        int64
+	succeeded        int64
+	failed           int64
+	retries          int64
+	pollBacklog      int64
+	mu               sync.Mutex
+}
+
+// NewTelemetry returns the collector of the agent self-telemetry
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		latency:          model.NewHistogram(telemetryLatencyBounds),
+		batchSize:        model.NewHistogram(telemetryBatchSizeBounds),
+		collectDurations: make(map[string]time.Duration),
+		breakerStates:    make(map[string]int),
+	}
+}
+
+// Name returns the name of the collector
+func (t *Telemetry) Name() string {
+	return TelemetryCollectorName
+}
+
+// Interval returns 0, the telemetry is collected on every poll
+func (t *Telemetry) Interval() time.Duration {
+	return 0
+}
+
+// RecordSend records the send of the metrics which took d and failed with err if it is not nil
+func (t *Telemetry) RecordSend(metrics int, d time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attempted++
+	if err != nil {
+		t.failed++
+	} else {
+		t.succeeded++
+	}
+	t.latency.Observe(d.Seconds())
+	t.batchSize.Observe(float64(metrics))
+}
+
+// RecordRetry records the retry of the request
+func (t *Telemetry) RecordRetry() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retries++
+}
+
+// RecordBreakerState records the state of the circuit breaker of the server: 0 - closed, 1 - half-open, 2 - open
+func (t *Telemetry) RecordBreakerState(server string, state int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.breakerStates[server] = state
+}
+
+// recordCollect records the duration of the collection of the collector
+func (t *Telemetry) recordCollect(collector string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.collectDurations[collector] = d
+}
+
+// recordPollBacklog records the number of the polls which are not committed yet
+func (t *Telemetry) recordPollBacklog(polls int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pollBacklog = polls
+}
+
+// Collect returns the telemetry recorded since the previous collection
+func (t *Telemetry) Collect(context.Context) ([]*model.Metric, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data := make([]*model.Metric, 0, 7+len(t.collectDurations)+len(t.breakerStates)) //nolint:mnd // the metrics without labels
+	data = append(data,
+		model.NewMetricCounter(telemetrySendsAttemptedID, t.attempted),
+		model.NewMetricCounter(telemetrySendsSucceededID, t.succeeded),
+		model.NewMetricCounter(telemetrySendsFailedID, t.failed),
+		model.NewMetricCounter(telemetrySendRetriesID, t.retries),
+		model.NewMetricHistogram(telemetrySendLatencyID, t.latency),
+		model.NewMetricHistogram(telemetryBatchSizeID, t.batchSize),
+		model.NewMetricGauge(telemetryPollBacklogID, float64(t.pollBacklog)),
+	)
+	for _, collector := range slices.Sorted(maps.Keys(t.collectDurations)) {
+		m := model.NewMetricGauge(telemetryCollectDurationID, t.collectDurations[collector].Seconds())
+		m.Labels = model.Labels{"collector": collector}
+		data = append(data, m)
+	}
+	for _, server := range slices.Sorted(maps.Keys(t.breakerStates)) {
+		m := model.NewMetricGauge(telemetryBreakerStateID, float64(t.breakerStates[server]))
+		m.Labels = model.Labels{"server": server}
+		data = append(data, m)
+	}
+	t.attempted, t.succeeded, t.failed, t.retries = 0, 0, 0, 0
+	t.latency = model.NewHistogram(telemetryLatencyBounds)
+	t.batchSize = model.NewHistogram(telemetryBatchSizeBounds)
+	return data, nil
+}
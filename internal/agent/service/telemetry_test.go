@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetry_Collect(t *testing.T) {
+	tm := NewTelemetry()
+	tm.RecordSend(10, 20*time.Millisecond, nil)
+	tm.RecordSend(1, 2*time.Second, errors.New("failed"))
+	tm.RecordRetry()
+	tm.recordCollect("runtime", 250*time.Millisecond)
+	tm.recordPollBacklog(3)
+	tm.RecordBreakerState("localhost:8080", 2)
+
+	data, err := tm.Collect(context.Background())
+	require.NoError(t, err)
+	got := keyed(data)
+	assert.Equal(t, "2", got["counter:agent_sends_attempted"])
+	assert.Equal(t, "1", got["counter:agent_sends_succeeded"])
+	assert.Equal(t, "1", got["counter:agent_sends_failed"])
+	assert.Equal(t, "1", got["counter:agent_send_retries"])
+	assert.Equal(t, "3", got["gauge:agent_poll_backlog"])
+	assert.Equal(t, "0.25", got[`gauge:agent_collect_duration_seconds{collector="runtime"}`])
+	assert.Equal(t, "2", got[`gauge:agent_breaker_state{server="localhost:8080"}`])
+	for _, m := range data {
+		switch m.ID {
+		case telemetrySendLatencyID:
+			assert.Equal(t, uint64(2), m.Histogram.Count())
+			assert.InDelta(t, 2.02, m.Histogram.Sum, 1e-9)
+		case telemetryBatchSizeID:
+			assert.Equal(t, uint64(2), m.Histogram.Count())
+			assert.InDelta(t, 11.0, m.Histogram.Sum, 1e-9)
+		}
+	}
+
+	data, err = tm.Collect(context.Background())
+	require.NoError(t, err)
+	got = keyed(data)
+	assert.Equal(t, "0", got["counter:agent_sends_attempted"], "the counters are reset by the collection")
+	assert.Equal(t, "3", got["gauge:agent_poll_backlog"], "the gauges are kept")
+	for _, m := range data {
+		if m.MType == model.TypeHistogram {
+			assert.Zero(t, m.Histogram.Count(), m.ID)
+		}
+	}
+}
+
+func TestSource_Telemetry(t *testing.T) {
+	tm := NewTelemetry()
+	s := NewSource(&stubCollector{name: "stub", data: []*model.Metric{model.NewMetricGauge("g", 1)}}, tm)
+	s.SetTelemetry(tm)
+	require.NoError(t, s.Collect(context.Background()))
+	require.NoError(t, s.Collect(context.Background()))
+	tm.RecordSend(2, time.Millisecond, nil)
+
+	data, delta := s.Get()
+	got := keyed(data)
+	assert.Equal(t, "2", got["gauge:agent_poll_backlog"])
+	assert.Contains(t, got, `gauge:agent_collect_duration_seconds{collector="stub"}`)
+	assert.Contains(t, got, `gauge:agent_collect_duration_seconds{collector="agent"}`)
+	s.Commit(delta)
+
+	require.NoError(t, s.Collect(context.Background()))
+	data, _ = s.Get()
+	got = keyed(data)
+	assert.Equal(t, "1", got["gauge:agent_poll_backlog"], "the committed polls are not in the backlog")
+	assert.Equal(t, "1", got["counter:agent_sends_attempted"])
+
+	for _, meta := range s.Meta() {
+		if meta.ID == telemetryPollBacklogID {
+			return
+		}
+	}
+	t.Error("no meta of the telemetry")
+}
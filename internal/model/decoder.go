@@ -0,0 +1,179 @@
+package model
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxProtoMetricSize is the maximum size of the encoded metric in the protobuf batch
+const maxProtoMetricSize = 1 << 24
+
+// MetricsRequestDecoder decodes the batch of the metric requests element by element,
+// so the whole batch is never held in memory
+type MetricsRequestDecoder struct {
+	next     func() (*MetricRequest, error)
+	maxCount int
+	count    int
+	done     bool
+}
+
+// NewMetricsRequestDecoder returns the decoder of the JSON array of the metric requests.
+//
+// If maxCount is positive, the batch with more elements is rejected with ErrTooManyMetrics.
+func NewMetricsRequestDecoder(r io.Reader, maxCount int) *MetricsRequestDecoder {
+	return &MetricsRequestDecoder{next: jsonMetricsNext(json.NewDecoder(r)), maxCount: maxCount}
+}
+
+// NewMetricsRequestProtoDecoder returns the decoder of the protobuf batch of the metric requests.
+//
+// If maxCount is positive, the batch with more elements is rejected with ErrTooManyMetrics.
+func NewMetricsRequestProtoDecoder(r io.Reader, maxCount int) *MetricsRequestDecoder {
+	return &MetricsRequestDecoder{next: protoMetricsNext(bufio.NewReader(r)), maxCount: maxCount}
+}
+
+// Next returns the next validated metric request or io.EOF after the last one.
+//
+// The empty batch is an error.
+func (d *MetricsRequestDecoder) Next() (*MetricRequest, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	mr, err := d.next()
+	if errors.Is(err, io.EOF) {
+		d.done = true
+		if d.count == 0 {
+			return nil, errors.New("empty body")
+		}
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	d.count++
+	if d.maxCount > 0 && d.count > d.maxCount {
+		return nil, fmt.Errorf("%w: more than %d", ErrTooManyMetrics, d.maxCount)
+	}
+	if err = mr.RequiredValue(); err != nil {
+		return nil, fmt.Errorf("metric %d: %w", d.count-1, err)
+	}
+	return mr, nil
+}
+
+// Count returns the number of the decoded metric requests
+func (d *MetricsRequestDecoder) Count() int {
+	return d.count
+}
+
+// jsonMetricsNext returns the function decoding the next element of the JSON array
+func jsonMetricsNext(dec *json.Decoder) func() (*MetricRequest, error) {
+	started := false
+	return func() (*MetricRequest, error) {
+		if !started {
+			tok, err := dec.Token()
+			if err != nil {
+				//nolint:wrapcheck // ignore
+				return nil, err
+			}
+			if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+				return nil, errors.New("array expected")
+			}
+			started = true
+		}
+		if !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return nil, fmt.Errorf("failed to read the end of array: %w", err)
+			}
+			if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+				return nil, errors.New("unexpected data after array")
+			}
+			return nil, io.EOF
+		}
+		var mr *MetricRequest
+		if err := dec.Decode(&mr); err != nil {
+			return nil, noEOF(err)
+		}
+		if mr == nil || mr.Metric == nil {
+			return nil, ErrMetricNotFound
+		}
+		return mr, nil
+	}
+}
+
+// protoMetricsNext returns the function decoding the next metric of the protobuf batch,
+// the unknown fields of the batch are skipped
+func protoMetricsNext(r *bufio.Reader) func() (*MetricRequest, error) {
+	const metricsField = 1
+	return func() (*MetricRequest, error) {
+		for {
+			tag, err := binary.ReadUvarint(r)
+			if err != nil {
+				//nolint:wrapcheck // ignore
+				return nil, err
+			}
+			num, typ := protowire.DecodeTag(tag)
+			if num != metricsField || typ != protowire.BytesType {
+				if err = skipProtoField(r, typ); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+			if size > maxProtoMetricSize {
+				return nil, fmt.Errorf("metric size %d exceeds %d", size, maxProtoMetricSize)
+			}
+			data := make([]byte, size)
+			if _, err = io.ReadFull(r, data); err != nil {
+				return nil, noEOF(err)
+			}
+			p := &pb.Metric{}
+			if err = proto.Unmarshal(data, p); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metric: %w", err)
+			}
+			m, err := MetricFromProto(p)
+			if err != nil {
+				return nil, err
+			}
+			return &MetricRequest{m}, nil
+		}
+	}
+}
+
+// skipProtoField skips the value of the field of the given type
+func skipProtoField(r *bufio.Reader, typ protowire.Type) error {
+	var err error
+	switch typ {
+	case protowire.VarintType:
+		_, err = binary.ReadUvarint(r)
+	case protowire.Fixed32Type:
+		_, err = r.Discard(4) //nolint:mnd // ignore
+	case protowire.Fixed64Type:
+		_, err = r.Discard(8) //nolint:mnd // ignore
+	case protowire.BytesType:
+		var size uint64
+		if size, err = binary.ReadUvarint(r); err == nil {
+			_, err = io.CopyN(io.Discard, r, int64(size))
+		}
+	default:
+		return fmt.Errorf("unsupported wire type %d", typ)
+	}
+	return noEOF(err)
+}
+
+// noEOF replaces io.EOF in the middle of the element with io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
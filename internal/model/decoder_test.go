@@ -0,0 +1,122 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeAll returns all metric requests of the decoder or the first error
+func decodeAll(dec *MetricsRequestDecoder) ([]*MetricRequest, error) {
+	var mrs []*MetricRequest
+	for {
+		mr, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return mrs, nil
+		}
+		if err != nil {
+			return mrs, err
+		}
+		mrs = append(mrs, mr)
+	}
+}
+
+func TestMetricsRequestDecoder_JSON(t *testing.T) {
+	tests := []struct {
+		wantErr  error
+		name     string
+		body     string
+		want     []*MetricRequest
+		maxCount int
+		isErr    bool
+	}{
+		{
+			name: "valid",
+			body: `[{"type":"gauge","id":"g","value":1.5}, {"type":"counter","id":"c","delta":2}]`,
+			want: []*MetricRequest{{NewMetricGauge("g", 1.5)}, {NewMetricCounter("c", 2)}},
+		},
+		{
+			name:     "limit",
+			body:     `[{"type":"gauge","id":"g","value":1.5},{"type":"counter","id":"c","delta":2}]`,
+			maxCount: 2,
+			want:     []*MetricRequest{{NewMetricGauge("g", 1.5)}, {NewMetricCounter("c", 2)}},
+		},
+		{
+			name:     "too many",
+			body:     `[{"type":"gauge","id":"g","value":1.5},{"type":"counter","id":"c","delta":2}]`,
+			maxCount: 1,
+			wantErr:  ErrTooManyMetrics,
+		},
+		{name: "invalid value", body: `[{"type":"counter","id":"c"}]`, wantErr: ErrValueIsNotValid},
+		{name: "invalid type", body: `[{"type":"invalid","id":"c","delta":1}]`, wantErr: ErrTypeIsNotValid},
+		{name: "null", body: `[null]`, wantErr: ErrMetricNotFound},
+		{name: "empty body", body: ``, isErr: true},
+		{name: "empty array", body: `[]`, isErr: true},
+		{name: "object", body: `{"type":"counter","id":"c","delta":1}`, isErr: true},
+		{name: "unterminated", body: `[{"type":"counter","id":"c","delta":1}`, isErr: true},
+		{name: "trailing data", body: `[{"type":"counter","id":"c","delta":1}] []`, isErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeAll(NewMetricsRequestDecoder(strings.NewReader(tt.body), tt.maxCount))
+			if tt.wantErr != nil || tt.isErr {
+				require.Error(t, err)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMetricsRequestDecoder_Proto(t *testing.T) {
+	ms := []*Metric{NewMetricGauge("g", 1.5), NewMetricCounter("c", 2)}
+	batch, err := MetricsToProto(ms)
+	require.NoError(t, err)
+	body, err := proto.Marshal(batch)
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(body), 0))
+		require.NoError(t, err)
+		assert.Equal(t, []*MetricRequest{{ms[0]}, {ms[1]}}, got)
+	})
+	t.Run("unknown field is skipped", func(t *testing.T) {
+		data := protowire.AppendTag(nil, 2, protowire.BytesType)
+		data = protowire.AppendBytes(data, []byte("unknown"))
+		data = protowire.AppendTag(data, 3, protowire.VarintType)
+		data = protowire.AppendVarint(data, 1)
+		got, err := decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(append(data, body...)), 0))
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+	})
+	t.Run("too many", func(t *testing.T) {
+		_, err := decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(body), 1))
+		require.ErrorIs(t, err, ErrTooManyMetrics)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		_, err := decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(body[:len(body)-1]), 0))
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+	t.Run("empty", func(t *testing.T) {
+		_, err := decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(nil), 0))
+		require.Error(t, err)
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		data, err := proto.Marshal(&pb.MetricBatch{Metrics: []*pb.Metric{{Id: "c", Type: TypeCounter}}})
+		require.NoError(t, err)
+		_, err = decodeAll(NewMetricsRequestProtoDecoder(bytes.NewReader(data), 0))
+		require.ErrorIs(t, err, ErrValueIsNotValid)
+	})
+}
@@ -7,4 +7,11 @@ var (
 	ErrMetricAlreadyExist = errors.New("metric already exist")
 	ErrTypeIsNotValid     = errors.New("type is not valid")
 	ErrValueIsNotValid    = errors.New("value is not valid")
+	ErrBoundsMismatch     = errors.New("histogram bounds mismatch")
+	ErrBoundsRequired     = errors.New("histogram bounds are required")
+	ErrLabelsIsNotValid   = errors.New("labels is not valid")
+	ErrIDIsNotValid       = errors.New("id is not valid")
+	ErrOutOfOrder         = errors.New("sample is out of order")
+	ErrMetaNotFound       = errors.New("metadata not found")
+	ErrTooManyMetrics     = errors.New("too many metrics")
 )
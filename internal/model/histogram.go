@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Histogram - histogram value structure
+type Histogram struct {
+	Bounds []float64 `json:"bounds"` // верхние границы бакетов в порядке возрастания
+	Counts []uint64  `json:"counts"` // количество наблюдений в бакетах, последний бакет - +Inf
+	Sum    float64   `json:"sum"`    // сумма всех наблюдений
+}
+
+// NewHistogram returns a new empty histogram with the given bucket bounds
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds the value to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, v)]++
+	h.Sum += v
+}
+
+// Count returns the total number of observations
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	return count
+}
+
+// Clone returns a copy of the histogram
+func (h *Histogram) Clone() *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum,
+	}
+}
+
+// Validate returns an error if the histogram is not consistent
+func (h *Histogram) Validate() error {
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("%w: histogram has %d bounds and %d counts", ErrValueIsNotValid, len(h.Bounds), len(h.Counts))
+	}
+	for i := 1; i < len(h.Bounds); i++ {
+		if h.Bounds[i] <= h.Bounds[i-1] {
+			return fmt.Errorf("%w: histogram bounds must be in increasing order", ErrValueIsNotValid)
+		}
+	}
+	return nil
+}
+
+// Merge adds the bucket counts and the sum of the given histogram
+func (h *Histogram) Merge(o *Histogram) error {
+	if !slices.Equal(h.Bounds, o.Bounds) || len(h.Counts) != len(o.Counts) {
+		return fmt.Errorf("%w: %w", ErrValueIsNotValid, ErrBoundsMismatch)
+	}
+	for i := range h.Counts {
+		h.Counts[i] += o.Counts[i]
+	}
+	h.Sum += o.Sum
+	return nil
+}
+
+// String returns the JSON representation of the histogram
+func (h *Histogram) String() string {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Value implements driver.Valuer, the histogram is stored as JSON
+func (h *Histogram) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal histogram: %w", err)
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner, the histogram is stored as JSON
+func (h *Histogram) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported histogram source type %T", src)
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return fmt.Errorf("failed to unmarshal histogram: %w", err)
+	}
+	return nil
+}
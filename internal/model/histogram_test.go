@@ -0,0 +1,94 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{1, 5, 10})
+	for _, v := range []float64{0.5, 1, 3, 7, 10, 100} {
+		h.Observe(v)
+	}
+	assert.Equal(t, []uint64{2, 1, 2, 1}, h.Counts)
+	assert.InDelta(t, 121.5, h.Sum, 1e-9)
+	assert.Equal(t, uint64(6), h.Count())
+}
+
+func TestHistogram_Validate(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		histogram *Histogram
+		name      string
+	}{
+		{
+			name:      "valid",
+			histogram: &Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Sum: 4},
+		},
+		{
+			name:      "without bounds",
+			histogram: &Histogram{Counts: []uint64{1}, Sum: 4},
+		},
+		{
+			name:      "counts length",
+			histogram: &Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2}},
+			wantErr:   ErrValueIsNotValid,
+		},
+		{
+			name:      "bounds order",
+			histogram: &Histogram{Bounds: []float64{2, 1}, Counts: []uint64{1, 2, 3}},
+			wantErr:   ErrValueIsNotValid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.histogram.Validate()
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	t.Run("same bounds", func(t *testing.T) {
+		h := &Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Sum: 4}
+		err := h.Merge(&Histogram{Bounds: []float64{1, 2}, Counts: []uint64{3, 2, 1}, Sum: 6})
+		require.NoError(t, err)
+		assert.Equal(t, &Histogram{Bounds: []float64{1, 2}, Counts: []uint64{4, 4, 4}, Sum: 10}, h)
+	})
+
+	t.Run("bounds mismatch", func(t *testing.T) {
+		h := &Histogram{Bounds: []float64{1, 2}, Counts: []uint64{1, 2, 3}, Sum: 4}
+		err := h.Merge(&Histogram{Bounds: []float64{1, 3}, Counts: []uint64{3, 2, 1}, Sum: 6})
+		assert.ErrorIs(t, err, ErrBoundsMismatch)
+		assert.ErrorIs(t, err, ErrValueIsNotValid)
+		assert.Equal(t, []uint64{1, 2, 3}, h.Counts)
+	})
+}
+
+func TestHistogram_ValueScan(t *testing.T) {
+	h := &Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 2}, Sum: 5.05}
+	v, err := h.Value()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(h.String()), v)
+
+	got := &Histogram{}
+	require.NoError(t, got.Scan(v))
+	assert.Equal(t, h, got)
+
+	got = &Histogram{}
+	require.NoError(t, got.Scan(h.String()))
+	assert.Equal(t, h, got)
+
+	assert.Error(t, got.Scan(1))
+
+	var null *Histogram
+	v, err = null.Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// labelNameRe is the pattern of the valid label name
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels - metric labels (dimensions), part of the metric identity
+type Labels map[string]string
+
+// Key returns the canonical representation of the labels: {a="1",b="2"} with sorted names.
+//
+// It returns an empty string for empty labels.
+func (l Labels) Key() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := slices.Sorted(maps.Keys(l))
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(l[name]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Validate returns an error if a label name is not valid
+func (l Labels) Validate() error {
+	for name := range l {
+		if !labelNameRe.MatchString(name) {
+			return fmt.Errorf("%w: invalid label name %q", ErrLabelsIsNotValid, name)
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of the labels
+func (l Labels) Clone() Labels {
+	if l == nil {
+		return nil
+	}
+	return maps.Clone(l)
+}
+
+// Value implements driver.Valuer, the labels are stored as JSON
+func (l Labels) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner, the labels are stored as JSON
+func (l *Labels) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported labels source type %T", src)
+	}
+	*l = nil
+	if err := json.Unmarshal(data, (*map[string]string)(l)); err != nil {
+		return fmt.Errorf("failed to unmarshal labels: %w", err)
+	}
+	if len(*l) == 0 {
+		*l = nil
+	}
+	return nil
+}
+
+// MatchType - type of the label matcher
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// LabelMatcher - matcher of the label value
+//
+// A missing label matches as an empty value, so host="" selects metrics without the host label.
+type LabelMatcher struct {
+	re    *regexp.Regexp
+	Name  string
+	Value string
+	Type  MatchType
+}
+
+// NewLabelMatcher returns a new label matcher
+func NewLabelMatcher(t MatchType, name, value string) (*LabelMatcher, error) {
+	lm := &LabelMatcher{Name: name, Value: value, Type: t}
+	if !labelNameRe.MatchString(name) {
+		return nil, fmt.Errorf("%w: invalid label name %q", ErrLabelsIsNotValid, name)
+	}
+	switch t {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrLabelsIsNotValid, err)
+		}
+		lm.re = re
+	default:
+		return nil, fmt.Errorf("%w: invalid match type %q", ErrLabelsIsNotValid, t)
+	}
+	return lm, nil
+}
+
+// ParseLabelMatcher parses the label matcher from the string like name=value, name!=value,
+// name=~regexp or name!~regexp, the value can be quoted.
+func ParseLabelMatcher(s string) (*LabelMatcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: invalid matcher %q", ErrLabelsIsNotValid, s)
+	}
+	name, rest := s[:i], s[i:]
+	var t MatchType
+	for _, mt := range []MatchType{MatchNotEqual, MatchRegexp, MatchNotRegexp, MatchEqual} {
+		if strings.HasPrefix(rest, string(mt)) {
+			t = mt
+			break
+		}
+	}
+	if t == "" {
+		return nil, fmt.Errorf("%w: invalid matcher %q", ErrLabelsIsNotValid, s)
+	}
+	value := rest[len(t):]
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	return NewLabelMatcher(t, name, value)
+}
+
+// Matches returns true if the labels match
+func (lm *LabelMatcher) Matches(l Labels) bool {
+	v := l[lm.Name]
+	switch lm.Type {
+	case MatchEqual:
+		return v == lm.Value
+	case MatchNotEqual:
+		return v != lm.Value
+	case MatchRegexp:
+		return lm.re.MatchString(v)
+	case MatchNotRegexp:
+		return !lm.re.MatchString(v)
+	}
+	return false
+}
+
+// String returns the string representation of the matcher
+func (lm *LabelMatcher) String() string {
+	return lm.Name + string(lm.Type) + strconv.Quote(lm.Value)
+}
+
+// MatchLabels returns true if the labels match all matchers
+func MatchLabels(l Labels, matchers ...*LabelMatcher) bool {
+	for _, lm := range matchers {
+		if !lm.Matches(l) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabels_Key(t *testing.T) {
+	assert.Empty(t, Labels(nil).Key())
+	assert.Empty(t, Labels{}.Key())
+	assert.Equal(t, `{dc="eu",host="h\"1"}`, Labels{"host": `h"1`, "dc": "eu"}.Key())
+
+	m := NewMetricGauge("cpu", 1)
+	assert.Equal(t, "cpu", m.Key())
+	m.Labels = Labels{"core": "0"}
+	assert.Equal(t, `cpu{core="0"}`, m.Key())
+	assert.Equal(t, `{core="0"}`, m.LabelsKey())
+}
+
+func TestMetric_KeyCollision(t *testing.T) {
+	labeled := NewMetricGauge("cpu", 1)
+	labeled.Labels = Labels{"core": "0"}
+	require.NoError(t, (&MetricRequest{labeled}).RequiredValue())
+
+	// the ID with the braces would have the key of the labeled metric
+	collided := NewMetricGauge(`cpu{core="0"}`, 1)
+	require.Equal(t, labeled.Key(), collided.Key())
+	require.ErrorIs(t, (&MetricRequest{collided}).RequiredValue(), ErrIDIsNotValid)
+	_, err := NewMetricRequest(TypeGauge, collided.ID, "1")
+	require.ErrorIs(t, err, ErrIDIsNotValid)
+	_, err = NewHistogramRequest(collided.ID, "1", "1")
+	require.ErrorIs(t, err, ErrIDIsNotValid)
+
+	require.ErrorIs(t, ValidateID("cpu}"), ErrIDIsNotValid)
+	require.NoError(t, ValidateID("cpu.core_0"))
+}
+
+func TestLabels_Validate(t *testing.T) {
+	assert.NoError(t, Labels{"host": "h1", "_core0": ""}.Validate())
+	assert.ErrorIs(t, Labels{"0core": "1"}.Validate(), ErrLabelsIsNotValid)
+	assert.ErrorIs(t, Labels{"": "1"}.Validate(), ErrLabelsIsNotValid)
+
+	mr := &MetricRequest{NewMetricGauge("cpu", 1)}
+	mr.Labels = Labels{"host-name": "h1"}
+	assert.ErrorIs(t, mr.RequiredValue(), ErrLabelsIsNotValid)
+}
+
+func TestLabels_Clone(t *testing.T) {
+	m := NewMetricGauge("cpu", 1)
+	m.Labels = Labels{"host": "h1"}
+	c := m.Clone()
+	assert.Equal(t, m, c)
+	c.Labels["host"] = "h2"
+	assert.Equal(t, "h1", m.Labels["host"])
+}
+
+func TestLabels_ValueScan(t *testing.T) {
+	l := Labels{"host": "h1"}
+	v, err := l.Value()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"host":"h1"}`), v)
+
+	var got Labels
+	require.NoError(t, got.Scan(v))
+	assert.Equal(t, l, got)
+	require.NoError(t, got.Scan(`{}`))
+	assert.Nil(t, got)
+	require.NoError(t, got.Scan(nil))
+	assert.Nil(t, got)
+	assert.Error(t, got.Scan(1))
+
+	v, err = Labels(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestParseLabelMatcher(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		want    *LabelMatcher
+		name    string
+		s       string
+	}{
+		{name: "equal", s: "host=h1", want: &LabelMatcher{Name: "host", Value: "h1", Type: MatchEqual}},
+		{name: "quoted", s: `host="h=1"`, want: &LabelMatcher{Name: "host", Value: "h=1", Type: MatchEqual}},
+		{name: "not equal", s: "host!=", want: &LabelMatcher{Name: "host", Type: MatchNotEqual}},
+		{name: "regexp", s: "host=~h.*", want: &LabelMatcher{Name: "host", Value: "h.*", Type: MatchRegexp}},
+		{name: "not regexp", s: "host!~h.*", want: &LabelMatcher{Name: "host", Value: "h.*", Type: MatchNotRegexp}},
+		{name: "without operator", s: "host", wantErr: ErrLabelsIsNotValid},
+		{name: "invalid operator", s: "host!h1", wantErr: ErrLabelsIsNotValid},
+		{name: "invalid name", s: "1host=h1", wantErr: ErrLabelsIsNotValid},
+		{name: "invalid regexp", s: "host=~(", wantErr: ErrLabelsIsNotValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelMatcher(tt.s)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Name, got.Name)
+			assert.Equal(t, tt.want.Value, got.Value)
+			assert.Equal(t, tt.want.Type, got.Type)
+		})
+	}
+}
+
+func TestLabelMatcher_Matches(t *testing.T) {
+	l := Labels{"host": "h1", "dc": "eu-west"}
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{s: "host=h1", want: true},
+		{s: "host=h2", want: false},
+		{s: "host!=h2", want: true},
+		{s: "dc=~eu-.*", want: true},
+		{s: "dc=~eu", want: false},
+		{s: "dc!~us-.*", want: true},
+		{s: `core=""`, want: true},
+		{s: "core!=", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			lm, err := ParseLabelMatcher(tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, lm.Matches(l))
+		})
+	}
+
+	host, err := NewLabelMatcher(MatchEqual, "host", "h1")
+	require.NoError(t, err)
+	dc, err := NewLabelMatcher(MatchRegexp, "dc", "us-.*")
+	require.NoError(t, err)
+	assert.True(t, MatchLabels(l))
+	assert.True(t, MatchLabels(l, host))
+	assert.False(t, MatchLabels(l, host, dc))
+	assert.Equal(t, `dc=~"us-.*"`, dc.String())
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Meta - metric metadata structure
+type Meta struct {
+	MType       string `json:"type"`                  // тип метрики
+	ID          string `json:"id"`                    // имя метрики
+	Unit        string `json:"unit,omitempty"`        // единица измерения: bytes, percent, ns и т.п.
+	Help        string `json:"help,omitempty"`        // краткое описание метрики
+	Description string `json:"description,omitempty"` // произвольное подробное описание
+}
+
+// Key returns the key identifying the metadata
+func (m *Meta) Key() string {
+	return m.MType + ":" + m.ID
+}
+
+// Clone returns a copy of the metadata
+func (m *Meta) Clone() *Meta {
+	c := *m
+	return &c
+}
+
+// Validate returns an error if the type or the name is not valid
+func (m *Meta) Validate() error {
+	if err := (&MetricRequest{&Metric{MType: m.MType}}).ValidateType(); err != nil {
+		return err
+	}
+	if m.ID == "" {
+		return fmt.Errorf("%w: empty id", ErrIDIsNotValid)
+	}
+	return nil
+}
+
+// NewMeta returns new metadata
+func NewMeta(t, id, unit, help string) *Meta {
+	return &Meta{
+		MType: t,
+		ID:    id,
+		Unit:  unit,
+		Help:  help,
+	}
+}
+
+// UnmarshalMetaFromReader unmarshals the metadata from the reader
+func UnmarshalMetaFromReader(r io.Reader) (*Meta, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty body")
+	}
+	var meta *Meta
+	if err = json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	if meta == nil {
+		return nil, ErrMetaNotFound
+	}
+	return meta, nil
+}
+
+// UnmarshalMetasFromReader unmarshals the list of metadata from the reader
+func UnmarshalMetasFromReader(r io.Reader) ([]*Meta, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty body")
+	}
+	var metas []*Meta
+	if err = json.Unmarshal(body, &metas); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	if len(metas) == 0 {
+		return nil, errors.New("empty body")
+	}
+	for _, m := range metas {
+		if m == nil {
+			return nil, ErrMetaNotFound
+		}
+		if err = m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return metas, nil
+}
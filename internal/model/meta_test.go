@@ -0,0 +1,39 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeta_Validate(t *testing.T) {
+	assert.NoError(t, NewMeta(TypeGauge, "Alloc", "bytes", "").Validate())
+	assert.ErrorIs(t, NewMeta("invalid", "Alloc", "", "").Validate(), ErrTypeIsNotValid)
+	assert.ErrorIs(t, NewMeta(TypeGauge, "", "", "").Validate(), ErrIDIsNotValid)
+}
+
+func TestUnmarshalMetaFromReader(t *testing.T) {
+	got, err := UnmarshalMetaFromReader(strings.NewReader(`{"unit":"ns","help":"GC pause","description":"Total"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &Meta{Unit: "ns", Help: "GC pause", Description: "Total"}, got)
+
+	_, err = UnmarshalMetaFromReader(strings.NewReader(""))
+	assert.Error(t, err)
+	_, err = UnmarshalMetaFromReader(strings.NewReader("null"))
+	assert.ErrorIs(t, err, ErrMetaNotFound)
+	_, err = UnmarshalMetaFromReader(strings.NewReader("invalid"))
+	assert.Error(t, err)
+}
+
+func TestUnmarshalMetasFromReader(t *testing.T) {
+	got, err := UnmarshalMetasFromReader(strings.NewReader(`[{"type":"gauge","id":"Alloc","unit":"bytes"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []*Meta{NewMeta(TypeGauge, "Alloc", "bytes", "")}, got)
+
+	for _, body := range []string{"", "[]", "[null]", `[{"type":"gauge"}]`, `[{"type":"invalid","id":"Alloc"}]`, "invalid"} {
+		_, err = UnmarshalMetasFromReader(strings.NewReader(body))
+		assert.Error(t, err, body)
+	}
+}
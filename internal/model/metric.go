@@ -6,29 +6,46 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
 )
 
 const (
-	TypeGauge   = "gauge"
-	TypeCounter = "counter"
+	TypeGauge     = "gauge"
+	TypeCounter   = "counter"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeSet       = "set"
 )
 
 // Metric - metric structure
 type Metric struct {
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	ID    string   `json:"id"`              // имя метрики
+	Delta     *int64           `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Value     *float64         `json:"value,omitempty"`     // значение метрики в случае передачи gauge
+	Histogram *Histogram       `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Summary   *tdigest.TDigest `json:"summary,omitempty"`   // значение метрики в случае передачи summary
+	Set       *hll.Sketch      `json:"set,omitempty"`       // значение метрики в случае передачи set
+	Labels    Labels           `json:"labels,omitempty"`    // метки (измерения) метрики, входят в её идентификатор
+	MType     string           `json:"type"`                // параметр, принимающий значение gauge, counter, histogram, summary или set
+	ID        string           `json:"id"`                  // имя метрики
+	Members   []string         `json:"members,omitempty"`   // элементы множества в случае передачи set, добавляются в значение
+	Timestamp int64            `json:"timestamp,omitempty"` // время измерения в Unix миллисекундах, 0 - не задано
 }
 
 // Clone returns a copy of the metric
 func (m *Metric) Clone() *Metric {
 	metric := &Metric{
-		MType: m.MType,
-		ID:    m.ID,
+		Labels:    m.Labels.Clone(),
+		Members:   slices.Clone(m.Members),
+		Timestamp: m.Timestamp,
+		MType:     m.MType,
+		ID:        m.ID,
 	}
 	if m.Value != nil {
 		metric.Value = new(float64)
@@ -38,16 +55,61 @@ func (m *Metric) Clone() *Metric {
 		metric.Delta = new(int64)
 		*metric.Delta = *m.Delta
 	}
+	if m.Histogram != nil {
+		metric.Histogram = m.Histogram.Clone()
+	}
+	if m.Summary != nil {
+		metric.Summary = m.Summary.Clone()
+	}
+	if m.Set != nil {
+		metric.Set = m.Set.Clone()
+	}
 	return metric
 }
 
+// Key returns the identifier of the metric within its type: the ID followed by the canonical labels,
+// it is unique as the ID can not contain the braces of the labels, see ValidateID
+func (m *Metric) Key() string {
+	return m.ID + m.Labels.Key()
+}
+
+// ValidateID returns an error if the ID contains the braces, so the ID followed by the labels
+// can not be taken for the other ID without labels
+func ValidateID(id string) error {
+	if strings.ContainsAny(id, "{}") {
+		return fmt.Errorf("%w: %q contains braces", ErrIDIsNotValid, id)
+	}
+	return nil
+}
+
+// LabelsKey returns the canonical representation of the labels
+func (m *Metric) LabelsKey() string {
+	return m.Labels.Key()
+}
+
 // AnyValue returns the value of the metric as any
 func (m *Metric) AnyValue() any {
-	if m.MType == TypeCounter {
+	switch m.MType {
+	case TypeCounter:
 		if m.Delta == nil {
 			return nil
 		}
 		return *m.Delta
+	case TypeHistogram:
+		if m.Histogram == nil {
+			return nil
+		}
+		return m.Histogram
+	case TypeSummary:
+		if m.Summary == nil {
+			return nil
+		}
+		return m.Summary
+	case TypeSet:
+		if m.Set == nil {
+			return nil
+		}
+		return m.Set.Count()
 	}
 	if m.Value == nil {
 		return nil
@@ -62,9 +124,103 @@ func (m *Metric) ScanRow(row pgx.Row) error {
 		&m.ID,
 		&m.Value,
 		&m.Delta,
+		&m.Histogram,
+		&m.Summary,
+		&m.Set,
+		&m.Labels,
+		&m.Timestamp,
 	)
 }
 
+// Merge adds the value of the given metric to the metric.
+//
+// It is used for the accumulating types: counter deltas and histogram bucket counts are summed up,
+// summary and set sketches are merged, set members are added to the sketch, the latest timestamp is kept.
+// For other types the metric is left unchanged.
+func (m *Metric) Merge(o *Metric) error {
+	if IsAccumulating(m.MType) && o.Timestamp > m.Timestamp {
+		m.Timestamp = o.Timestamp
+	}
+	switch m.MType {
+	case TypeCounter:
+		if o.Delta == nil {
+			return nil
+		}
+		if m.Delta == nil {
+			m.Delta = new(int64)
+		}
+		*m.Delta += *o.Delta
+	case TypeHistogram:
+		if o.Histogram == nil {
+			return nil
+		}
+		if m.Histogram == nil {
+			m.Histogram = o.Histogram.Clone()
+			return nil
+		}
+		return m.Histogram.Merge(o.Histogram)
+	case TypeSummary:
+		if o.Summary == nil {
+			return nil
+		}
+		if m.Summary == nil {
+			m.Summary = o.Summary.Clone()
+			return nil
+		}
+		m.Summary.Merge(o.Summary)
+	case TypeSet:
+		return m.mergeSet(o)
+	}
+	return nil
+}
+
+// mergeSet adds the members and the sketch of the given set metric to the sketch of the metric
+func (m *Metric) mergeSet(o *Metric) error {
+	m.FoldMembers()
+	if o.Set == nil && len(o.Members) == 0 {
+		return nil
+	}
+	switch {
+	case m.Set == nil && o.Set != nil:
+		m.Set = o.Set.Clone()
+	case m.Set == nil:
+		m.Set = hll.New(hll.DefaultPrecision)
+	case o.Set != nil:
+		if err := m.Set.Merge(o.Set); err != nil {
+			return fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+		}
+	}
+	for _, v := range o.Members {
+		m.Set.Add(v)
+	}
+	return nil
+}
+
+// FoldMembers adds the raw members of the set metric to its sketch and clears them,
+// the sketch is created with the default precision if it is not set
+func (m *Metric) FoldMembers() {
+	if m.MType != TypeSet || len(m.Members) == 0 {
+		return
+	}
+	if m.Set == nil {
+		m.Set = hll.New(hll.DefaultPrecision)
+	}
+	for _, v := range m.Members {
+		m.Set.Add(v)
+	}
+	m.Members = nil
+}
+
+// IsOutOfOrder returns true if the metric has the timestamp older than the timestamp of the given metric
+func (m *Metric) IsOutOfOrder(o *Metric) bool {
+	return m.Timestamp != 0 && m.Timestamp < o.Timestamp
+}
+
+// IsAccumulating returns true if the values of the metric type are merged on update
+func IsAccumulating(t string) bool {
+	return t == TypeCounter || t == TypeHistogram || t == TypeSummary || t == TypeSet
+}
+
 // NewMetricGauge returns a new gauge metric
 func NewMetricGauge(id string, value float64) *Metric {
 	return &Metric{
@@ -83,6 +239,33 @@ func NewMetricCounter(id string, delta int64) *Metric {
 	}
 }
 
+// NewMetricHistogram returns a new histogram metric
+func NewMetricHistogram(id string, h *Histogram) *Metric {
+	return &Metric{
+		Histogram: h,
+		MType:     TypeHistogram,
+		ID:        id,
+	}
+}
+
+// NewMetricSummary returns a new summary metric
+func NewMetricSummary(id string, td *tdigest.TDigest) *Metric {
+	return &Metric{
+		Summary: td,
+		MType:   TypeSummary,
+		ID:      id,
+	}
+}
+
+// NewMetricSet returns a new set metric
+func NewMetricSet(id string, s *hll.Sketch) *Metric {
+	return &Metric{
+		Set:   s,
+		MType: TypeSet,
+		ID:    id,
+	}
+}
+
 // MetricRequest - metric request structure
 type MetricRequest struct {
 	*Metric
@@ -90,6 +273,12 @@ type MetricRequest struct {
 
 // RequiredValue returns an error if the value is not set
 func (mr *MetricRequest) RequiredValue() error {
+	if err := ValidateID(mr.ID); err != nil {
+		return err
+	}
+	if err := mr.Labels.Validate(); err != nil {
+		return err
+	}
 	switch mr.MType {
 	case TypeGauge:
 		if mr.Value == nil {
@@ -99,6 +288,19 @@ func (mr *MetricRequest) RequiredValue() error {
 		if mr.Delta == nil {
 			return ErrValueIsNotValid
 		}
+	case TypeHistogram:
+		if mr.Histogram == nil {
+			return ErrValueIsNotValid
+		}
+		return mr.Histogram.Validate()
+	case TypeSummary:
+		if mr.Summary == nil {
+			return ErrValueIsNotValid
+		}
+	case TypeSet:
+		if mr.Set == nil && len(mr.Members) == 0 {
+			return ErrValueIsNotValid
+		}
 	default:
 		return ErrTypeIsNotValid
 	}
@@ -107,7 +309,9 @@ func (mr *MetricRequest) RequiredValue() error {
 
 // ValidateType returns an error if the type is not valid
 func (mr *MetricRequest) ValidateType() error {
-	if mr.MType != TypeGauge && mr.MType != TypeCounter {
+	switch mr.MType {
+	case TypeGauge, TypeCounter, TypeHistogram, TypeSummary, TypeSet:
+	default:
 		return ErrTypeIsNotValid
 	}
 	return nil
@@ -115,6 +319,9 @@ func (mr *MetricRequest) ValidateType() error {
 
 // NewMetricRequest returns a new metric request
 func NewMetricRequest(t, id, value string) (*MetricRequest, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
 	var m *Metric
 	switch t {
 	case TypeGauge:
@@ -129,12 +336,64 @@ func NewMetricRequest(t, id, value string) (*MetricRequest, error) {
 			return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
 		}
 		m = NewMetricCounter(id, number)
+	case TypeHistogram:
+		// the value is a single observation, it goes to the only +Inf bucket,
+		// NewHistogramRequest observes it into the given bounds
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+		}
+		h := NewHistogram(nil)
+		h.Observe(number)
+		m = NewMetricHistogram(id, h)
+	case TypeSummary:
+		// the value is a single observation
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+		}
+		td := tdigest.New(tdigest.DefaultCompression)
+		td.Add(number)
+		m = NewMetricSummary(id, td)
+	case TypeSet:
+		// the value is a single member
+		s := hll.New(hll.DefaultPrecision)
+		s.Add(value)
+		m = NewMetricSet(id, s)
 	default:
 		return nil, ErrTypeIsNotValid
 	}
 	return &MetricRequest{m}, nil
 }
 
+// NewHistogramRequest returns a new histogram request with the single observation of the value,
+// the bounds are the comma-separated upper bounds of the buckets in increasing order, e.g. "0.1,0.5,1"
+func NewHistogramRequest(id, value, bounds string) (*MetricRequest, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+	if bounds == "" {
+		return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, ErrBoundsRequired)
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+	}
+	fields := strings.Split(bounds, ",")
+	bs := make([]float64, len(fields))
+	for i, f := range fields {
+		if bs[i], err = strconv.ParseFloat(strings.TrimSpace(f), 64); err != nil || math.IsNaN(bs[i]) || math.IsInf(bs[i], 0) {
+			return nil, fmt.Errorf("%w: histogram bound %q is not a finite number", ErrValueIsNotValid, f)
+		}
+	}
+	h := NewHistogram(bs)
+	if err = h.Validate(); err != nil {
+		return nil, err
+	}
+	h.Observe(number)
+	return &MetricRequest{NewMetricHistogram(id, h)}, nil
+}
+
 // UnmarshalMetricRequestFromReader unmarshals the metric request from the reader
 func UnmarshalMetricRequestFromReader(r io.Reader) (*MetricRequest, error) {
 	body, err := io.ReadAll(r)
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,6 +48,13 @@ func TestMetric_AnyValue(t *testing.T) {
 			},
 			want: nil,
 		},
+		{
+			name: "histogram",
+			args: args{
+				Metric: NewMetricHistogram("test", &Histogram{Counts: []uint64{2}, Sum: 3}),
+			},
+			want: &Histogram{Counts: []uint64{2}, Sum: 3},
+		},
 		{
 			name: "nil delta",
 			args: args{
@@ -87,6 +96,13 @@ func TestMetric_Clone(t *testing.T) {
 			},
 			want: NewMetricCounter("test", 33),
 		},
+		{
+			name: "histogram",
+			args: args{
+				Metric: NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{2, 3}, Sum: 4}),
+			},
+			want: NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{2, 3}, Sum: 4}),
+		},
 		{
 			name: "nil",
 			args: args{
@@ -114,6 +130,69 @@ func TestMetric_Clone(t *testing.T) {
 				assert.NotNil(t, got.Delta)
 				assert.NotSame(t, tt.want.Delta, got.Delta)
 			}
+			if tt.args.Metric.Histogram != nil {
+				assert.NotNil(t, got.Histogram)
+				assert.NotSame(t, tt.args.Metric.Histogram, got.Histogram)
+				assert.NotSame(t, &tt.args.Metric.Histogram.Counts[0], &got.Histogram.Counts[0])
+			}
+		})
+	}
+}
+
+func TestMetric_Merge(t *testing.T) {
+	tests := []struct {
+		metric  *Metric
+		other   *Metric
+		want    *Metric
+		wantErr error
+		name    string
+	}{
+		{
+			name:   "counter",
+			metric: NewMetricCounter("test", 3),
+			other:  NewMetricCounter("test", 4),
+			want:   NewMetricCounter("test", 7),
+		},
+		{
+			name:   "counter without delta",
+			metric: &Metric{MType: TypeCounter, ID: "test"},
+			other:  NewMetricCounter("test", 4),
+			want:   NewMetricCounter("test", 4),
+		},
+		{
+			name:   "gauge",
+			metric: NewMetricGauge("test", 3),
+			other:  NewMetricGauge("test", 4),
+			want:   NewMetricGauge("test", 3),
+		},
+		{
+			name:   "histogram",
+			metric: NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4}),
+			other:  NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{3, 1}, Sum: 2}),
+			want:   NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{4, 3}, Sum: 6}),
+		},
+		{
+			name:   "histogram without value",
+			metric: &Metric{MType: TypeHistogram, ID: "test"},
+			other:  NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{3, 1}, Sum: 2}),
+			want:   NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{3, 1}, Sum: 2}),
+		},
+		{
+			name:    "histogram bounds mismatch",
+			metric:  NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 4}),
+			other:   NewMetricHistogram("test", &Histogram{Bounds: []float64{2}, Counts: []uint64{3, 1}, Sum: 2}),
+			wantErr: ErrBoundsMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metric.Merge(tt.other)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.metric)
 		})
 	}
 }
@@ -252,6 +331,33 @@ func TestNewMetricRequest(t *testing.T) {
 			},
 			wantErr: ErrValueIsNotValid,
 		},
+		{
+			name: "histogram",
+			args: args{
+				t:     TypeHistogram,
+				name:  "test",
+				value: "1.5",
+			},
+			want: &MetricRequest{NewMetricHistogram("test", &Histogram{Counts: []uint64{1}, Sum: 1.5})},
+		},
+		{
+			name: "summary error number",
+			args: args{
+				t:     TypeSummary,
+				name:  "test",
+				value: "invalid",
+			},
+			wantErr: ErrValueIsNotValid,
+		},
+		{
+			name: "histogram error number",
+			args: args{
+				t:     TypeHistogram,
+				name:  "test",
+				value: "invalid",
+			},
+			wantErr: ErrValueIsNotValid,
+		},
 		{
 			name: "unknown type",
 			args: args{
@@ -357,6 +463,49 @@ func TestMetricRequest_RequiredValue(t *testing.T) {
 			metric:  &MetricRequest{&Metric{MType: TypeCounter, ID: "test"}},
 			wantErr: ErrValueIsNotValid,
 		},
+		{
+			name:    "histogram",
+			metric:  &MetricRequest{NewMetricHistogram("test", NewHistogram([]float64{1, 2}))},
+			wantErr: nil,
+		},
+		{
+			name:    "histogram error",
+			metric:  &MetricRequest{&Metric{MType: TypeHistogram, ID: "test"}},
+			wantErr: ErrValueIsNotValid,
+		},
+		{
+			name: "histogram invalid",
+			metric: &MetricRequest{NewMetricHistogram("test", &Histogram{
+				Bounds: []float64{1, 2},
+				Counts: []uint64{1},
+			})},
+			wantErr: ErrValueIsNotValid,
+		},
+		{
+			name:    "summary",
+			metric:  &MetricRequest{NewMetricSummary("test", tdigest.New(100))},
+			wantErr: nil,
+		},
+		{
+			name:    "summary error",
+			metric:  &MetricRequest{&Metric{MType: TypeSummary, ID: "test"}},
+			wantErr: ErrValueIsNotValid,
+		},
+		{
+			name:    "set sketch",
+			metric:  &MetricRequest{NewMetricSet("test", hll.New(hll.DefaultPrecision))},
+			wantErr: nil,
+		},
+		{
+			name:    "set members",
+			metric:  &MetricRequest{&Metric{MType: TypeSet, ID: "test", Members: []string{"a"}}},
+			wantErr: nil,
+		},
+		{
+			name:    "set error",
+			metric:  &MetricRequest{&Metric{MType: TypeSet, ID: "test"}},
+			wantErr: ErrValueIsNotValid,
+		},
 		{
 			name:    "type error",
 			metric:  &MetricRequest{&Metric{MType: "invalid", ID: "test"}},
@@ -410,3 +559,128 @@ func TestMetricRequest_ValidateType(t *testing.T) {
 		})
 	}
 }
+
+func TestNewMetricRequest_Summary(t *testing.T) {
+	mr, err := NewMetricRequest(TypeSummary, "test", "2.5")
+	require.NoError(t, err)
+	assert.Equal(t, TypeSummary, mr.MType)
+	assert.Equal(t, "test", mr.ID)
+	require.NotNil(t, mr.Summary)
+	assert.InDelta(t, 1.0, mr.Summary.Count(), 1e-9)
+	assert.InDelta(t, 2.5, mr.Summary.Quantile(0.5), 1e-9)
+	require.NoError(t, mr.RequiredValue())
+}
+
+func TestNewHistogramRequest(t *testing.T) {
+	mr, err := NewHistogramRequest("test", "0.3", "0.1, 0.5,1")
+	require.NoError(t, err)
+	assert.Equal(t, NewMetricHistogram("test", &Histogram{Bounds: []float64{0.1, 0.5, 1}, Counts: []uint64{0, 1, 0, 0}, Sum: 0.3}), mr.Metric)
+	require.NoError(t, mr.RequiredValue())
+
+	for _, tt := range []struct {
+		wantErr error
+		value   string
+		bounds  string
+	}{
+		{value: "1", bounds: "", wantErr: ErrBoundsRequired},
+		{value: "x", bounds: "1", wantErr: ErrValueIsNotValid},
+		{value: "1", bounds: "1,x", wantErr: ErrValueIsNotValid},
+		{value: "1", bounds: "1,+Inf", wantErr: ErrValueIsNotValid},
+		{value: "1", bounds: "1,1", wantErr: ErrValueIsNotValid},
+	} {
+		_, err = NewHistogramRequest("test", tt.value, tt.bounds)
+		require.ErrorIs(t, err, tt.wantErr, "value %q bounds %q", tt.value, tt.bounds)
+		require.ErrorIs(t, err, ErrValueIsNotValid)
+	}
+}
+
+func TestMetric_MergeSummary(t *testing.T) {
+	a, b := tdigest.New(100), tdigest.New(100)
+	for i := 1; i <= 10; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 10))
+	}
+	m := NewMetricSummary("test", a)
+	require.NoError(t, m.Merge(NewMetricSummary("test", b)))
+	assert.InDelta(t, 20.0, m.Summary.Count(), 1e-9)
+	assert.InDelta(t, 20.0, m.Summary.Max(), 1e-9)
+	assert.InDelta(t, 10.0, b.Count(), 1e-9)
+
+	empty := &Metric{MType: TypeSummary, ID: "test"}
+	require.NoError(t, empty.Merge(NewMetricSummary("test", b)))
+	assert.Equal(t, b.Centroids(), empty.Summary.Centroids())
+	assert.NotSame(t, b, empty.Summary)
+
+	clone := m.Clone()
+	assert.NotSame(t, m.Summary, clone.Summary)
+	assert.Equal(t, m.Summary.Centroids(), clone.Summary.Centroids())
+	assert.Equal(t, m.Summary, m.AnyValue())
+}
+
+func TestMetric_Timestamp(t *testing.T) {
+	m := NewMetricGauge("test", 1)
+	m.Timestamp = 200
+	assert.Equal(t, m, m.Clone())
+
+	older := NewMetricGauge("test", 2)
+	assert.False(t, older.IsOutOfOrder(m))
+	older.Timestamp = 100
+	assert.True(t, older.IsOutOfOrder(m))
+	assert.False(t, m.IsOutOfOrder(older))
+
+	counter := NewMetricCounter("test", 1)
+	counter.Timestamp = 100
+	require.NoError(t, counter.Merge(&Metric{Delta: counter.Delta, MType: TypeCounter, ID: "test", Timestamp: 300}))
+	assert.Equal(t, int64(300), counter.Timestamp)
+	require.NoError(t, counter.Merge(&Metric{Delta: counter.Delta, MType: TypeCounter, ID: "test", Timestamp: 200}))
+	assert.Equal(t, int64(300), counter.Timestamp)
+}
+
+func TestNewMetricRequest_Set(t *testing.T) {
+	mr, err := NewMetricRequest(TypeSet, "users", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, TypeSet, mr.MType)
+	require.NotNil(t, mr.Set)
+	assert.Equal(t, uint64(1), mr.AnyValue())
+	require.NoError(t, mr.RequiredValue())
+	require.NoError(t, mr.ValidateType())
+}
+
+func TestMetric_MergeSet(t *testing.T) {
+	s := hll.New(hll.DefaultPrecision)
+	s.Add("a")
+	s.Add("b")
+	m := &Metric{MType: TypeSet, ID: "users", Members: []string{"a", "c"}}
+	require.NoError(t, m.Merge(&Metric{MType: TypeSet, ID: "users", Set: s, Members: []string{"d"}}))
+	assert.Nil(t, m.Members)
+	assert.Equal(t, uint64(4), m.AnyValue())
+	assert.Equal(t, uint64(2), s.Count())
+	assert.NotSame(t, s, m.Set)
+
+	require.NoError(t, m.Merge(NewMetricSet("users", hll.New(hll.DefaultPrecision))))
+	assert.Equal(t, uint64(4), m.AnyValue())
+	err := m.Merge(NewMetricSet("users", hll.New(hll.MinPrecision)))
+	assert.ErrorIs(t, err, ErrValueIsNotValid)
+	assert.ErrorIs(t, err, hll.ErrPrecisionMismatch)
+
+	clone := m.Clone()
+	assert.NotSame(t, m.Set, clone.Set)
+	assert.Equal(t, m.Set, clone.Set)
+
+	empty := &Metric{MType: TypeSet, ID: "users"}
+	assert.Nil(t, empty.AnyValue())
+	require.NoError(t, empty.Merge(&Metric{MType: TypeSet, ID: "users", Members: []string{"a"}}))
+	assert.Equal(t, uint64(1), empty.AnyValue())
+}
+
+func TestMetric_FoldMembers(t *testing.T) {
+	m := &Metric{MType: TypeSet, ID: "users", Members: []string{"a", "b", "a"}}
+	m.FoldMembers()
+	assert.Nil(t, m.Members)
+	assert.Equal(t, uint64(2), m.AnyValue())
+
+	g := &Metric{MType: TypeGauge, ID: "test", Members: []string{"a"}}
+	g.FoldMembers()
+	assert.Nil(t, g.Set)
+	assert.Equal(t, []string{"a"}, g.Members)
+}
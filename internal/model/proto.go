@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
+	"google.golang.org/protobuf/proto"
+)
+
+// ToProto returns the protobuf representation of the metric
+func (m *Metric) ToProto() (*pb.Metric, error) {
+	p := &pb.Metric{
+		Id:        m.ID,
+		Type:      m.MType,
+		Delta:     m.Delta,
+		Value:     m.Value,
+		Members:   m.Members,
+		Labels:    m.Labels,
+		Timestamp: m.Timestamp,
+	}
+	if m.Histogram != nil {
+		p.Histogram = &pb.Histogram{Bounds: m.Histogram.Bounds, Counts: m.Histogram.Counts, Sum: m.Histogram.Sum}
+	}
+	var err error
+	if m.Summary != nil {
+		if p.Summary, err = m.Summary.MarshalBinary(); err != nil {
+			return nil, fmt.Errorf("failed to marshal summary: %w", err)
+		}
+	}
+	if m.Set != nil {
+		if p.Set, err = m.Set.MarshalBinary(); err != nil {
+			return nil, fmt.Errorf("failed to marshal set: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// MetricFromProto returns the metric from its protobuf representation
+func MetricFromProto(p *pb.Metric) (*Metric, error) {
+	m := &Metric{
+		Delta:     p.Delta,
+		Value:     p.Value,
+		Members:   p.GetMembers(),
+		Timestamp: p.GetTimestamp(),
+		MType:     p.GetType(),
+		ID:        p.GetId(),
+	}
+	if len(p.GetLabels()) > 0 {
+		m.Labels = p.GetLabels()
+	}
+	if h := p.GetHistogram(); h != nil {
+		m.Histogram = &Histogram{Bounds: h.GetBounds(), Counts: h.GetCounts(), Sum: h.GetSum()}
+		if m.Histogram.Bounds == nil {
+			m.Histogram.Bounds = []float64{}
+		}
+	}
+	if p.Summary != nil {
+		m.Summary = &tdigest.TDigest{}
+		if err := m.Summary.UnmarshalBinary(p.GetSummary()); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+		}
+	}
+	if p.Set != nil {
+		m.Set = &hll.Sketch{}
+		if err := m.Set.UnmarshalBinary(p.GetSet()); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrValueIsNotValid, err)
+		}
+	}
+	return m, nil
+}
+
+// MetricsToProto returns the protobuf batch of the metrics
+func MetricsToProto(ms []*Metric) (*pb.MetricBatch, error) {
+	batch := &pb.MetricBatch{Metrics: make([]*pb.Metric, len(ms))}
+	for i, m := range ms {
+		p, err := m.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		batch.Metrics[i] = p
+	}
+	return batch, nil
+}
+
+// UnmarshalMetricRequestFromProtoReader unmarshals the metric request from the reader with the protobuf message
+func UnmarshalMetricRequestFromProtoReader(r io.Reader) (*MetricRequest, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty body")
+	}
+	p := &pb.Metric{}
+	if err = proto.Unmarshal(body, p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	m, err := MetricFromProto(p)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricRequest{m}, nil
+}
+
+// UnmarshalMetricsRequestFromProtoReader unmarshals the metric requests from the reader with the protobuf batch
+func UnmarshalMetricsRequestFromProtoReader(r io.Reader) ([]*MetricRequest, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty body")
+	}
+	batch := &pb.MetricBatch{}
+	if err = proto.Unmarshal(body, batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	if len(batch.GetMetrics()) == 0 {
+		return nil, errors.New("empty body")
+	}
+	mrs := make([]*MetricRequest, len(batch.GetMetrics()))
+	for i, p := range batch.GetMetrics() {
+		m, err := MetricFromProto(p)
+		if err != nil {
+			return nil, err
+		}
+		mrs[i] = &MetricRequest{m}
+	}
+	return mrs, nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetric_ToProto(t *testing.T) {
+	td := tdigest.New(tdigest.DefaultCompression)
+	td.Add(1.5)
+	sketch := hll.New(hll.MinPrecision)
+	sketch.Add("u1")
+	labeled := NewMetricGauge("cpu", 0.5)
+	labeled.Labels = Labels{"host": "h1"}
+	labeled.Timestamp = 1700000000000
+	tests := []struct {
+		metric *Metric
+		name   string
+	}{
+		{name: "gauge", metric: NewMetricGauge("test", 1.25)},
+		{name: "counter", metric: NewMetricCounter("test", -3)},
+		{name: "histogram", metric: NewMetricHistogram("test", &Histogram{Bounds: []float64{1}, Counts: []uint64{2, 1}, Sum: 3.5})},
+		{name: "summary", metric: NewMetricSummary("test", td)},
+		{name: "set", metric: NewMetricSet("test", sketch)},
+		{name: "set members", metric: &Metric{MType: TypeSet, ID: "test", Members: []string{"u1", "u2"}}},
+		{name: "labels and timestamp", metric: labeled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.metric.ToProto()
+			require.NoError(t, err)
+			data, err := proto.Marshal(p)
+			require.NoError(t, err)
+			mr, err := UnmarshalMetricRequestFromProtoReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.NoError(t, mr.RequiredValue())
+			if tt.metric.Summary != nil {
+				assert.Equal(t, tt.metric.Summary.Centroids(), mr.Summary.Centroids())
+				mr.Summary, tt.metric.Summary = nil, nil
+			}
+			assert.Equal(t, tt.metric, mr.Metric)
+		})
+	}
+}
+
+func TestUnmarshalMetricsRequestFromProtoReader(t *testing.T) {
+	batch, err := MetricsToProto([]*Metric{NewMetricGauge("g", 1), NewMetricCounter("c", 2)})
+	require.NoError(t, err)
+	data, err := proto.Marshal(batch)
+	require.NoError(t, err)
+	mrs, err := UnmarshalMetricsRequestFromProtoReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, []*MetricRequest{{NewMetricGauge("g", 1)}, {NewMetricCounter("c", 2)}}, mrs)
+
+	_, err = UnmarshalMetricsRequestFromProtoReader(bytes.NewReader(nil))
+	assert.Error(t, err)
+	_, err = UnmarshalMetricsRequestFromProtoReader(bytes.NewReader([]byte{0xff}))
+	assert.Error(t, err)
+	data, err = proto.Marshal(&pb.MetricBatch{Metrics: []*pb.Metric{}})
+	require.NoError(t, err)
+	_, err = UnmarshalMetricsRequestFromProtoReader(bytes.NewReader(data))
+	assert.Error(t, err)
+
+	data, err = proto.Marshal(&pb.MetricBatch{Metrics: []*pb.Metric{{Id: "s", Type: TypeSet, Set: []byte{1}}}})
+	require.NoError(t, err)
+	_, err = UnmarshalMetricsRequestFromProtoReader(bytes.NewReader(data))
+	assert.ErrorIs(t, err, ErrValueIsNotValid)
+}
+
+func TestUnmarshalMetricRequestFromProtoReader_Invalid(t *testing.T) {
+	_, err := UnmarshalMetricRequestFromProtoReader(bytes.NewReader(nil))
+	assert.Error(t, err)
+	_, err = UnmarshalMetricRequestFromProtoReader(bytes.NewReader([]byte{0xff}))
+	assert.Error(t, err)
+	data, err := proto.Marshal(&pb.Metric{Id: "s", Type: TypeSummary, Summary: []byte{1}})
+	require.NoError(t, err)
+	_, err = UnmarshalMetricRequestFromProtoReader(bytes.NewReader(data))
+	assert.ErrorIs(t, err, ErrValueIsNotValid)
+}
@@ -0,0 +1,8 @@
+// Package proto contains the protobuf messages of the metrics and the gRPC service updating them.
+//
+// The messages are generated from metrics.proto and the service from service.proto, regenerate them
+// with protoc, protoc-gen-go and protoc-gen-go-grpc after changing the schema.
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative metrics.proto
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative service.proto
@@ -0,0 +1,197 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: service.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// StreamUpdatesRequest is a batch of the stream of updates.
+type StreamUpdatesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Batch *MetricBatch           `protobuf:"bytes,1,opt,name=batch,proto3" json:"batch,omitempty"`
+	// HMAC-SHA256 of the batch encoding in hex if the key is set,
+	// the messages of a stream can not be signed with the metadata
+	Hash          string `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUpdatesRequest) Reset() {
+	*x = StreamUpdatesRequest{}
+	mi := &file_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUpdatesRequest) ProtoMessage() {}
+
+func (x *StreamUpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUpdatesRequest.ProtoReflect.Descriptor instead.
+func (*StreamUpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamUpdatesRequest) GetBatch() *MetricBatch {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *StreamUpdatesRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+// StreamUpdatesResponse is the result of the stream of updates.
+type StreamUpdatesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// number of the updated metrics
+	Updated       int64 `protobuf:"varint,1,opt,name=updated,proto3" json:"updated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUpdatesResponse) Reset() {
+	*x = StreamUpdatesResponse{}
+	mi := &file_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUpdatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUpdatesResponse) ProtoMessage() {}
+
+func (x *StreamUpdatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUpdatesResponse.ProtoReflect.Descriptor instead.
+func (*StreamUpdatesResponse) Descriptor() ([]byte, []int) {
+	return file_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamUpdatesResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+var File_service_proto protoreflect.FileDescriptor
+
+const file_service_proto_rawDesc = "" +
+	"\n" +
+	"\rservice.proto\x12\ametrics\x1a\rmetrics.proto\"V\n" +
+	"\x14StreamUpdatesRequest\x12*\n" +
+	"\x05batch\x18\x01 \x01(\v2\x14.metrics.MetricBatchR\x05batch\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\"1\n" +
+	"\x15StreamUpdatesResponse\x12\x18\n" +
+	"\aupdated\x18\x01 \x01(\x03R\aupdated2\xc2\x01\n" +
+	"\aMetrics\x12*\n" +
+	"\x06Update\x12\x0f.metrics.Metric\x1a\x0f.metrics.Metric\x129\n" +
+	"\vUpdateBatch\x12\x14.metrics.MetricBatch\x1a\x14.metrics.MetricBatch\x12P\n" +
+	"\rStreamUpdates\x12\x1d.metrics.StreamUpdatesRequest\x1a\x1e.metrics.StreamUpdatesResponse(\x01B:Z8github.com/korobkovandrey/runtime-metrics/internal/protob\x06proto3"
+
+var (
+	file_service_proto_rawDescOnce sync.Once
+	file_service_proto_rawDescData []byte
+)
+
+func file_service_proto_rawDescGZIP() []byte {
+	file_service_proto_rawDescOnce.Do(func() {
+		file_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_service_proto_rawDesc), len(file_service_proto_rawDesc)))
+	})
+	return file_service_proto_rawDescData
+}
+
+var file_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_service_proto_goTypes = []any{
+	(*StreamUpdatesRequest)(nil),  // 0: metrics.StreamUpdatesRequest
+	(*StreamUpdatesResponse)(nil), // 1: metrics.StreamUpdatesResponse
+	(*MetricBatch)(nil),           // 2: metrics.MetricBatch
+	(*Metric)(nil),                // 3: metrics.Metric
+}
+var file_service_proto_depIdxs = []int32{
+	2, // 0: metrics.StreamUpdatesRequest.batch:type_name -> metrics.MetricBatch
+	3, // 1: metrics.Metrics.Update:input_type -> metrics.Metric
+	2, // 2: metrics.Metrics.UpdateBatch:input_type -> metrics.MetricBatch
+	0, // 3: metrics.Metrics.StreamUpdates:input_type -> metrics.StreamUpdatesRequest
+	3, // 4: metrics.Metrics.Update:output_type -> metrics.Metric
+	2, // 5: metrics.Metrics.UpdateBatch:output_type -> metrics.MetricBatch
+	1, // 6: metrics.Metrics.StreamUpdates:output_type -> metrics.StreamUpdatesResponse
+	4, // [4:7] is the sub-list for method output_type
+	1, // [1:4] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_service_proto_init() }
+func file_service_proto_init() {
+	if File_service_proto != nil {
+		return
+	}
+	file_metrics_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_service_proto_rawDesc), len(file_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_service_proto_goTypes,
+		DependencyIndexes: file_service_proto_depIdxs,
+		MessageInfos:      file_service_proto_msgTypes,
+	}.Build()
+	File_service_proto = out.File
+	file_service_proto_goTypes = nil
+	file_service_proto_depIdxs = nil
+}
@@ -4,51 +4,119 @@ package config
 import (
 	"flag"
 	"fmt"
+	"os"
 	"time"
 
-	"github.com/caarlos0/env/v6"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/service"
+	"github.com/korobkovandrey/runtime-metrics/pkg/confload"
+	"go.uber.org/zap/zapcore"
 )
 
 // Config is the server config.
 type Config struct {
-	Addr                string `env:"ADDRESS"`
-	FileStoragePath     string `env:"FILE_STORAGE_PATH"`
-	DatabaseDSN         string `env:"DATABASE_DSN"`
-	Key                 string `env:"KEY"`
-	RetryDelays         []time.Duration
-	StoreInterval       int64 `env:"STORE_INTERVAL"`
-	ShutdownTimeout     time.Duration
-	DatabasePingTimeout time.Duration
-	Restore             bool `env:"RESTORE"`
-	Pprof               bool `env:"PPROF"`
+	Addr                string          `env:"ADDRESS" json:"address"`
+	GRPCAddr            string          `env:"GRPC_ADDRESS" json:"grpc_address"`
+	FileStoragePath     string          `env:"FILE_STORAGE_PATH" json:"file_storage_path"`
+	DatabaseDSN         string          `env:"DATABASE_DSN" json:"database_dsn"`
+	Key                 string          `env:"KEY" json:"key"`
+	OutOfOrderPolicy    string          `env:"OUT_OF_ORDER_POLICY" json:"out_of_order_policy"`
+	LogLevel            string          `env:"LOG_LEVEL" json:"log_level"`
+	RetryDelays         []time.Duration `env:"RETRY_DELAYS" envSeparator:"," json:"retry_delays"`
+	StoreInterval       int64           `env:"STORE_INTERVAL" json:"store_interval"`
+	MaxBatchSize        int             `env:"MAX_BATCH_SIZE" json:"max_batch_size"`
+	MaxBodySize         int64           `env:"MAX_BODY_SIZE" json:"max_body_size"`
+	ShutdownTimeout     time.Duration   `env:"SHUTDOWN_TIMEOUT" json:"shutdown_timeout"`
+	DatabasePingTimeout time.Duration   `env:"DATABASE_PING_TIMEOUT" json:"database_ping_timeout"`
+	Restore             bool            `env:"RESTORE" json:"restore"`
+	Pprof               bool            `env:"PPROF" json:"pprof"`
 }
 
+// reloadableFields are the fields applied by the running server on reload.
+var reloadableFields = []string{"Key", "LogLevel", "StoreInterval"}
+
 // NewConfig returns the server config.
+//
+// The config is loaded from the flags, the env and the config file set by -c, -config or CONFIG,
+// see confload for the precedence.
 func NewConfig() (*Config, error) {
+	return newConfig(flag.CommandLine, os.Args[1:])
+}
+
+// Reload reads the server config again from the command line, the env and the config file.
+func Reload() (*Config, error) {
+	return newConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+}
+
+// Apply copies the reloadable fields of the reloaded cfg to the running config and returns
+// the names of the applied fields and the changed fields which need a restart.
+func Apply(running, cfg *Config) (applied, restart []string) {
+	return confload.Apply(running, cfg, reloadableFields...)
+}
+
+// newConfig parses the args with the flag set and loads the config.
+func newConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 	const (
-		storeInterval   = 0
-		shutdownTimeout = 5
-		databasePingTimeout
+		storeInterval       = 0
+		maxBatchSize        = 100000
+		maxBodySize         = 64 << 20
+		shutdownTimeout     = 5 * time.Second
+		databasePingTimeout = 5 * time.Second
 	)
-	cfg := &Config{}
-	flag.StringVar(&cfg.Addr, "a", "localhost:8080", "server host")
-	flag.StringVar(&cfg.FileStoragePath, "f", "storage.json", "file storage path")
-	flag.StringVar(&cfg.DatabaseDSN, "d", "", "database dsn")
-	flag.BoolVar(&cfg.Restore, "r", true, "file storage path")
-	flag.Int64Var(&cfg.StoreInterval, "i", storeInterval, "store interval")
-	flag.StringVar(&cfg.Key, "k", "", "key")
-	flag.BoolVar(&cfg.Pprof, "pprof", false, "use pprof")
-
-	flag.Parse()
-
-	err := env.Parse(cfg)
+	cfg := &Config{RetryDelays: []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}}
+	var configPath string
+	fs.StringVar(&configPath, "c", "", "config file, JSON or YAML if the extension is .yaml or .yml")
+	fs.StringVar(&configPath, "config", "", "config file, JSON or YAML if the extension is .yaml or .yml")
+	fs.StringVar(&cfg.Addr, "a", "localhost:8080", "server host")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-address", "", "gRPC server host, empty - no gRPC server")
+	fs.StringVar(&cfg.FileStoragePath, "f", "storage.json", "file storage path")
+	fs.StringVar(&cfg.DatabaseDSN, "d", "", "database dsn")
+	fs.BoolVar(&cfg.Restore, "r", true, "file storage path")
+	fs.Int64Var(&cfg.StoreInterval, "i", storeInterval, "store interval")
+	fs.StringVar(&cfg.Key, "k", "", "key")
+	fs.BoolVar(&cfg.Pprof, "pprof", false, "use pprof")
+	fs.IntVar(&cfg.MaxBatchSize, "max-batch", maxBatchSize, "maximum number of metrics in the batch, 0 - no limit")
+	fs.Int64Var(&cfg.MaxBodySize, "max-body", maxBodySize, "maximum size of the decompressed request body in bytes, 0 - no limit")
+	fs.StringVar(&cfg.OutOfOrderPolicy, "ooo", "accept", "out-of-order gauge samples policy: accept, ignore or reject")
+	fs.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", shutdownTimeout, "timeout of the graceful shutdown")
+	fs.DurationVar(&cfg.DatabasePingTimeout, "db-ping-timeout", databasePingTimeout, "timeout of the database ping")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "log level: debug, info, warn or error")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	src, err := confload.Load(fs, cfg, configPath)
 	if err != nil {
 		return cfg, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	cfg.ShutdownTimeout = shutdownTimeout * time.Second
-	cfg.DatabasePingTimeout = databasePingTimeout * time.Second
-	cfg.RetryDelays = []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}
+	if cfg.StoreInterval < 0 {
+		return cfg, fmt.Errorf("StoreInterval (%d, %s) must not be negative", cfg.StoreInterval, src.Of("StoreInterval"))
+	}
+	if cfg.MaxBatchSize < 0 {
+		return cfg, fmt.Errorf("MaxBatchSize (%d, %s) must not be negative", cfg.MaxBatchSize, src.Of("MaxBatchSize"))
+	}
+	if cfg.MaxBodySize < 0 {
+		return cfg, fmt.Errorf("MaxBodySize (%d, %s) must not be negative", cfg.MaxBodySize, src.Of("MaxBodySize"))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		return cfg, fmt.Errorf("ShutdownTimeout (%s, %s) must be greater 0", cfg.ShutdownTimeout, src.Of("ShutdownTimeout"))
+	}
+	if cfg.DatabasePingTimeout <= 0 {
+		return cfg, fmt.Errorf("DatabasePingTimeout (%s, %s) must be greater 0",
+			cfg.DatabasePingTimeout, src.Of("DatabasePingTimeout"))
+	}
+	for _, d := range cfg.RetryDelays {
+		if d < 0 {
+			return cfg, fmt.Errorf("RetryDelays (%s) must not be negative", src.Of("RetryDelays"))
+		}
+	}
+	if _, err = zapcore.ParseLevel(cfg.LogLevel); err != nil {
+		return cfg, fmt.Errorf("LogLevel (%s): %w", src.Of("LogLevel"), err)
+	}
+	if _, err = service.ParseOutOfOrderPolicy(cfg.OutOfOrderPolicy); err != nil {
+		return cfg, fmt.Errorf("OutOfOrderPolicy (%s): %w", src.Of("OutOfOrderPolicy"), err)
+	}
 
 	return cfg, nil
 }
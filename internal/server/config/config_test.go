@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,22 +12,57 @@ import (
 
 func TestNewConfig(t *testing.T) {
 	t.Setenv("ADDRESS", "test_ADDRESS")
+	t.Setenv("GRPC_ADDRESS", "test_GRPC_ADDRESS")
 	t.Setenv("FILE_STORAGE_PATH", "test_FILE_STORAGE_PATH")
 	t.Setenv("DATABASE_DSN", "test_DATABASE_DSN")
 	t.Setenv("RESTORE", "true")
 	t.Setenv("STORE_INTERVAL", "5")
 	t.Setenv("KEY", "test_KEY")
 	t.Setenv("PPROF", "true")
+	t.Setenv("MAX_BATCH_SIZE", "500")
+	t.Setenv("MAX_BODY_SIZE", "1024")
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"address: file.host:80\nshutdown_timeout: 7s\nretry_delays: [100ms, 1s]\nout_of_order_policy: reject\n"), 0o600))
+	t.Setenv("CONFIG", path)
 	cfg, err := NewConfig()
 	require.NoError(t, err)
 	assert.Equal(t, "test_ADDRESS", cfg.Addr)
+	assert.Equal(t, "test_GRPC_ADDRESS", cfg.GRPCAddr)
 	assert.Equal(t, "test_FILE_STORAGE_PATH", cfg.FileStoragePath)
 	assert.Equal(t, "test_DATABASE_DSN", cfg.DatabaseDSN)
 	assert.True(t, cfg.Restore)
 	assert.Equal(t, int64(5), cfg.StoreInterval)
 	assert.Equal(t, "test_KEY", cfg.Key)
 	assert.True(t, cfg.Pprof)
-	assert.Equal(t, 5*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, 500, cfg.MaxBatchSize)
+	assert.Equal(t, int64(1024), cfg.MaxBodySize)
+	assert.Equal(t, 7*time.Second, cfg.ShutdownTimeout)
 	assert.Equal(t, 5*time.Second, cfg.DatabasePingTimeout)
-	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}, cfg.RetryDelays)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, time.Second}, cfg.RetryDelays)
+	assert.Equal(t, "reject", cfg.OutOfOrderPolicy)
+}
+
+func TestReload(t *testing.T) {
+	args := os.Args
+	os.Args = []string{"server", "-a", "flag.host:80"}
+	t.Cleanup(func() { os.Args = args })
+	running, err := Reload()
+	require.NoError(t, err)
+
+	t.Setenv("KEY", "new key")
+	t.Setenv("STORE_INTERVAL", "30")
+	t.Setenv("DATABASE_DSN", "postgres://localhost/metrics")
+	cfg, err := Reload()
+	require.NoError(t, err)
+	assert.Equal(t, "flag.host:80", cfg.Addr)
+	applied, restart := Apply(running, cfg)
+	assert.Equal(t, []string{"Key", "StoreInterval"}, applied)
+	assert.Equal(t, []string{"DatabaseDSN"}, restart)
+	assert.Equal(t, "new key", running.Key)
+	assert.Empty(t, running.DatabaseDSN)
+
+	t.Setenv("LOG_LEVEL", "loud")
+	_, err = Reload()
+	require.ErrorContains(t, err, "LogLevel (env LOG_LEVEL)")
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/agent/sender"
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/config"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHandler_NewGRPCServer(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	h := NewHandler()
+	// the index template is parsed relative to the root of the repository
+	t.Chdir("../..")
+	require.NoError(t, h.Configure(t.Context(), &config.Config{Key: "secret", OutOfOrderPolicy: "accept"}, l))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := h.NewGRPCServer(l)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	defer s.Stop()
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	newSender := func(key string) *sender.Sender {
+		sc := sender.New(&sender.Config{Addr: lis.Addr().String(), Transport: sender.TransportGRPC, Key: []byte(key)}, l)
+		t.Cleanup(func() {
+			assert.NoError(t, sc.Close())
+		})
+		return sc
+	}
+
+	value := func(mType, id string) string {
+		t.Helper()
+		body, code, _ := testRequest(t, ts, http.MethodPost, "/value/",
+			strings.NewReader(fmt.Sprintf(`{"id":%q,"type":%q}`, id, mType)))
+		require.Equal(t, http.StatusOK, code, string(body))
+		return string(body)
+	}
+
+	sc := newSender("secret")
+	require.NoError(t, sc.SendMetric(t.Context(), model.NewMetricGauge("g", 1.5)))
+	assert.JSONEq(t, `{"id":"g","type":"gauge","value":1.5}`, value(model.TypeGauge, "g"))
+
+	batch := make([]*model.Metric, 2500)
+	for i := range batch {
+		batch[i] = model.NewMetricCounter(fmt.Sprintf("c%d", i), int64(i))
+	}
+	require.NoError(t, sc.SendBatchMetrics(t.Context(), batch[:10]), "the small batch is sent with UpdateBatch")
+	require.NoError(t, sc.SendBatchMetrics(t.Context(), batch), "the large batch is streamed")
+	assert.JSONEq(t, `{"id":"c9","type":"counter","delta":18}`, value(model.TypeCounter, "c9"))
+	assert.JSONEq(t, `{"id":"c2499","type":"counter","delta":2499}`, value(model.TypeCounter, "c2499"))
+
+	wrong := newSender("wrong")
+	err = wrong.SendMetric(t.Context(), model.NewMetricGauge("g", 2))
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), err)
+	err = wrong.SendBatchMetrics(t.Context(), batch)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), err)
+	assert.JSONEq(t, `{"id":"g","type":"gauge","value":1.5}`, value(model.TypeGauge, "g"),
+		"the metric with the invalid signature is not stored")
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, conn.Close())
+	}()
+	_, err = pb.NewMetricsClient(conn).Update(t.Context(), &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(3)})
+	require.NoError(t, err, "the request without the signature is not validated like the HTTP request")
+}
+
+func TestServeGRPC(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeGRPC(ctx, l, "127.0.0.1:0", time.Second, grpc.NewServer())
+	}()
+	cancel()
+	select {
+	case err = <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server is not stopped")
+	}
+	require.ErrorContains(t, ServeGRPC(t.Context(), l, "127.0.0.1:-1", time.Second, grpc.NewServer()), "failed to listen")
+}
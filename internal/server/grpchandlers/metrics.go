@@ -0,0 +1,187 @@
+// Package grpchandlers contains the gRPC service of the server.
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// updatesChunkSize is the number of metrics stored with one UpdateBatch call
+const updatesChunkSize = 1000
+
+// Updater updates metrics
+type Updater interface {
+	Update(context.Context, *model.MetricRequest) (*model.Metric, error)
+}
+
+// BatchUpdater is an interface for batch updating metrics
+type BatchUpdater interface {
+	UpdateBatch(context.Context, []*model.MetricRequest) ([]*model.Metric, error)
+}
+
+// MetricsServer is the gRPC service updating metrics, it works like the update handlers of the HTTP server
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	u        Updater
+	bu       BatchUpdater
+	maxCount int
+}
+
+// NewMetricsServer returns the service, the batch with more than maxCount metrics is rejected, maxCount <= 0 means no limit
+func NewMetricsServer(u Updater, bu BatchUpdater, maxCount int) *MetricsServer {
+	return &MetricsServer{u: u, bu: bu, maxCount: maxCount}
+}
+
+// Update updates the metric and returns the stored one
+func (s *MetricsServer) Update(ctx context.Context, p *pb.Metric) (*pb.Metric, error) {
+	mr, err := metricRequest(p)
+	if err != nil {
+		return nil, statusError(codes.InvalidArgument, fmt.Errorf("failed to unmarshal metric request: %w", err))
+	}
+	m, err := s.u.Update(ctx, mr)
+	if err != nil {
+		return nil, statusError(codes.Internal, fmt.Errorf("failed to update metric: %w", err))
+	}
+	resp, err := m.ToProto()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Errorf("failed response: %w", err).Error())
+	}
+	return resp, nil
+}
+
+// UpdateBatch updates the metrics of the batch and returns the stored ones
+//
+// The batch is validated before storing, so nothing is stored if any metric is not valid.
+// The metrics are stored in chunks of updatesChunkSize, if the storage fails, the chunks stored before the failure are kept
+// and Aborted is returned, which the agent does not retry, so the stored deltas are not applied twice.
+func (s *MetricsServer) UpdateBatch(ctx context.Context, batch *pb.MetricBatch) (*pb.MetricBatch, error) {
+	mrs, err := s.batchRequest(batch)
+	if err != nil {
+		return nil, statusError(codes.InvalidArgument, fmt.Errorf("failed to validate metrics request: %w", err))
+	}
+	ms, err := s.updateChunks(ctx, mrs)
+	if err != nil {
+		return nil, updateError(len(ms), fmt.Errorf("failed to update metric batch: %w", err))
+	}
+	resp, err := model.MetricsToProto(ms)
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Errorf("failed response: %w", err).Error())
+	}
+	return resp, nil
+}
+
+// StreamUpdates updates the metrics of every batch of the stream as it is received and returns the number of the updated metrics
+//
+// Every batch is validated and stored like the batch of UpdateBatch, the batches stored before a failure are kept
+// and Aborted is returned like UpdateBatch does.
+func (s *MetricsServer) StreamUpdates(stream pb.Metrics_StreamUpdatesServer) error {
+	var updated int64
+	for n := 0; ; n++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.StreamUpdatesResponse{Updated: updated})
+		}
+		if err != nil {
+			//nolint:wrapcheck // the status of the stream error is returned as is
+			return err
+		}
+		mrs, err := s.batchRequest(req.GetBatch())
+		if err != nil {
+			err = fmt.Errorf("failed to validate metrics request of batch %d: %w", n, err)
+			if updated > 0 {
+				return updateError(int(updated), err)
+			}
+			return statusError(codes.InvalidArgument, err)
+		}
+		ms, err := s.updateChunks(stream.Context(), mrs)
+		if err != nil {
+			return updateError(int(updated)+len(ms), fmt.Errorf("failed to update metric batch %d: %w", n, err))
+		}
+		updated += int64(len(ms))
+	}
+}
+
+// batchRequest returns the validated metric requests of the batch
+func (s *MetricsServer) batchRequest(batch *pb.MetricBatch) ([]*model.MetricRequest, error) {
+	metrics := batch.GetMetrics()
+	if len(metrics) == 0 {
+		return nil, errors.New("empty batch")
+	}
+	if s.maxCount > 0 && len(metrics) > s.maxCount {
+		return nil, fmt.Errorf("%w: more than %d", model.ErrTooManyMetrics, s.maxCount)
+	}
+	mrs := make([]*model.MetricRequest, len(metrics))
+	for i, p := range metrics {
+		mr, err := metricRequest(p)
+		if err != nil {
+			return nil, fmt.Errorf("metric %d: %w", i, err)
+		}
+		mrs[i] = mr
+	}
+	return mrs, nil
+}
+
+// updateChunks stores the metric requests in chunks, the stored metrics are returned,
+// the metrics stored before the failure are returned with the error
+func (s *MetricsServer) updateChunks(ctx context.Context, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+	ms := make([]*model.Metric, 0, len(mrs))
+	for chunk := range slices.Chunk(mrs, updatesChunkSize) {
+		stored, err := s.bu.UpdateBatch(ctx, chunk)
+		if err != nil {
+			//nolint:wrapcheck // ignore
+			return ms, err
+		}
+		ms = append(ms, stored...)
+	}
+	return ms, nil
+}
+
+// metricRequest returns the validated metric request of the protobuf metric
+func metricRequest(p *pb.Metric) (*model.MetricRequest, error) {
+	m, err := model.MetricFromProto(p)
+	if err != nil {
+		//nolint:wrapcheck // ignore
+		return nil, err
+	}
+	mr := &model.MetricRequest{Metric: m}
+	if err = mr.RequiredValue(); err != nil {
+		//nolint:wrapcheck // ignore
+		return nil, err
+	}
+	return mr, nil
+}
+
+// updateError returns the status of the failed update, Aborted if the metrics are stored before the failure,
+// the code matches 422 of the HTTP handler of the batch
+func updateError(stored int, err error) error {
+	if stored > 0 {
+		return status.Error(codes.Aborted, fmt.Sprintf("%s: %d metrics are stored before the failure", err, stored))
+	}
+	return statusError(codes.Internal, err)
+}
+
+// statusError returns the gRPC status of the error with the code matching the status code of the HTTP handlers,
+// the code is used if the error has no matching code
+func statusError(code codes.Code, err error) error {
+	switch {
+	case errors.Is(err, model.ErrTooManyMetrics):
+		code = codes.ResourceExhausted
+	case errors.Is(err, model.ErrMetricNotFound):
+		code = codes.NotFound
+	case errors.Is(err, model.ErrOutOfOrder):
+		code = codes.FailedPrecondition
+	case errors.Is(err, model.ErrTypeIsNotValid), errors.Is(err, model.ErrValueIsNotValid),
+		errors.Is(err, model.ErrLabelsIsNotValid), errors.Is(err, model.ErrIDIsNotValid),
+		errors.Is(err, model.ErrBoundsMismatch):
+		code = codes.InvalidArgument
+	}
+	return status.Error(code, err.Error())
+}
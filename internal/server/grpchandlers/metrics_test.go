@@ -0,0 +1,180 @@
+package grpchandlers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetricsServer_Update(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	tests := []struct {
+		req       *pb.Metric
+		mockSetup func(*mocks.MockUpdater)
+		want      *pb.Metric
+		name      string
+		wantCode  codes.Code
+	}{
+		{
+			name: "ok",
+			req:  &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1.5)},
+			mockSetup: func(u *mocks.MockUpdater) {
+				u.EXPECT().Update(gomock.Any(), gomock.Any()).Return(model.NewMetricGauge("g", 1.5), nil)
+			},
+			want:     &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1.5)},
+			wantCode: codes.OK,
+		},
+		{
+			name:     "no value",
+			req:      &pb.Metric{Id: "c", Type: model.TypeCounter},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "braces in id",
+			req:      &pb.Metric{Id: `g{a="1"}`, Type: model.TypeGauge, Value: proto.Float64(1)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "bad type",
+			req:      &pb.Metric{Id: "x", Type: "bad", Value: proto.Float64(1)},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "out of order",
+			req:  &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1)},
+			mockSetup: func(u *mocks.MockUpdater) {
+				u.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, model.ErrOutOfOrder)
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "bounds mismatch",
+			req:  &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1)},
+			mockSetup: func(u *mocks.MockUpdater) {
+				u.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, model.ErrBoundsMismatch)
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "storage error",
+			req:  &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1)},
+			mockSetup: func(u *mocks.MockUpdater) {
+				u.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, errors.New("db is down"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := mocks.NewMockUpdater(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(u)
+			}
+			got, err := NewMetricsServer(u, nil, 0).Update(t.Context(), tt.req)
+			require.Equal(t, tt.wantCode, status.Code(err), err)
+			if tt.want != nil {
+				assert.True(t, proto.Equal(tt.want, got), got)
+			}
+		})
+	}
+}
+
+func TestMetricsServer_UpdateBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	gauge := &pb.Metric{Id: "g", Type: model.TypeGauge, Value: proto.Float64(1)}
+	counter := &pb.Metric{Id: "c", Type: model.TypeCounter, Delta: proto.Int64(2)}
+	tests := []struct {
+		req       *pb.MetricBatch
+		mockSetup func(*mocks.MockBatchUpdater)
+		name      string
+		wantLen   int
+		wantCode  codes.Code
+	}{
+		{
+			name: "ok",
+			req:  &pb.MetricBatch{Metrics: []*pb.Metric{gauge, counter}},
+			mockSetup: func(bu *mocks.MockBatchUpdater) {
+				bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(2)).
+					Return([]*model.Metric{model.NewMetricGauge("g", 1), model.NewMetricCounter("c", 2)}, nil)
+			},
+			wantLen:  2,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "empty",
+			req:      &pb.MetricBatch{},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "invalid metric is not stored",
+			req:      &pb.MetricBatch{Metrics: []*pb.Metric{gauge, {Id: "c", Type: model.TypeCounter}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "too many",
+			req:      &pb.MetricBatch{Metrics: []*pb.Metric{gauge, counter, gauge}},
+			wantCode: codes.ResourceExhausted,
+		},
+		{
+			name: "storage error",
+			req:  &pb.MetricBatch{Metrics: []*pb.Metric{gauge, counter}},
+			mockSetup: func(bu *mocks.MockBatchUpdater) {
+				bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).Return(nil, errors.New("db is down"))
+			},
+			wantCode: codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bu := mocks.NewMockBatchUpdater(ctrl)
+			if tt.mockSetup != nil {
+				tt.mockSetup(bu)
+			}
+			got, err := NewMetricsServer(nil, bu, 2).UpdateBatch(t.Context(), tt.req)
+			require.Equal(t, tt.wantCode, status.Code(err), err)
+			assert.Len(t, got.GetMetrics(), tt.wantLen)
+		})
+	}
+}
+
+func TestMetricsServer_updateChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	bu := mocks.NewMockBatchUpdater(ctrl)
+	mrs := make([]*model.MetricRequest, updatesChunkSize+1)
+	for i := range mrs {
+		mrs[i] = &model.MetricRequest{Metric: model.NewMetricCounter("c", 1)}
+	}
+	gomock.InOrder(
+		bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(updatesChunkSize)).Return(make([]*model.Metric, updatesChunkSize), nil),
+		bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(1)).Return(make([]*model.Metric, 1), nil),
+	)
+	ms, err := NewMetricsServer(nil, bu, 0).updateChunks(t.Context(), mrs)
+	require.NoError(t, err)
+	assert.Len(t, ms, updatesChunkSize+1)
+}
+
+func TestMetricsServer_UpdateBatch_FailedAfterFirstChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	bu := mocks.NewMockBatchUpdater(ctrl)
+	batch := &pb.MetricBatch{Metrics: make([]*pb.Metric, updatesChunkSize+1)}
+	for i := range batch.Metrics {
+		batch.Metrics[i] = &pb.Metric{Id: "c", Type: model.TypeCounter, Delta: proto.Int64(1)}
+	}
+	gomock.InOrder(
+		bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(updatesChunkSize)).Return(make([]*model.Metric, updatesChunkSize), nil),
+		bu.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(1)).Return(nil, errors.New("db is down")),
+	)
+	_, err := NewMetricsServer(nil, bu, 0).UpdateBatch(t.Context(), batch)
+	require.Equal(t, codes.Aborted, status.Code(err), "the partially stored batch is not retried")
+	assert.ErrorContains(t, err, fmt.Sprintf("%d metrics are stored", updatesChunkSize))
+}
@@ -6,11 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/config"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/grpchandlers"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/interceptor/icompress"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/interceptor/ilogger"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/interceptor/isign"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/middleware/mcompress"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/middleware/mlogger"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/middleware/msign"
@@ -18,12 +24,18 @@ import (
 	"github.com/korobkovandrey/runtime-metrics/internal/server/repository/pgxstorage"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/service"
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"google.golang.org/grpc"
 )
 
 // Handler is a handler for the HTTP server.
 type Handler struct {
 	chi.Router
-	closers []func() error
+	updater      *service.Updater
+	batchUpdater *service.BatchUpdater
+	key          atomic.Pointer[[]byte]
+	fs           *repository.FileStorage
+	closers      []func() error
+	maxBatchSize int
 }
 
 // NewHandler returns a new Handler.
@@ -33,7 +45,18 @@ func NewHandler() *Handler {
 
 // Configure configures the handler.
 func (h *Handler) Configure(ctx context.Context, cfg *config.Config, l *logging.ZapLogger) error {
-	h.Use(mcompress.GzipCompressed(l), msign.Signer([]byte(cfg.Key)), mlogger.RequestLogger(l))
+	policy, err := service.ParseOutOfOrderPolicy(cfg.OutOfOrderPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	key := []byte(cfg.Key)
+	h.key.Store(&key)
+	h.Use(mcompress.GzipCompressed(l))
+	if cfg.MaxBodySize > 0 {
+		// the decompressed body is limited before it is spooled by the signer and the handlers
+		h.Use(middleware.RequestSize(cfg.MaxBodySize))
+	}
+	h.Use(msign.KeySigner(h.signKey), mlogger.RequestLogger(l))
 	if cfg.Pprof {
 		h.Mount("/debug", middleware.Profiler())
 	}
@@ -41,6 +64,7 @@ func (h *Handler) Configure(ctx context.Context, cfg *config.Config, l *logging.
 		service.FinderRepository
 		service.UpdaterRepository
 		service.BatchUpdaterRepository
+		service.MetaRepository
 	}
 	if cfg.DatabaseDSN != "" {
 		ps, err := pgxstorage.NewPGXStorage(ctx, &pgxstorage.Config{
@@ -65,6 +89,7 @@ func (h *Handler) Configure(ctx context.Context, cfg *config.Config, l *logging.
 			}
 			h.closers = append(h.closers, fs.Close)
 			go fs.Run(ctx, l)
+			h.fs = fs
 			r = fs
 		} else {
 			r = ms
@@ -73,12 +98,48 @@ func (h *Handler) Configure(ctx context.Context, cfg *config.Config, l *logging.
 	}
 
 	finder := service.NewFinder(r)
-	if err := h.setIndexRoute(finder); err != nil {
+	metaService := service.NewMetaService(r)
+	if err := h.setIndexRoute(finder, metaService); err != nil {
 		return fmt.Errorf("failed to set index route: %w", err)
 	}
-	h.setUpdateRoutes(service.NewUpdater(r))
-	h.setUpdatesRoute(service.NewBatchUpdater(r))
+	h.updater = service.NewUpdater(r, policy)
+	h.batchUpdater = service.NewBatchUpdater(r, policy)
+	h.maxBatchSize = cfg.MaxBatchSize
+	h.setUpdateRoutes(h.updater)
+	h.setUpdatesRoute(h.batchUpdater, cfg.MaxBatchSize)
 	h.setValueRoutes(finder)
+	h.setMetaRoutes(metaService)
+	return nil
+}
+
+// NewGRPCServer returns the gRPC server updating metrics with the services of the configured handler.
+//
+// The calls are logged, compressed and signed with the key of the handler like the HTTP requests.
+func (h *Handler) NewGRPCServer(l *logging.ZapLogger) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(ilogger.UnaryRequestLogger(l), icompress.UnaryGzipCompressed(l),
+			isign.UnaryKeySigner(h.signKey)),
+		grpc.ChainStreamInterceptor(ilogger.StreamRequestLogger(l), icompress.StreamGzipCompressed(l),
+			isign.StreamKeySigner(h.signKey)),
+	)
+	pb.RegisterMetricsServer(s, grpchandlers.NewMetricsServer(h.updater, h.batchUpdater, h.maxBatchSize))
+	return s
+}
+
+// Reload applies the reloadable fields of the config: the key and the file store interval.
+func (h *Handler) Reload(cfg *config.Config) {
+	key := []byte(cfg.Key)
+	h.key.Store(&key)
+	if h.fs != nil {
+		h.fs.SetStoreInterval(cfg.StoreInterval)
+	}
+}
+
+// signKey returns the current key of the signer.
+func (h *Handler) signKey() []byte {
+	if key := h.key.Load(); key != nil {
+		return *key
+	}
 	return nil
 }
 
@@ -94,8 +155,8 @@ func (h *Handler) Close() error {
 }
 
 // setIndexRoute sets the index route.
-func (h *Handler) setIndexRoute(s handlers.AllFinder) error {
-	indexHandler, err := handlers.NewIndexHandler(s)
+func (h *Handler) setIndexRoute(s handlers.AllFinder, ms handlers.AllMetaFinder) error {
+	indexHandler, err := handlers.NewIndexHandler(s, ms)
 	if err != nil {
 		return fmt.Errorf("failed to create index handler: %w", err)
 	}
@@ -134,8 +195,8 @@ func (h *Handler) setUpdateRoutes(s handlers.Updater) {
 }
 
 // setUpdatesRoute sets the updates route.
-func (h *Handler) setUpdatesRoute(s handlers.BatchUpdater) {
-	h.Post("/updates/", handlers.NewUpdatesHandler(s))
+func (h *Handler) setUpdatesRoute(s handlers.BatchUpdater, maxBatchSize int) {
+	h.Post("/updates/", handlers.NewUpdatesHandler(s, maxBatchSize))
 }
 
 // setValueRoutes sets the value routes.
@@ -145,3 +206,13 @@ func (h *Handler) setValueRoutes(s handlers.Finder) {
 		r.Get("/{type}/{name}", handlers.NewValueURIHandler(s))
 	})
 }
+
+// setMetaRoutes sets the metadata routes.
+func (h *Handler) setMetaRoutes(s handlers.MetaKeeper) {
+	h.Route("/meta", func(r chi.Router) {
+		r.Get("/", handlers.NewMetaListHandler(s))
+		r.Put("/", handlers.NewMetasPutHandler(s))
+		r.Get("/{type}/{name}", handlers.NewMetaGetHandler(s))
+		r.Put("/{type}/{name}", handlers.NewMetaPutHandler(s))
+	})
+}
@@ -3,17 +3,25 @@ package server
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/config"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers/mocks"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"go.uber.org/zap/zapcore"
 )
 
 type testCase struct {
@@ -32,12 +40,12 @@ func TestHandler_setIndexRoute(t *testing.T) {
 	defer ctrl.Finish()
 	tests := []struct {
 		name      string
-		mockSetup func(*mocks.MockAllFinder)
+		mockSetup func(*mocks.MockAllFinder, *mocks.MockAllMetaFinder)
 		testCase
 	}{
 		{
 			name: "index ok",
-			mockSetup: func(s *mocks.MockAllFinder) {
+			mockSetup: func(s *mocks.MockAllFinder, ms *mocks.MockAllMetaFinder) {
 				s.EXPECT().
 					FindAll(gomock.Any()).
 					Return([]*model.Metric{
@@ -45,6 +53,9 @@ func TestHandler_setIndexRoute(t *testing.T) {
 						model.NewMetricCounter("PollCount", 10),
 						model.NewMetricGauge("RandomValue", 12.55),
 					}, nil)
+				ms.EXPECT().
+					FindAll(gomock.Any()).
+					Return([]*model.Meta{model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Bytes of allocated heap objects")}, nil)
 			},
 			testCase: testCase{
 				method:          http.MethodGet,
@@ -55,15 +66,17 @@ func TestHandler_setIndexRoute(t *testing.T) {
 					"Alloc", "123.4",
 					"PollCount", "10",
 					"RandomValue", "12.55",
+					"bytes", "Bytes of allocated heap objects",
 				},
 			},
 		},
 		{
 			name: "index service error",
-			mockSetup: func(s *mocks.MockAllFinder) {
+			mockSetup: func(s *mocks.MockAllFinder, ms *mocks.MockAllMetaFinder) {
 				s.EXPECT().
 					FindAll(gomock.Any()).
 					Return(nil, errors.New("service error"))
+				ms.EXPECT().FindAll(gomock.Any()).MaxTimes(0)
 			},
 			testCase: testCase{
 				method:   http.MethodGet,
@@ -75,12 +88,13 @@ func TestHandler_setIndexRoute(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := mocks.NewMockAllFinder(ctrl)
-			tt.mockSetup(s)
+			ms := mocks.NewMockAllMetaFinder(ctrl)
+			tt.mockSetup(s, ms)
 			h := NewHandler()
 			currentDir, err := os.Getwd()
 			require.NoError(t, err)
 			t.Chdir("../..")
-			err = h.setIndexRoute(s)
+			err = h.setIndexRoute(s, ms)
 			require.NoError(t, err)
 			t.Chdir(currentDir)
 			testHelper(t, h, tt.testCase)
@@ -363,6 +377,118 @@ func TestHandler_setUpdatesRoute(t *testing.T) {
 				wantJSON:        `[{"type":"gauge","id":"test","value":66.34},{"type":"counter","id":"test","delta":10}]`,
 			},
 		},
+		{
+			name: "updatesJSON histogram valid",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				s.EXPECT().
+					UpdateBatch(gomock.Any(), []*model.MetricRequest{
+						{Metric: model.NewMetricHistogram("test", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{2, 1}, Sum: 3.5})},
+					}).
+					Return([]*model.Metric{
+						model.NewMetricHistogram("test", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{4, 1}, Sum: 4}),
+					}, nil)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"histogram","id":"test","histogram":{"bounds":[1],"counts":[2,1],"sum":3.5}}]`,
+				wantCode:        http.StatusOK,
+				wantContentType: "application/json",
+				wantJSON:        `[{"type":"histogram","id":"test","histogram":{"bounds":[1],"counts":[4,1],"sum":4}}]`,
+			},
+		},
+		{
+			name: "updatesJSON labels valid",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				m := model.NewMetricGauge("cpu", 0.5)
+				m.Labels = model.Labels{"host": "h1", "core": "0"}
+				s.EXPECT().
+					UpdateBatch(gomock.Any(), []*model.MetricRequest{{Metric: m}}).
+					Return([]*model.Metric{m}, nil)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"gauge","id":"cpu","value":0.5,"labels":{"host":"h1","core":"0"}}]`,
+				wantCode:        http.StatusOK,
+				wantContentType: "application/json",
+				wantJSON:        `[{"type":"gauge","id":"cpu","value":0.5,"labels":{"core":"0","host":"h1"}}]`,
+			},
+		},
+		{
+			name: "updatesJSON set members valid",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				sketch := hll.New(hll.MinPrecision)
+				sketch.Add("u1")
+				sketch.Add("u2")
+				s.EXPECT().
+					UpdateBatch(gomock.Any(), []*model.MetricRequest{
+						{Metric: &model.Metric{MType: model.TypeSet, ID: "users", Members: []string{"u1", "u2"}}},
+					}).
+					Return([]*model.Metric{model.NewMetricSet("users", sketch)}, nil)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"set","id":"users","members":["u1","u2"]}]`,
+				wantCode:        http.StatusOK,
+				wantContentType: "application/json",
+				containsStrings: []string{`"type":"set"`, `"precision":4`},
+			},
+		},
+		{
+			name: "updatesJSON set missing value",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"set","id":"users"}]`,
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"Bad Request"},
+			},
+		},
+		{
+			name: "updatesJSON labels invalid name",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"gauge","id":"cpu","value":0.5,"labels":{"host-name":"h1"}}]`,
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"Bad Request", "labels is not valid"},
+			},
+		},
+		{
+			name: "updatesJSON histogram invalid counts",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"histogram","id":"test","histogram":{"bounds":[1],"counts":[2],"sum":3.5}}]`,
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"Bad Request", "value is not valid"},
+			},
+		},
+		{
+			name: "updatesJSON histogram bounds mismatch",
+			mockSetup: func(s *mocks.MockBatchUpdater) {
+				s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: %w", model.ErrValueIsNotValid, model.ErrBoundsMismatch))
+			},
+			testCase: testCase{
+				method:          http.MethodPost,
+				url:             "/updates/",
+				postBody:        `[{"type":"histogram","id":"test","histogram":{"bounds":[1],"counts":[2,1],"sum":3.5}}]`,
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"Bad Request", "value is not valid"},
+			},
+		},
 		{
 			name: "updatesJSON invalid type",
 			mockSetup: func(s *mocks.MockBatchUpdater) {
@@ -431,7 +557,7 @@ func TestHandler_setUpdatesRoute(t *testing.T) {
 			h := NewHandler()
 			s := mocks.NewMockBatchUpdater(ctrl)
 			tt.mockSetup(s)
-			h.setUpdatesRoute(s)
+			h.setUpdatesRoute(s, 0)
 			testHelper(t, h, tt.testCase)
 		})
 	}
@@ -475,6 +601,80 @@ func TestHandler_setValueRoutes(t *testing.T) {
 				wantBody:        "111",
 			},
 		},
+		{
+			name: "valueURI histogram valid",
+			mockSetup: func(s *mocks.MockFinder) {
+				s.EXPECT().
+					Find(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: model.NewMetricHistogram("test",
+						&model.Histogram{Counts: []uint64{1}})})).
+					Return(model.NewMetricHistogram("test", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{2, 1}, Sum: 3.5}), nil)
+			},
+			testCase: testCase{
+				method:          http.MethodGet,
+				url:             "/value/histogram/test",
+				wantCode:        http.StatusOK,
+				wantContentType: "text/plain; charset=utf-8",
+				wantJSON:        `{"bounds":[1],"counts":[2,1],"sum":3.5}`,
+			},
+		},
+		{
+			name: "valueURI set cardinality",
+			mockSetup: func(s *mocks.MockFinder) {
+				sketch := hll.New(hll.DefaultPrecision)
+				for _, v := range []string{"u1", "u2", "u1", "u3"} {
+					sketch.Add(v)
+				}
+				s.EXPECT().Find(gomock.Any(), gomock.Any()).Return(model.NewMetricSet("users", sketch), nil)
+			},
+			testCase: testCase{
+				method:          http.MethodGet,
+				url:             "/value/set/users",
+				wantCode:        http.StatusOK,
+				wantContentType: "text/plain; charset=utf-8",
+				wantBody:        "3",
+			},
+		},
+		{
+			name: "valueURI summary quantile",
+			mockSetup: func(s *mocks.MockFinder) {
+				td := tdigest.New(tdigest.DefaultCompression)
+				for i := 1; i <= 5; i++ {
+					td.Add(float64(i))
+				}
+				s.EXPECT().Find(gomock.Any(), gomock.Any()).Return(model.NewMetricSummary("test", td), nil)
+			},
+			testCase: testCase{
+				method:          http.MethodGet,
+				url:             "/value/summary/test?q=0.5",
+				wantCode:        http.StatusOK,
+				wantContentType: "text/plain; charset=utf-8",
+				wantBody:        "3",
+			},
+		},
+		{
+			name: "valueURI summary invalid quantile",
+			mockSetup: func(s *mocks.MockFinder) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			testCase: testCase{
+				method:          http.MethodGet,
+				url:             "/value/summary/test?q=1.5",
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"quantile must be a number from 0 to 1"},
+			},
+		},
+		{
+			name: "valueURI quantile for gauge",
+			mockSetup: func(s *mocks.MockFinder) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			testCase: testCase{
+				method:          http.MethodGet,
+				url:             "/value/gauge/test?q=0.5",
+				wantCode:        http.StatusBadRequest,
+				containsStrings: []string{"quantile is supported for summary only"},
+			},
+		},
 		{
 			name: "valueURI service error",
 			mockSetup: func(s *mocks.MockFinder) {
@@ -613,6 +813,30 @@ func TestHandler_setValueRoutes(t *testing.T) {
 	}
 }
 
+func TestHandler_Configure_MaxBodySize(t *testing.T) {
+	l, err := logging.NewZapLogger(zapcore.FatalLevel)
+	require.NoError(t, err)
+	h := NewHandler()
+	// the index template is parsed relative to the root of the repository
+	t.Chdir("../..")
+	require.NoError(t, h.Configure(t.Context(), &config.Config{Key: "secret", OutOfOrderPolicy: "accept", MaxBodySize: 64}, l))
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	post := func(body string) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, ts.URL+"/updates/", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("HashSHA256", sign.MakeToString([]byte(body), []byte("secret")))
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, post(`[{"type":"counter","id":"a","delta":1}]`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge,
+		post(`[{"type":"counter","id":"a","delta":1},{"type":"counter","id":"b","delta":1}]`))
+}
+
 func testRequest(
 	t *testing.T, ts *httptest.Server,
 	method, path string, postBody io.Reader) (body []byte, statusCode int, contentType string) {
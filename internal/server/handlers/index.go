@@ -13,25 +13,58 @@ import (
 //
 //go:generate mockgen -source=index.go -destination=mocks/mock_allfinder.go -package=mocks
 type AllFinder interface {
-	FindAll(context.Context) ([]*model.Metric, error)
+	FindAll(context.Context, ...*model.LabelMatcher) ([]*model.Metric, error)
+}
+
+// AllMetaFinder is an interface for finding the metadata of all metrics
+type AllMetaFinder interface {
+	FindAll(context.Context) ([]*model.Meta, error)
+}
+
+// indexRow is a row of the index page
+type indexRow struct {
+	*model.Metric
+	Meta *model.Meta
 }
 
 // NewIndexHandler creates a new index handler
-func NewIndexHandler(s AllFinder) (http.HandlerFunc, error) {
+//
+// The metrics can be filtered by labels with the match query parameters, e.g. /?match=host=h1.
+func NewIndexHandler(s AllFinder, ms AllMetaFinder) (http.HandlerFunc, error) {
 	tpl, err := template.ParseFiles("./web/template/index.html")
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
+		matchers, err := parseMatchers(r)
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, err)
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+model.ErrLabelsIsNotValid.Error(), http.StatusBadRequest)
+			return
+		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		data, err := s.FindAll(r.Context())
+		data, err := s.FindAll(r.Context(), matchers...)
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find all: %w", err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+		metas, err := ms.FindAll(r.Context())
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find all metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		metaMap := make(map[string]*model.Meta, len(metas))
+		for _, meta := range metas {
+			metaMap[meta.Key()] = meta
+		}
+		rows := make([]indexRow, len(data))
+		for i, m := range data {
+			rows[i] = indexRow{Metric: m, Meta: metaMap[(&model.Meta{MType: m.MType, ID: m.ID}).Key()]}
+		}
 		w.WriteHeader(http.StatusOK)
-		if err = tpl.Execute(w, data); err != nil {
+		if err = tpl.Execute(w, rows); err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to execute template: %w", err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
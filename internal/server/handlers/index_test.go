@@ -21,6 +21,7 @@ func TestNewIndexHandler(t *testing.T) {
 		serviceError    error
 		name            string
 		serviceResponse []*model.Metric
+		metaResponse    []*model.Meta
 		containsStrings []string
 		wantCode        int
 	}{
@@ -31,9 +32,12 @@ func TestNewIndexHandler(t *testing.T) {
 				model.NewMetricCounter("PollCount", 10),
 				model.NewMetricGauge("RandomValue", 12.55),
 			},
+			metaResponse: []*model.Meta{
+				model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Bytes of allocated heap objects"),
+			},
 			wantCode: http.StatusOK,
 			containsStrings: []string{
-				"Alloc", "123.4",
+				"Alloc", "123.4", "bytes", "Bytes of allocated heap objects",
 				"PollCount", "10",
 				"RandomValue", "12.55",
 			},
@@ -49,11 +53,13 @@ func TestNewIndexHandler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := mocks.NewMockAllFinder(ctrl)
 			s.EXPECT().FindAll(gomock.Any()).Return(tt.serviceResponse, tt.serviceError)
+			ms := mocks.NewMockAllMetaFinder(ctrl)
+			ms.EXPECT().FindAll(gomock.Any()).Return(tt.metaResponse, nil).MaxTimes(1)
 
 			currentDir, err := os.Getwd()
 			require.NoError(t, err)
 			t.Chdir("../../..")
-			handler, err := NewIndexHandler(s)
+			handler, err := NewIndexHandler(s, ms)
 			require.NoError(t, err)
 			t.Chdir(currentDir)
 
@@ -69,3 +75,38 @@ func TestNewIndexHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestNewIndexHandler_Matchers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	currentDir, err := os.Getwd()
+	require.NoError(t, err)
+	t.Chdir("../../..")
+	s := mocks.NewMockAllFinder(ctrl)
+	ms := mocks.NewMockAllMetaFinder(ctrl)
+	ms.EXPECT().FindAll(gomock.Any()).Return(nil, nil).AnyTimes()
+	handler, err := NewIndexHandler(s, ms)
+	require.NoError(t, err)
+	t.Chdir(currentDir)
+
+	t.Run("valid", func(t *testing.T) {
+		m := model.NewMetricGauge("cpu", 1.5)
+		m.Labels = model.Labels{"host": "h1"}
+		lm, err := model.ParseLabelMatcher("host=h1")
+		require.NoError(t, err)
+		s.EXPECT().FindAll(gomock.Any(), gomock.Eq(lm)).Return([]*model.Metric{m}, nil)
+		r := httptest.NewRequest(http.MethodGet, "/?match=host=h1", http.NoBody)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "cpu{host=&#34;h1&#34;}")
+	})
+
+	t.Run("invalid matcher", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?match=host", http.NoBody)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// MetaKeeper reads and saves the metrics metadata
+//
+//go:generate mockgen -source=meta.go -destination=mocks/mock_metakeeper.go -package=mocks
+type MetaKeeper interface {
+	Find(ctx context.Context, t, id string) (*model.Meta, error)
+	FindAll(ctx context.Context) ([]*model.Meta, error)
+	Save(ctx context.Context, metas []*model.Meta) error
+}
+
+// NewMetaGetHandler returns a handler that returns the metadata of the metric.
+func NewMetaGetHandler(s MetaKeeper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		meta := &model.Meta{MType: r.PathValue("type"), ID: r.PathValue("name")}
+		if err := meta.Validate(); err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to validate metadata request: %w", err))
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		meta, err := s.Find(r.Context(), meta.MType, meta.ID)
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find metadata: %w", err))
+			if errors.Is(err, model.ErrMetaNotFound) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		responseMarshaled(meta, w, r)
+	}
+}
+
+// NewMetaListHandler returns a handler that returns the metadata of all metrics.
+func NewMetaListHandler(s MetaKeeper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metas, err := s.FindAll(r.Context())
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find all metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if metas == nil {
+			metas = []*model.Meta{}
+		}
+		responseMarshaled(metas, w, r)
+	}
+}
+
+// NewMetaPutHandler returns a handler that saves the metadata of the metric.
+//
+// The type and the name are taken from the path, the body contains unit, help and description.
+func NewMetaPutHandler(s MetaKeeper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		meta, err := model.UnmarshalMetaFromReader(r.Body)
+		if err == nil {
+			meta.MType, meta.ID = r.PathValue("type"), r.PathValue("name")
+			err = meta.Validate()
+		}
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to unmarshal metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = s.Save(r.Context(), []*model.Meta{meta}); err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to save metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		responseMarshaled(meta, w, r)
+	}
+}
+
+// NewMetasPutHandler returns a handler that saves the list of metadata.
+func NewMetasPutHandler(s MetaKeeper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		metas, err := model.UnmarshalMetasFromReader(r.Body)
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to unmarshal metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = s.Save(r.Context(), metas); err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to save metadata: %w", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		responseMarshaled(metas, w, r)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers/mocks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNewMetaGetHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		mockSetup       func(*mocks.MockMetaKeeper)
+		name            string
+		mType           string
+		wantJSON        string
+		containsStrings []string
+		wantCode        int
+		emptyID         bool
+	}{
+		{
+			name:  "valid",
+			mType: model.TypeGauge,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Find(gomock.Any(), model.TypeGauge, "Alloc").
+					Return(model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Allocated heap"), nil)
+			},
+			wantCode: http.StatusOK,
+			wantJSON: `{"type":"gauge","id":"Alloc","unit":"bytes","help":"Allocated heap"}`,
+		},
+		{
+			name:  "invalid type",
+			mType: "invalid",
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"type is not valid"},
+		},
+		{
+			name:    "empty id",
+			mType:   model.TypeGauge,
+			emptyID: true,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"id is not valid"},
+		},
+		{
+			name:  "not found",
+			mType: model.TypeGauge,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, model.ErrMetaNotFound)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:  "service error",
+			mType: model.TypeGauge,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("unexpected error"))
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewMockMetaKeeper(ctrl)
+			tt.mockSetup(s)
+			id := "Alloc"
+			if tt.emptyID {
+				id = ""
+			}
+			r := httptest.NewRequest(http.MethodGet, "/meta/"+tt.mType+"/"+id, http.NoBody)
+			r.SetPathValue("type", tt.mType)
+			r.SetPathValue("name", id)
+			w := httptest.NewRecorder()
+			NewMetaGetHandler(s)(w, r)
+			require.Equal(t, tt.wantCode, w.Code)
+			body := w.Body.String()
+			if tt.wantJSON != "" {
+				require.JSONEq(t, tt.wantJSON, body)
+			}
+			for _, str := range tt.containsStrings {
+				require.Contains(t, body, str)
+			}
+		})
+	}
+}
+
+func TestNewMetaPutHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tests := []struct {
+		mockSetup func(*mocks.MockMetaKeeper)
+		name      string
+		json      string
+		wantJSON  string
+		wantCode  int
+	}{
+		{
+			name: "valid",
+			json: `{"unit":"bytes","help":"Allocated heap","description":"See runtime.MemStats"}`,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Save(gomock.Any(), []*model.Meta{{
+					MType: model.TypeGauge, ID: "Alloc", Unit: "bytes", Help: "Allocated heap", Description: "See runtime.MemStats",
+				}}).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantJSON: `{"type":"gauge","id":"Alloc","unit":"bytes","help":"Allocated heap","description":"See runtime.MemStats"}`,
+		},
+		{
+			name: "path overrides body",
+			json: `{"type":"counter","id":"Other","unit":"bytes"}`,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Save(gomock.Any(), []*model.Meta{{MType: model.TypeGauge, ID: "Alloc", Unit: "bytes"}}).Return(nil)
+			},
+			wantCode: http.StatusOK,
+			wantJSON: `{"type":"gauge","id":"Alloc","unit":"bytes"}`,
+		},
+		{
+			name: "invalid json",
+			json: `invalid`,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Save(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "service error",
+			json: `{"unit":"bytes"}`,
+			mockSetup: func(s *mocks.MockMetaKeeper) {
+				s.EXPECT().Save(gomock.Any(), gomock.Any()).Return(errors.New("unexpected error"))
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewMockMetaKeeper(ctrl)
+			tt.mockSetup(s)
+			r := httptest.NewRequest(http.MethodPut, "/meta/gauge/Alloc", bytes.NewBufferString(tt.json))
+			r.SetPathValue("type", model.TypeGauge)
+			r.SetPathValue("name", "Alloc")
+			w := httptest.NewRecorder()
+			NewMetaPutHandler(s)(w, r)
+			require.Equal(t, tt.wantCode, w.Code)
+			if tt.wantJSON != "" {
+				require.JSONEq(t, tt.wantJSON, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewMetasPutHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("valid", func(t *testing.T) {
+		s := mocks.NewMockMetaKeeper(ctrl)
+		s.EXPECT().Save(gomock.Any(), []*model.Meta{
+			model.NewMeta(model.TypeGauge, "Alloc", "bytes", ""),
+			model.NewMeta(model.TypeCounter, "PollCount", "", "Number of polls"),
+		}).Return(nil)
+		r := httptest.NewRequest(http.MethodPut, "/meta/", bytes.NewBufferString(
+			`[{"type":"gauge","id":"Alloc","unit":"bytes"},{"type":"counter","id":"PollCount","help":"Number of polls"}]`))
+		w := httptest.NewRecorder()
+		NewMetasPutHandler(s)(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		s := mocks.NewMockMetaKeeper(ctrl)
+		s.EXPECT().Save(gomock.Any(), gomock.Any()).MaxTimes(0)
+		r := httptest.NewRequest(http.MethodPut, "/meta/", bytes.NewBufferString(`[{"type":"invalid","id":"Alloc"}]`))
+		w := httptest.NewRecorder()
+		NewMetasPutHandler(s)(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "type is not valid")
+	})
+
+	t.Run("empty id", func(t *testing.T) {
+		s := mocks.NewMockMetaKeeper(ctrl)
+		s.EXPECT().Save(gomock.Any(), gomock.Any()).MaxTimes(0)
+		r := httptest.NewRequest(http.MethodPut, "/meta/", bytes.NewBufferString(`[{"type":"gauge","id":""}]`))
+		w := httptest.NewRecorder()
+		NewMetasPutHandler(s)(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "id is not valid")
+	})
+}
+
+func TestNewMetaListHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("empty", func(t *testing.T) {
+		s := mocks.NewMockMetaKeeper(ctrl)
+		s.EXPECT().FindAll(gomock.Any()).Return(nil, nil)
+		w := httptest.NewRecorder()
+		NewMetaListHandler(s)(w, httptest.NewRequest(http.MethodGet, "/meta/", http.NoBody))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `[]`, w.Body.String())
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		s := mocks.NewMockMetaKeeper(ctrl)
+		s.EXPECT().FindAll(gomock.Any()).Return(nil, errors.New("unexpected error"))
+		w := httptest.NewRecorder()
+		NewMetaListHandler(s)(w, httptest.NewRequest(http.MethodGet, "/meta/", http.NoBody))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
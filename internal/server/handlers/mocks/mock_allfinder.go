@@ -42,16 +42,60 @@ func (m *MockAllFinder) EXPECT() *MockAllFinderMockRecorder {
 }
 
 // FindAll mocks base method.
-func (m *MockAllFinder) FindAll(arg0 context.Context) ([]*model.Metric, error) {
+func (m *MockAllFinder) FindAll(arg0 context.Context, arg1 ...*model.LabelMatcher) ([]*model.Metric, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindAll", arg0)
+	varargs := []any{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindAll", varargs...)
 	ret0, _ := ret[0].([]*model.Metric)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAll indicates an expected call of FindAll.
-func (mr *MockAllFinderMockRecorder) FindAll(arg0 any) *gomock.Call {
+func (mr *MockAllFinderMockRecorder) FindAll(arg0 any, arg1 ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockAllFinder)(nil).FindAll), varargs...)
+}
+
+// MockAllMetaFinder is a mock of AllMetaFinder interface.
+type MockAllMetaFinder struct {
+	isgomock struct{}
+	ctrl     *gomock.Controller
+	recorder *MockAllMetaFinderMockRecorder
+}
+
+// MockAllMetaFinderMockRecorder is the mock recorder for MockAllMetaFinder.
+type MockAllMetaFinderMockRecorder struct {
+	mock *MockAllMetaFinder
+}
+
+// NewMockAllMetaFinder creates a new mock instance.
+func NewMockAllMetaFinder(ctrl *gomock.Controller) *MockAllMetaFinder {
+	mock := &MockAllMetaFinder{ctrl: ctrl}
+	mock.recorder = &MockAllMetaFinderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAllMetaFinder) EXPECT() *MockAllMetaFinderMockRecorder {
+	return m.recorder
+}
+
+// FindAll mocks base method.
+func (m *MockAllMetaFinder) FindAll(arg0 context.Context) ([]*model.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", arg0)
+	ret0, _ := ret[0].([]*model.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockAllMetaFinderMockRecorder) FindAll(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockAllFinder)(nil).FindAll), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockAllMetaFinder)(nil).FindAll), arg0)
 }
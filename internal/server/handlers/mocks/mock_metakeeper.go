@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: meta.go
+//
+// Generated by this command:
+//
+//	mockgen -source=meta.go -destination=mocks/mock_metakeeper.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/korobkovandrey/runtime-metrics/internal/model"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMetaKeeper is a mock of MetaKeeper interface.
+type MockMetaKeeper struct {
+	isgomock struct{}
+	ctrl     *gomock.Controller
+	recorder *MockMetaKeeperMockRecorder
+}
+
+// MockMetaKeeperMockRecorder is the mock recorder for MockMetaKeeper.
+type MockMetaKeeperMockRecorder struct {
+	mock *MockMetaKeeper
+}
+
+// NewMockMetaKeeper creates a new mock instance.
+func NewMockMetaKeeper(ctrl *gomock.Controller) *MockMetaKeeper {
+	mock := &MockMetaKeeper{ctrl: ctrl}
+	mock.recorder = &MockMetaKeeperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetaKeeper) EXPECT() *MockMetaKeeperMockRecorder {
+	return m.recorder
+}
+
+// Find mocks base method.
+func (m *MockMetaKeeper) Find(ctx context.Context, t, id string) (*model.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", ctx, t, id)
+	ret0, _ := ret[0].(*model.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockMetaKeeperMockRecorder) Find(ctx, t, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockMetaKeeper)(nil).Find), ctx, t, id)
+}
+
+// FindAll mocks base method.
+func (m *MockMetaKeeper) FindAll(ctx context.Context) ([]*model.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", ctx)
+	ret0, _ := ret[0].([]*model.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockMetaKeeperMockRecorder) FindAll(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockMetaKeeper)(nil).FindAll), ctx)
+}
+
+// Save mocks base method.
+func (m *MockMetaKeeper) Save(ctx context.Context, metas []*model.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, metas)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockMetaKeeperMockRecorder) Save(ctx, metas any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockMetaKeeper)(nil).Save), ctx, metas)
+}
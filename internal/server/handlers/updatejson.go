@@ -17,9 +17,16 @@ type Updater interface {
 }
 
 // NewUpdateJSONHandler returns a handler for updating metrics
+//
+// The metric is encoded with JSON or with protobuf if the content type is application/x-protobuf,
+// the response is encoded the same way as the request.
 func NewUpdateJSONHandler(s Updater) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mr, err := model.UnmarshalMetricRequestFromReader(r.Body)
+		unmarshal := model.UnmarshalMetricRequestFromReader
+		if isProtobuf(r) {
+			unmarshal = model.UnmarshalMetricRequestFromProtoReader
+		}
+		mr, err := unmarshal(r.Body)
 		if err == nil {
 			err = mr.RequiredValue()
 		}
@@ -33,6 +40,10 @@ func NewUpdateJSONHandler(s Updater) http.HandlerFunc {
 				errMsg += ": " + model.ErrTypeIsNotValid.Error()
 			case errors.Is(err, model.ErrValueIsNotValid):
 				errMsg += ": " + model.ErrValueIsNotValid.Error()
+			case errors.Is(err, model.ErrLabelsIsNotValid):
+				errMsg += ": " + model.ErrLabelsIsNotValid.Error()
+			case errors.Is(err, model.ErrIDIsNotValid):
+				errMsg += ": " + model.ErrIDIsNotValid.Error()
 			}
 			http.Error(w, errMsg, http.StatusBadRequest)
 			return
@@ -44,9 +55,17 @@ func NewUpdateJSONHandler(s Updater) http.HandlerFunc {
 				http.NotFound(w, r)
 				return
 			}
+			if errors.Is(err, model.ErrValueIsNotValid) {
+				http.Error(w, http.StatusText(http.StatusBadRequest)+": "+model.ErrValueIsNotValid.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, model.ErrOutOfOrder) {
+				http.Error(w, http.StatusText(http.StatusConflict)+": "+model.ErrOutOfOrder.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
-		responseMarshaled(m, w, r)
+		responseMetric(m, w, r)
 	}
 }
@@ -8,9 +8,11 @@ import (
 	"testing"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers/mocks"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestNewUpdateJSONHandler(t *testing.T) {
@@ -58,6 +60,28 @@ func TestNewUpdateJSONHandler(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 		},
+		{
+			name: "timestamped update",
+			json: `{"type":"gauge","id":"test","value":12.34,"timestamp":1700000000000}`,
+			mockSetup: func(s *mocks.MockUpdater) {
+				m := model.NewMetricGauge("test", 12.34)
+				m.Timestamp = 1700000000000
+				s.EXPECT().
+					Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: m})).
+					Return(m, nil)
+			},
+			wantCode: http.StatusOK,
+			wantJSON: `{"type":"gauge","id":"test","value":12.34,"timestamp":1700000000000}`,
+		},
+		{
+			name: "out of order",
+			json: `{"type":"gauge","id":"test","value":12.34,"timestamp":1}`,
+			mockSetup: func(s *mocks.MockUpdater) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil, model.ErrOutOfOrder)
+			},
+			wantCode:        http.StatusConflict,
+			containsStrings: []string{"sample is out of order"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -78,3 +102,31 @@ func TestNewUpdateJSONHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestNewUpdateJSONHandler_Protobuf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := mocks.NewMockUpdater(ctrl)
+	s.EXPECT().
+		Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: model.NewMetricCounter("test", 2)})).
+		Return(model.NewMetricCounter("test", 5), nil)
+	p, err := model.NewMetricCounter("test", 2).ToProto()
+	require.NoError(t, err)
+	body, err := proto.Marshal(p)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeProtobuf)
+	w := httptest.NewRecorder()
+	NewUpdateJSONHandler(s).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, ContentTypeProtobuf, w.Header().Get("Content-Type"))
+	got := &pb.Metric{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), got))
+	require.Equal(t, int64(5), got.GetDelta())
+
+	r = httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBufferString(`{"type":"counter","id":"test","delta":2}`))
+	r.Header.Set("Content-Type", ContentTypeProtobuf)
+	w = httptest.NewRecorder()
+	NewUpdateJSONHandler(s).ServeHTTP(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
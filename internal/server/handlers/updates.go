@@ -2,11 +2,22 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/pkg/spool"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// updatesChunkSize is the number of metrics stored with one UpdateBatch call
+	updatesChunkSize = 1000
+	// maxMemoryBody is the size of the request body kept in memory, the larger body is spooled to a temporary file
+	maxMemoryBody = 1 << 20
 )
 
 // BatchUpdater is an interface for batch updating metrics
@@ -17,36 +28,244 @@ type BatchUpdater interface {
 }
 
 // NewUpdatesHandler returns a handler for updating metrics
-func NewUpdatesHandler(s BatchUpdater) http.HandlerFunc {
+//
+// The metrics are encoded with JSON or with protobuf if the content type is application/x-protobuf,
+// the response is encoded the same way as the request.
+//
+// The batch is decoded element by element and is read twice: the first pass validates every metric,
+// so nothing is stored if any of them is not valid, the second pass stores the metrics in chunks
+// of updatesChunkSize. The stored metrics are encoded to the response chunk by chunk, so neither
+// the request nor the response of a large batch is held in memory.
+// The batch with more than maxCount metrics is rejected with 413, maxCount <= 0 means no limit,
+// the body larger than the limit of http.MaxBytesReader is rejected with 413 too.
+//
+// The batch of up to updatesChunkSize metrics is stored atomically as far as the storage is,
+// the larger batch is not: if the storage fails, the chunks stored before the failure are kept
+// and 422 is returned with the number of the stored metrics, the agent does not send the batch again,
+// so the stored deltas are not applied twice.
+func NewUpdatesHandler(s BatchUpdater, maxCount int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		mrs, err := model.UnmarshalMetricsRequestFromReader(r.Body)
+		newDecoder := model.NewMetricsRequestDecoder
+		if isProtobuf(r) {
+			newDecoder = model.NewMetricsRequestProtoDecoder
+		}
+		body, err := seekableBody(r.Body)
 		if err != nil {
-			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to unmarshal metrics request: %w", err))
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to read metrics request: %w", err))
+			if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
 		}
-		if err = model.ValidateMetricsRequest(mrs); err != nil {
+		defer func() {
+			_ = body.Close()
+		}()
+		err = validateBatch(newDecoder(body, maxCount))
+		if err == nil {
+			_, err = body.Seek(0, io.SeekStart)
+		}
+		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to validate metrics request: %w", err))
 			errMsg := http.StatusText(http.StatusBadRequest)
 			switch {
+			case errors.Is(err, model.ErrTooManyMetrics):
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge)+": "+model.ErrTooManyMetrics.Error(),
+					http.StatusRequestEntityTooLarge)
+				return
 			case errors.Is(err, model.ErrTypeIsNotValid):
 				errMsg += ": " + model.ErrTypeIsNotValid.Error()
 			case errors.Is(err, model.ErrValueIsNotValid):
 				errMsg += ": " + model.ErrValueIsNotValid.Error()
+			case errors.Is(err, model.ErrLabelsIsNotValid):
+				errMsg += ": " + model.ErrLabelsIsNotValid.Error()
+			case errors.Is(err, model.ErrIDIsNotValid):
+				errMsg += ": " + model.ErrIDIsNotValid.Error()
 			}
 			http.Error(w, errMsg, http.StatusBadRequest)
 			return
 		}
-		ms, err := s.UpdateBatch(r.Context(), mrs)
+		resp := newBatchResponse(isProtobuf(r))
+		defer func() {
+			_ = resp.close()
+		}()
+		stored, err := updateBatchChunks(r.Context(), s, newDecoder(body, maxCount), resp.add)
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to update metric batch: %w", err))
+			if stored > 0 {
+				http.Error(w, fmt.Sprintf("%s: %d metrics are stored before the failure",
+					http.StatusText(http.StatusUnprocessableEntity), stored), http.StatusUnprocessableEntity)
+				return
+			}
 			if errors.Is(err, model.ErrMetricNotFound) {
 				http.NotFound(w, r)
 				return
 			}
+			if errors.Is(err, model.ErrValueIsNotValid) {
+				http.Error(w, http.StatusText(http.StatusBadRequest)+": "+model.ErrValueIsNotValid.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, model.ErrOutOfOrder) {
+				http.Error(w, http.StatusText(http.StatusConflict)+": "+model.ErrOutOfOrder.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
-		responseMarshaled(ms, w, r)
+		resp.write(w, r)
+	}
+}
+
+// readSeekCloser is the request body which can be read more than once
+type readSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// seekableBody returns the body as is if it is seekable, e.g. spooled by the msign middleware,
+// otherwise the body is spooled to the buffer
+func seekableBody(body io.ReadCloser) (readSeekCloser, error) {
+	if rs, ok := body.(readSeekCloser); ok {
+		return rs, nil
+	}
+	sp := spool.New(maxMemoryBody)
+	if _, err := sp.ReadFrom(body); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to spool body: %w", err), sp.Close())
+	}
+	return sp, nil
+}
+
+// validateBatch decodes all metrics of the batch and returns the first error
+func validateBatch(dec *model.MetricsRequestDecoder) error {
+	for {
+		_, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			//nolint:wrapcheck // ignore
+			return err
+		}
+	}
+}
+
+// updateBatchChunks decodes the batch and stores it in chunks, the stored metrics of every chunk
+// are passed to stored, the number of the stored metrics is returned
+func updateBatchChunks(ctx context.Context, s BatchUpdater, dec *model.MetricsRequestDecoder,
+	stored func([]*model.Metric) error) (int, error) {
+	n := 0
+	chunk := make([]*model.MetricRequest, 0, updatesChunkSize)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		ms, err := s.UpdateBatch(ctx, chunk)
+		if err != nil {
+			//nolint:wrapcheck // ignore
+			return err
+		}
+		n += len(ms)
+		chunk = chunk[:0]
+		return stored(ms)
+	}
+	for {
+		mr, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			//nolint:wrapcheck // ignore
+			return n, err
+		}
+		chunk = append(chunk, mr)
+		if len(chunk) == updatesChunkSize {
+			if err = flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, flush()
+}
+
+// batchResponse is the response of the stored metrics, the metrics are encoded chunk by chunk
+// to the spool buffer, so the response of a large batch is not held in memory
+type batchResponse struct {
+	buf      *spool.Buffer
+	count    int
+	protobuf bool
+}
+
+// newBatchResponse returns the response encoded with protobuf or JSON
+func newBatchResponse(protobuf bool) *batchResponse {
+	return &batchResponse{buf: spool.New(maxMemoryBody), protobuf: protobuf}
+}
+
+// add encodes the metrics to the response
+//
+// The protobuf encodings of the chunks are concatenated, which is the encoding of the batch of all metrics.
+func (br *batchResponse) add(ms []*model.Metric) error {
+	if br.protobuf {
+		batch, err := model.MetricsToProto(ms)
+		if err != nil {
+			return fmt.Errorf("failed to convert metrics: %w", err)
+		}
+		data, err := proto.Marshal(batch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metrics: %w", err)
+		}
+		if _, err = br.buf.Write(data); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+		br.count += len(ms)
+		return nil
 	}
+	for _, m := range ms {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metric: %w", err)
+		}
+		sep := byte(',')
+		if br.count == 0 {
+			sep = '['
+		}
+		if _, err = br.buf.Write(append([]byte{sep}, data...)); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+		br.count++
+	}
+	return nil
+}
+
+// write writes the response
+func (br *batchResponse) write(w http.ResponseWriter, r *http.Request) {
+	contentType := ContentTypeProtobuf
+	var err error
+	if !br.protobuf {
+		contentType = "application/json"
+		end := "]"
+		if br.count == 0 {
+			end = "[]"
+		}
+		_, err = br.buf.Write([]byte(end))
+	}
+	if err == nil {
+		err = br.buf.Rewind()
+	}
+	if err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed response: %w", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, br.buf); err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed response: %w", err))
+	}
+}
+
+// close removes the temporary file of the response
+func (br *batchResponse) close() error {
+	//nolint:wrapcheck // ignore
+	return br.buf.Close()
 }
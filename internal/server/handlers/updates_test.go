@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/handlers/mocks"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestNewUpdatesHandler(t *testing.T) {
@@ -97,7 +102,7 @@ func TestNewUpdatesHandler(t *testing.T) {
 			tt.mockSetup(s)
 			r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(tt.json))
 			w := httptest.NewRecorder()
-			NewUpdatesHandler(s)(w, r)
+			NewUpdatesHandler(s, 0)(w, r)
 			require.Equal(t, tt.wantCode, w.Code)
 			body := w.Body.String()
 			if tt.wantJSON != "" {
@@ -109,3 +114,162 @@ func TestNewUpdatesHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestNewUpdatesHandler_Protobuf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := mocks.NewMockBatchUpdater(ctrl)
+	ms := []*model.Metric{model.NewMetricGauge("g", 1.5), model.NewMetricCounter("c", 2)}
+	s.EXPECT().
+		UpdateBatch(gomock.Any(), []*model.MetricRequest{{Metric: ms[0]}, {Metric: ms[1]}}).
+		Return(ms, nil)
+	batch, err := model.MetricsToProto(ms)
+	require.NoError(t, err)
+	body, err := proto.Marshal(batch)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeProtobuf+"; charset=binary")
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, 0).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, ContentTypeProtobuf, w.Header().Get("Content-Type"))
+	got := &pb.MetricBatch{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), got))
+	require.Len(t, got.GetMetrics(), 2)
+	require.InDelta(t, 1.5, got.GetMetrics()[0].GetValue(), 1e-9)
+	require.Equal(t, int64(2), got.GetMetrics()[1].GetDelta())
+}
+
+func TestNewUpdatesHandler_TooManyMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := mocks.NewMockBatchUpdater(ctrl)
+	s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+	r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(
+		`[{"type":"counter","id":"a","delta":1},{"type":"counter","id":"b","delta":1},{"type":"counter","id":"c","delta":1}]`))
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, 2)(w, r)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), model.ErrTooManyMetrics.Error())
+}
+
+func TestNewUpdatesHandler_Chunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	const n = updatesChunkSize*2 + 1
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := range n {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"type":"counter","id":"c%d","delta":1}`, i)
+	}
+	buf.WriteString("]")
+	s := mocks.NewMockBatchUpdater(ctrl)
+	var sizes []int
+	s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).Times(3).
+		DoAndReturn(func(_ context.Context, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+			sizes = append(sizes, len(mrs))
+			ms := make([]*model.Metric, len(mrs))
+			for i, mr := range mrs {
+				ms[i] = mr.Clone()
+			}
+			return ms, nil
+		})
+	r := httptest.NewRequest(http.MethodPost, "/updates/", &buf)
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, n)(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []int{updatesChunkSize, updatesChunkSize, 1}, sizes)
+	var got []*model.Metric
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Len(t, got, n)
+	require.Equal(t, "c2000", got[n-1].ID)
+}
+
+func TestNewUpdatesHandler_InvalidAfterFirstChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := range updatesChunkSize + 1 {
+		fmt.Fprintf(&buf, `{"type":"counter","id":"c%d","delta":1},`, i)
+	}
+	buf.WriteString(`{"type":"counter","id":"bad"}]`)
+	s := mocks.NewMockBatchUpdater(ctrl)
+	s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+	r := httptest.NewRequest(http.MethodPost, "/updates/", &buf)
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, 0)(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), model.ErrValueIsNotValid.Error())
+}
+
+func TestNewUpdatesHandler_BodyTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := mocks.NewMockBatchUpdater(ctrl)
+	s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+	body := `[{"type":"counter","id":"a","delta":1},{"type":"counter","id":"b","delta":1}]`
+	r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(len(body)-1))
+	NewUpdatesHandler(s, 0)(w, r)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestNewUpdatesHandler_FailedAfterFirstChunk(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := range updatesChunkSize + 1 {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"type":"counter","id":"c%d","delta":1}`, i)
+	}
+	buf.WriteString("]")
+	s := mocks.NewMockBatchUpdater(ctrl)
+	gomock.InOrder(
+		s.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(updatesChunkSize)).Return(make([]*model.Metric, updatesChunkSize), nil),
+		s.EXPECT().UpdateBatch(gomock.Any(), gomock.Len(1)).Return(nil, errors.New("storage error")),
+	)
+	r := httptest.NewRequest(http.MethodPost, "/updates/", &buf)
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, 0)(w, r)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, "the partially stored batch is not retried")
+	require.Contains(t, w.Body.String(), fmt.Sprintf("%d metrics are stored", updatesChunkSize))
+}
+
+func TestNewUpdatesHandler_ProtobufChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ms := make([]*model.Metric, updatesChunkSize+1)
+	for i := range ms {
+		ms[i] = model.NewMetricCounter(fmt.Sprintf("c%d", i), 1)
+	}
+	s := mocks.NewMockBatchUpdater(ctrl)
+	s.EXPECT().UpdateBatch(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(_ context.Context, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+			stored := make([]*model.Metric, len(mrs))
+			for i, mr := range mrs {
+				stored[i] = mr.Clone()
+			}
+			return stored, nil
+		})
+	batch, err := model.MetricsToProto(ms)
+	require.NoError(t, err)
+	body, err := proto.Marshal(batch)
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentTypeProtobuf)
+	w := httptest.NewRecorder()
+	NewUpdatesHandler(s, 0).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	got := &pb.MetricBatch{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), got), "the encodings of the chunks make up the batch")
+	require.Len(t, got.GetMetrics(), len(ms))
+	require.Equal(t, "c1000", got.GetMetrics()[updatesChunkSize].GetId())
+}
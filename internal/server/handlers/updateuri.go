@@ -9,17 +9,30 @@ import (
 )
 
 // NewUpdateURIHandler returns handler for updating metrics
+//
+// The value of the histogram is a single observation into the buckets of the bounds query parameter,
+// e.g. /update/histogram/{name}/{value}?bounds=0.1,0.5,1, the histogram without the bounds is rejected
 func NewUpdateURIHandler(s Updater) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		t := r.PathValue("type")
 		name := r.PathValue("name")
 		value := r.PathValue("value")
-		mr, err := model.NewMetricRequest(t, name, value)
+		var mr *model.MetricRequest
+		var err error
+		if t == model.TypeHistogram {
+			mr, err = model.NewHistogramRequest(name, value, r.URL.Query().Get("bounds"))
+		} else {
+			mr, err = model.NewMetricRequest(t, name, value)
+		}
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to create metric request: %w", err))
 			errMsg := http.StatusText(http.StatusBadRequest)
 			if errors.Is(err, model.ErrTypeIsNotValid) {
 				errMsg += ": " + model.ErrTypeIsNotValid.Error()
+			} else if errors.Is(err, model.ErrIDIsNotValid) {
+				errMsg += ": " + model.ErrIDIsNotValid.Error()
+			} else if errors.Is(err, model.ErrBoundsRequired) {
+				errMsg += ": " + model.ErrBoundsRequired.Error()
 			} else if errors.Is(err, model.ErrValueIsNotValid) {
 				errMsg += ": " + model.ErrValueIsNotValid.Error()
 			}
@@ -33,6 +46,10 @@ func NewUpdateURIHandler(s Updater) http.HandlerFunc {
 				http.NotFound(w, r)
 				return
 			}
+			if errors.Is(err, model.ErrValueIsNotValid) {
+				http.Error(w, http.StatusText(http.StatusBadRequest)+": "+model.ErrValueIsNotValid.Error(), http.StatusBadRequest)
+				return
+			}
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
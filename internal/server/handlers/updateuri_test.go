@@ -20,6 +20,7 @@ func TestNewUpdateURIHandler(t *testing.T) {
 		pathValues      map[string]string
 		mockSetup       func(*mocks.MockUpdater)
 		name            string
+		query           string
 		containsStrings []string
 		wantCode        int
 	}{
@@ -63,6 +64,19 @@ func TestNewUpdateURIHandler(t *testing.T) {
 			wantCode:        http.StatusBadRequest,
 			containsStrings: []string{"value is not valid"},
 		},
+		{
+			name: "invalid id",
+			pathValues: map[string]string{
+				"type":  model.TypeGauge,
+				"name":  "test{a}",
+				"value": "1",
+			},
+			mockSetup: func(s *mocks.MockUpdater) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"id is not valid"},
+		},
 		{
 			name: "not found",
 			pathValues: map[string]string{
@@ -91,6 +105,49 @@ func TestNewUpdateURIHandler(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 		},
+		{
+			name: "histogram",
+			pathValues: map[string]string{
+				"type":  model.TypeHistogram,
+				"name":  "test",
+				"value": "0.3",
+			},
+			query: "?bounds=0.1,0.5,1",
+			mockSetup: func(s *mocks.MockUpdater) {
+				h := &model.Histogram{Bounds: []float64{0.1, 0.5, 1}, Counts: []uint64{0, 1, 0, 0}, Sum: 0.3}
+				s.EXPECT().
+					Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: model.NewMetricHistogram("test", h)})).
+					Return(model.NewMetricHistogram("test", h), nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "histogram without bounds",
+			pathValues: map[string]string{
+				"type":  model.TypeHistogram,
+				"name":  "test",
+				"value": "0.3",
+			},
+			mockSetup: func(s *mocks.MockUpdater) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"histogram bounds are required"},
+		},
+		{
+			name: "histogram invalid bounds",
+			pathValues: map[string]string{
+				"type":  model.TypeHistogram,
+				"name":  "test",
+				"value": "0.3",
+			},
+			query: "?bounds=1,0.5",
+			mockSetup: func(s *mocks.MockUpdater) {
+				s.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"value is not valid"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,7 +158,7 @@ func TestNewUpdateURIHandler(t *testing.T) {
 			for _, v := range tt.pathValues {
 				target += "/" + v
 			}
-			r := httptest.NewRequest(http.MethodPost, target, http.NoBody)
+			r := httptest.NewRequest(http.MethodPost, target+tt.query, http.NoBody)
 			for i, v := range tt.pathValues {
 				r.SetPathValue(i, v)
 			}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/middleware/mlogger"
+	"google.golang.org/protobuf/proto"
 )
 
+// ContentTypeProtobuf is the content type of the protobuf encoded body
+const ContentTypeProtobuf = "application/x-protobuf"
+
 // RequestCtxWithLogMessage adds log message to request context
 func RequestCtxWithLogMessage(r *http.Request, msg string) {
 	*r = *r.WithContext(context.WithValue(r.Context(), mlogger.LogMessageKey, msg))
@@ -33,3 +39,57 @@ func responseMarshaled(data any, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// isProtobuf returns true if the request body is encoded with protobuf
+func isProtobuf(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == ContentTypeProtobuf
+}
+
+// responseMetric writes the metric to response, encoded with protobuf if the request is
+func responseMetric(m *model.Metric, w http.ResponseWriter, r *http.Request) {
+	if !isProtobuf(r) {
+		responseMarshaled(m, w, r)
+		return
+	}
+	p, err := m.ToProto()
+	if err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed response: %w", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	responseProtoMarshaled(p, w, r)
+}
+
+// responseProtoMarshaled marshals the protobuf message and writes it to response
+func responseProtoMarshaled(msg proto.Message, w http.ResponseWriter, r *http.Request) {
+	response, err := proto.Marshal(msg)
+	if err == nil {
+		w.Header().Set("Content-Type", ContentTypeProtobuf)
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write(response)
+	}
+	if err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed response: %w", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseMatchers returns the label matchers from the match query parameters,
+// e.g. ?match=host=h1&match=dc=~"eu-.*"
+func parseMatchers(r *http.Request) ([]*model.LabelMatcher, error) {
+	values := r.URL.Query()["match"]
+	if len(values) == 0 {
+		return nil, nil
+	}
+	matchers := make([]*model.LabelMatcher, len(values))
+	for i, v := range values {
+		lm, err := model.ParseLabelMatcher(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse matcher: %w", err)
+		}
+		matchers[i] = lm
+	}
+	return matchers, nil
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
 )
@@ -14,15 +17,24 @@ import (
 //go:generate mockgen -source=valueuri.go -destination=mocks/mock_finder.go -package=mocks
 type Finder interface {
 	Find(context.Context, *model.MetricRequest) (*model.Metric, error)
+	FindAll(context.Context, ...*model.LabelMatcher) ([]*model.Metric, error)
 }
 
 // NewValueURIHandler returns a handler for the value URI.
+//
+// For summary metrics the q query parameter can be used to get the estimate of the quantile,
+// e.g. /value/summary/{name}?q=0.99, the summaries are merged on update, so the quantile is over
+// all the values reported since the metric was created.
+// For set metrics the estimate of the number of distinct members is returned.
+//
+// Without the match query parameters the metric without labels is returned. With them all the metrics
+// of the name matching the labels are returned, one per line with the labels before the value,
+// e.g. /value/gauge/{name}?match=host=~"h.*".
 func NewValueURIHandler(s Finder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		t := r.PathValue("type")
 		name := r.PathValue("name")
-		value := "0"
-		mr, err := model.NewMetricRequest(t, name, value)
+		mr, err := model.NewMetricRequest(t, name, "0")
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to create metric request: %w", err))
 			errMsg := http.StatusText(http.StatusBadRequest)
@@ -32,6 +44,22 @@ func NewValueURIHandler(s Finder) http.HandlerFunc {
 			http.Error(w, errMsg, http.StatusBadRequest)
 			return
 		}
+		q, err := parseQuantile(r, t)
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to parse quantile: %w", err))
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		matchers, err := parseMatchers(r)
+		if err != nil {
+			RequestCtxWithLogMessageFromError(r, err)
+			http.Error(w, http.StatusText(http.StatusBadRequest)+": "+model.ErrLabelsIsNotValid.Error(), http.StatusBadRequest)
+			return
+		}
+		if matchers != nil {
+			writeMatchedValues(w, r, s, mr, q, matchers)
+			return
+		}
 		m, err := s.Find(r.Context(), mr)
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find metric: %w", err))
@@ -42,7 +70,7 @@ func NewValueURIHandler(s Finder) http.HandlerFunc {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
-		_, err = fmt.Fprint(w, m.AnyValue())
+		_, err = fmt.Fprint(w, metricValue(m, q))
 		if err != nil {
 			RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to write value: %w", err))
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -50,3 +78,55 @@ func NewValueURIHandler(s Finder) http.HandlerFunc {
 		}
 	}
 }
+
+// writeMatchedValues writes the values of the metrics with the name and type of the request
+// matching the label matchers.
+func writeMatchedValues(w http.ResponseWriter, r *http.Request, s Finder, mr *model.MetricRequest,
+	q *float64, matchers []*model.LabelMatcher) {
+	ms, err := s.FindAll(r.Context(), matchers...)
+	if err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find metrics: %w", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	ms = slices.DeleteFunc(ms, func(m *model.Metric) bool {
+		return m.MType != mr.MType || m.ID != mr.ID
+	})
+	if len(ms) == 0 {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to find metrics: %w", model.ErrMetricNotFound))
+		http.NotFound(w, r)
+		return
+	}
+	var buf bytes.Buffer
+	for _, m := range ms {
+		_, _ = fmt.Fprintln(&buf, m.LabelsKey(), metricValue(m, q))
+	}
+	if _, err = w.Write(buf.Bytes()); err != nil {
+		RequestCtxWithLogMessageFromError(r, fmt.Errorf("failed to write values: %w", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// metricValue returns the value of the metric or the quantile estimate if q is set.
+func metricValue(m *model.Metric, q *float64) any {
+	if q != nil && m.Summary != nil {
+		return m.Summary.Quantile(*q)
+	}
+	return m.AnyValue()
+}
+
+// parseQuantile returns the quantile from the q query parameter or nil if it is not set.
+func parseQuantile(r *http.Request, t string) (*float64, error) {
+	if !r.URL.Query().Has("q") {
+		return nil, nil
+	}
+	if t != model.TypeSummary {
+		return nil, errors.New("quantile is supported for summary only")
+	}
+	q, err := strconv.ParseFloat(r.URL.Query().Get("q"), 64)
+	if err != nil || q < 0 || q > 1 {
+		return nil, errors.New("quantile must be a number from 0 to 1")
+	}
+	return &q, nil
+}
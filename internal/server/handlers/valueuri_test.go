@@ -20,6 +20,7 @@ func TestNewValueURIHandler(t *testing.T) {
 		pathValues      map[string]string
 		mockSetup       func(*mocks.MockFinder)
 		name            string
+		query           string
 		wantBody        string
 		containsStrings []string
 		wantCode        int
@@ -76,6 +77,50 @@ func TestNewValueURIHandler(t *testing.T) {
 			},
 			wantCode: http.StatusInternalServerError,
 		},
+		{
+			name: "matchers",
+			pathValues: map[string]string{
+				"type": model.TypeGauge,
+				"name": "cpu",
+			},
+			query: `?match=host=~"h.*"`,
+			mockSetup: func(s *mocks.MockFinder) {
+				h1 := model.NewMetricGauge("cpu", 1)
+				h1.Labels = model.Labels{"host": "h1"}
+				h2 := model.NewMetricGauge("cpu", 2)
+				h2.Labels = model.Labels{"host": "h2"}
+				other := model.NewMetricGauge("mem", 3)
+				other.Labels = model.Labels{"host": "h1"}
+				s.EXPECT().FindAll(gomock.Any(), gomock.Any()).Return([]*model.Metric{h1, h2, other}, nil)
+			},
+			wantBody: "{host=\"h1\"} 1\n{host=\"h2\"} 2\n",
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "matchers not found",
+			pathValues: map[string]string{
+				"type": model.TypeGauge,
+				"name": "cpu",
+			},
+			query: "?match=host=h3",
+			mockSetup: func(s *mocks.MockFinder) {
+				s.EXPECT().FindAll(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name: "invalid matcher",
+			pathValues: map[string]string{
+				"type": model.TypeGauge,
+				"name": "cpu",
+			},
+			query: "?match=host",
+			mockSetup: func(s *mocks.MockFinder) {
+				s.EXPECT().FindAll(gomock.Any(), gomock.Any()).MaxTimes(0)
+			},
+			wantCode:        http.StatusBadRequest,
+			containsStrings: []string{"labels is not valid"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -86,7 +131,7 @@ func TestNewValueURIHandler(t *testing.T) {
 			for _, v := range tt.pathValues {
 				target += "/" + v
 			}
-			r := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+			r := httptest.NewRequest(http.MethodGet, target+tt.query, http.NoBody)
 			for i, v := range tt.pathValues {
 				r.SetPathValue(i, v)
 			}
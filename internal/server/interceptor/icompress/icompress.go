@@ -0,0 +1,45 @@
+// Package icompress provides the gRPC interceptors for compressing responses.
+//
+// The requests compressed with gzip are decompressed by the gzip compressor registered by the package.
+package icompress
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// UnaryGzipCompressed returns an interceptor that compresses the response with gzip if the client supports it.
+func UnaryGzipCompressed(l *logging.ZapLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		setGzipCompressor(ctx, l)
+		return handler(ctx, req)
+	}
+}
+
+// StreamGzipCompressed returns an interceptor like UnaryGzipCompressed for the streams.
+func StreamGzipCompressed(l *logging.ZapLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		setGzipCompressor(ss.Context(), l)
+		return handler(srv, ss)
+	}
+}
+
+// setGzipCompressor sets gzip as the compressor of the response if the client supports it.
+func setGzipCompressor(ctx context.Context, l *logging.ZapLogger) {
+	supported, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		l.ErrorCtx(ctx, fmt.Errorf("failed to get client compressors: %w", err).Error())
+		return
+	}
+	if !slices.Contains(supported, gzip.Name) {
+		return
+	}
+	if err = grpc.SetSendCompressor(ctx, gzip.Name); err != nil {
+		l.ErrorCtx(ctx, fmt.Errorf("failed to set compressor: %w", err).Error())
+	}
+}
@@ -0,0 +1,90 @@
+// Package ilogger provides the gRPC interceptors for logging calls.
+package ilogger
+
+import (
+	"context"
+	"time"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/server/interceptor/isign"
+	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// UnaryRequestLogger returns an interceptor for logging the call: the status code, the method,
+// the duration and the size of the response, the message is the error of the call.
+func UnaryRequestLogger(l *logging.ZapLogger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		size := 0
+		if msg, ok := resp.(proto.Message); ok && err == nil {
+			size = proto.Size(msg)
+		}
+		logCall(ctx, l, info.FullMethod, err, append(fields(ctx, start), zap.Int("size", size))...)
+		//nolint:wrapcheck // the status of the error is returned as is
+		return resp, err
+	}
+}
+
+// StreamRequestLogger returns an interceptor like UnaryRequestLogger for the streams,
+// the number of the received messages is logged as well.
+func StreamRequestLogger(l *logging.ZapLogger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ls := &loggingStream{ServerStream: ss}
+		err := handler(srv, ls)
+		ctx := ss.Context()
+		logCall(ctx, l, info.FullMethod, err,
+			append(fields(ctx, start), zap.Int("size", ls.size), zap.Int("received", ls.received))...)
+		//nolint:wrapcheck // the status of the error is returned as is
+		return err
+	}
+}
+
+// loggingStream is a grpc.ServerStream counting the received messages and the size of the sent ones.
+type loggingStream struct {
+	grpc.ServerStream
+	received int
+	size     int
+}
+
+// RecvMsg receives the message and counts it.
+func (s *loggingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+	}
+	//nolint:wrapcheck // the status of the stream error is returned as is
+	return err
+}
+
+// SendMsg sends the message and adds its size.
+func (s *loggingStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		s.size += proto.Size(msg)
+	}
+	//nolint:wrapcheck // the status of the stream error is returned as is
+	return err
+}
+
+// fields returns the duration of the call started at start and the signature of the request if it is set.
+func fields(ctx context.Context, start time.Time) []zap.Field {
+	fs := []zap.Field{zap.Duration("duration", time.Since(start))}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if rs := md.Get(isign.MetadataKey); len(rs) > 0 && rs[0] != "" {
+			fs = append(fs, zap.String("sign", rs[0]))
+		}
+	}
+	return fs
+}
+
+// logCall logs the call of the method which returned err with the fields.
+func logCall(ctx context.Context, l *logging.ZapLogger, method string, err error, fs ...zap.Field) {
+	st := status.Convert(err)
+	l.InfoCtx(ctx, st.Message(), append([]zap.Field{zap.String("code", st.Code().String()), zap.String("method", method)}, fs...)...)
+}
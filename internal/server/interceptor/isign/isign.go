@@ -0,0 +1,142 @@
+// Package isign provides the gRPC interceptors for validating the signature of requests and signing responses.
+//
+// The message is signed with the HMAC-SHA256 of its deterministic protobuf encoding, the signature is passed
+// in the metadata with MetadataKey. The messages of a stream can not be signed with the metadata,
+// so the request message of a stream carries the signature of its payload itself.
+package isign
+
+import (
+	"context"
+	"fmt"
+
+	pb "github.com/korobkovandrey/runtime-metrics/internal/proto"
+	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataKey is the metadata key of the signature.
+const MetadataKey = "hashsha256"
+
+// UnaryKeySigner returns an interceptor that validates the signature of the request and signs the response
+// with the key returned by the function for every call, so the key can be changed without restarting the server.
+// The calls are not signed if the key is empty.
+func UnaryKeySigner(getKey func() []byte) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key := getKey()
+		if len(key) == 0 {
+			return handler(ctx, req)
+		}
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Error(codes.Internal, "the request is not a protobuf message")
+		}
+		var hash string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) > 0 {
+				hash = values[0]
+			}
+		}
+		if err := validate(msg, key, hash); err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if err = signHeader(ctx, resp, key, grpc.SetHeader); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// StreamKeySigner returns an interceptor like UnaryKeySigner for the streams: the signature of every received
+// message is validated and the sent messages are signed in the header.
+func StreamKeySigner(getKey func() []byte) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := getKey()
+		if len(key) == 0 {
+			return handler(srv, ss)
+		}
+		return handler(srv, &signedStream{ServerStream: ss, key: key})
+	}
+}
+
+// signedStream is a grpc.ServerStream that validates the received messages and signs the sent ones.
+type signedStream struct {
+	grpc.ServerStream
+	key []byte
+}
+
+// RecvMsg receives the message and validates its signature.
+func (s *signedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		//nolint:wrapcheck // the status of the stream error is returned as is
+		return err
+	}
+	req, ok := m.(*pb.StreamUpdatesRequest)
+	if !ok {
+		return status.Errorf(codes.Internal, "the stream message %T can not be signed", m)
+	}
+	return validate(req.GetBatch(), s.key, req.GetHash())
+}
+
+// SendMsg signs the message in the header and sends it.
+func (s *signedStream) SendMsg(m any) error {
+	if err := signHeader(s.Context(), m, s.key, func(_ context.Context, md metadata.MD) error {
+		return s.SetHeader(md)
+	}); err != nil {
+		return err
+	}
+	//nolint:wrapcheck // the status of the stream error is returned as is
+	return s.ServerStream.SendMsg(m)
+}
+
+// validate returns the error with codes.Unauthenticated if the hash is not the signature of the message,
+// the message without the hash is not validated like the request of the HTTP server.
+func validate(msg proto.Message, key []byte, hash string) error {
+	bh, err := sign.DecodeString(hash)
+	if err == nil {
+		var data []byte
+		if data, err = marshal(msg); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if sign.Validate(data, key, bh) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid signature")
+}
+
+// signHeader sets the signature of the response to the header with setHeader, the empty response is not signed.
+func signHeader(ctx context.Context, resp any, key []byte, setHeader func(context.Context, metadata.MD) error) error {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "the response is not a protobuf message")
+	}
+	data, err := marshal(msg)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	hash := sign.MakeToString(data, key)
+	if hash == "" {
+		return nil
+	}
+	if err = setHeader(ctx, metadata.Pairs(MetadataKey, hash)); err != nil {
+		return status.Error(codes.Internal, fmt.Errorf("failed to set signature header: %w", err).Error())
+	}
+	return nil
+}
+
+// marshal returns the deterministic encoding of the message which is signed.
+func marshal(msg proto.Message) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return data, nil
+}
@@ -2,12 +2,12 @@
 package msign
 
 import (
-	"bytes"
 	"errors"
 	"io"
 	"net/http"
 
 	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"github.com/korobkovandrey/runtime-metrics/pkg/spool"
 )
 
 // errorReadCloser is an io.ReadCloser that returns an error.
@@ -31,10 +31,13 @@ func (r *errorReadCloser) Read(p []byte) (int, error) {
 }
 
 // signWriter is a http.ResponseWriter that signs the response.
+//
+// The response is spooled while it is hashed, since the hash is sent in the header before the body,
+// so a large response is not held in memory.
 type signWriter struct {
 	http.ResponseWriter
-	buf        *bytes.Buffer
-	key        []byte
+	buf        *spool.Buffer
+	hasher     *sign.Hasher
 	statusCode int
 }
 
@@ -42,15 +45,19 @@ type signWriter struct {
 func newSignWriter(w http.ResponseWriter, key []byte) *signWriter {
 	return &signWriter{
 		ResponseWriter: w,
-		buf:            &bytes.Buffer{},
-		key:            key,
+		buf:            spool.New(maxMemoryBody),
+		hasher:         sign.NewHasher(key),
 	}
 }
 
 // Write writes the data to the buffer.
 func (w *signWriter) Write(data []byte) (n int, err error) {
+	if n, err = w.buf.Write(data); err != nil {
+		//nolint:wrapcheck // ignore
+		return n, err
+	}
 	//nolint:wrapcheck // ignore
-	return w.buf.Write(data)
+	return w.hasher.Write(data[:n])
 }
 
 // WriteHeader sets the status code.
@@ -60,34 +67,54 @@ func (w *signWriter) WriteHeader(statusCode int) {
 
 // close signs the response and writes it to the response writer.
 func (w *signWriter) close() {
-	data := w.buf.Bytes()
-	if hash := sign.MakeToString(data, w.key); hash != "" {
+	defer func() {
+		_ = w.buf.Close()
+	}()
+	if err := w.buf.Rewind(); err != nil {
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if hash := sign.EncodeToString(w.hasher.Sum()); hash != "" {
 		w.ResponseWriter.Header().Set("HashSHA256", hash)
 	}
 	w.ResponseWriter.WriteHeader(w.statusCode)
-	_, _ = w.ResponseWriter.Write(data)
+	_, _ = io.Copy(w.ResponseWriter, w.buf)
 }
 
+// maxMemoryBody is the size of the request body kept in memory, the larger body is spooled to a temporary file.
+const maxMemoryBody = 1 << 20
+
 // Signer returns a middleware that signs the request and response.
+//
+// The request body is validated before it is passed to the handler, so it is read in advance:
+// a large body is spooled to a temporary file instead of being held in memory. The spooled body
+// implements io.Seeker, the handler can read it more than once.
 func Signer(key []byte) func(h http.Handler) http.Handler {
+	return KeySigner(func() []byte { return key })
+}
+
+// KeySigner returns a middleware like Signer with the key returned by the function for every request,
+// so the key can be changed without rebuilding the router.
+func KeySigner(getKey func() []byte) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := getKey()
 			if len(key) == 0 {
 				h.ServeHTTP(w, r)
 				return
 			}
-			var body []byte
+			var body *spool.Buffer
 			bh, err := sign.DecodeString(r.Header.Get("HashSHA256"))
 			if err == nil {
-				body, err = io.ReadAll(r.Body)
-				if err == nil && !sign.Validate(body, key, bh) {
-					err = errors.New("invalid signature")
-				}
+				body, err = spoolBody(r.Body, key, bh)
 			}
 			if err != nil {
 				r.Body = newErrorReadCloser(r.Body, err)
 			} else {
-				r.Body = io.NopCloser(bytes.NewBuffer(body))
+				defer func() {
+					_ = body.Close()
+				}()
+				r.Body = body
 			}
 			sw := newSignWriter(w, key)
 			defer sw.close()
@@ -95,3 +122,20 @@ func Signer(key []byte) func(h http.Handler) http.Handler {
 		})
 	}
 }
+
+// spoolBody reads the body to the spool buffer and validates its hash.
+func spoolBody(body io.Reader, key, hash []byte) (*spool.Buffer, error) {
+	sp := spool.New(maxMemoryBody)
+	v := sign.NewValidator(key, hash)
+	_, err := io.Copy(io.MultiWriter(sp, v), body)
+	if err == nil && !v.Valid() {
+		err = errors.New("invalid signature")
+	}
+	if err == nil {
+		err = sp.Rewind()
+	}
+	if err != nil {
+		return nil, errors.Join(err, sp.Close())
+	}
+	return sp, nil
+}
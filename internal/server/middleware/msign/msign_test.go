@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/korobkovandrey/runtime-metrics/pkg/sign"
+	"github.com/korobkovandrey/runtime-metrics/pkg/spool"
 	"github.com/stretchr/testify/require"
 )
 
@@ -39,6 +40,18 @@ func TestSigner(t *testing.T) {
 			},
 			wantSignHeader: true,
 		},
+		{
+			name: "valid binary",
+			args: args{
+				text:     "\n\x04test\x12\x05gauge!\x00\x00\x00\x00\x00\x00\xf0?",
+				response: "\n\x04test\x00\xff",
+				key:      "sing key",
+			},
+			fields: fields{
+				key: "sing key",
+			},
+			wantSignHeader: true,
+		},
 		{
 			name: "fail",
 			args: args{
@@ -78,3 +91,66 @@ func TestSigner(t *testing.T) {
 		})
 	}
 }
+
+func TestSigner_LargeBody(t *testing.T) {
+	key := []byte("sign key")
+	text := bytes.Repeat([]byte("0123456789"), maxMemoryBody/10+1)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(text))
+	r.Header.Set("HashSHA256", sign.MakeToString(text, key))
+	w := httptest.NewRecorder()
+	Signer(key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sp, ok := r.Body.(*spool.Buffer)
+		require.True(t, ok)
+		require.False(t, sp.InMemory())
+		for range 2 {
+			got, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, text, got)
+			_, err = sp.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+		}
+		w.WriteHeader(http.StatusOK)
+	})).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestKeySigner(t *testing.T) {
+	var key []byte
+	text := []byte("send hello")
+	handler := KeySigner(func() []byte { return key })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(hash string) int {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(text))
+		r.Header.Set("HashSHA256", hash)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	require.Equal(t, http.StatusOK, serve("bad hash"), "no key, the hash is not checked")
+	key = []byte("new key")
+	require.Equal(t, http.StatusBadRequest, serve("bad hash"), "the changed key is used")
+	require.Equal(t, http.StatusOK, serve(sign.MakeToString(text, key)))
+}
+
+func TestSigner_LargeResponse(t *testing.T) {
+	key := []byte("sign key")
+	response := bytes.Repeat([]byte("0123456789"), maxMemoryBody/10+1)
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	w := httptest.NewRecorder()
+	Signer(key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < len(response); i += 4096 {
+			_, err := w.Write(response[i:min(i+4096, len(response))])
+			require.NoError(t, err)
+		}
+	})).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, response, w.Body.Bytes())
+	require.Equal(t, sign.MakeToString(response, key), w.Header().Get("HashSHA256"))
+}
@@ -4,6 +4,8 @@
 // persisting metrics to a file. It supports creating, updating, and
 // restoring metrics from a file. The storage can be synchronized
 // periodically or on-demand.
+//
+// The metrics metadata is stored next to the metrics file, e.g. storage.meta.json for storage.json.
 package repository
 
 import (
@@ -13,6 +15,8 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
@@ -23,9 +27,11 @@ import (
 // FileStorage is a file storage for metrics.
 type FileStorage struct {
 	*MemStorage
-	cfg       *config.Config
-	isSync    bool
-	isChanged bool
+	cfg           *config.Config
+	intervals     chan int64
+	isSync        bool
+	isChanged     bool
+	isMetaChanged bool
 }
 
 // NewFileStorage creates a new file storage.
@@ -33,6 +39,7 @@ func NewFileStorage(ms *MemStorage, cfg *config.Config) *FileStorage {
 	return &FileStorage{
 		MemStorage: ms,
 		cfg:        cfg,
+		intervals:  make(chan int64, 1),
 		isSync:     cfg.StoreInterval <= 0,
 	}
 }
@@ -91,6 +98,20 @@ func (f *FileStorage) CreateOrUpdateBatch(ctx context.Context, mrs []*model.Metr
 	return res, nil
 }
 
+// SaveMeta creates or replaces the metadata.
+func (f *FileStorage) SaveMeta(ctx context.Context, metas []*model.Meta) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.isMetaChanged = true
+	f.unsafeSaveMeta(metas)
+	if f.isSync {
+		if err := f.sync(false, true); err != nil {
+			return fmt.Errorf("failed to sync: %w", err)
+		}
+	}
+	return nil
+}
+
 // Close closes the file storage.
 func (f *FileStorage) Close() error {
 	return f.sync(true, false)
@@ -101,37 +122,60 @@ func (f *FileStorage) Restore() error {
 	if f.cfg.FileStoragePath == "" {
 		return nil
 	}
-	stat, err := os.Stat(f.cfg.FileStoragePath)
+	var mrs []*model.Metric
+	if err := f.readFile(f.cfg.FileStoragePath, &mrs); err != nil {
+		return err
+	}
+	if mrs != nil {
+		f.fill(mrs)
+	}
+	var metas []*model.Meta
+	if err := f.readFile(f.metaFilePath(), &metas); err != nil {
+		return fmt.Errorf("failed to restore metadata: %w", err)
+	}
+	if metas != nil {
+		f.fillMeta(metas)
+	}
+	return nil
+}
+
+// readFile unmarshals the file content to v, a missing or empty file is skipped.
+func (f *FileStorage) readFile(path string, v any) error {
+	stat, err := os.Stat(path)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			return fmt.Errorf("failed to stat file: %w", err)
 		}
 		return nil
 	}
-	if stat.Size() > 0 {
-		var data []byte
-		for i := 0; ; i++ {
-			data, err = os.ReadFile(f.cfg.FileStoragePath)
-			if i == len(f.cfg.RetryDelays) || err == nil || !errors.Is(err, fs.ErrPermission) {
-				break
-			}
-			time.Sleep(f.cfg.RetryDelays[i])
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read file: %w", err)
+	if stat.Size() == 0 {
+		return nil
+	}
+	var data []byte
+	for i := 0; ; i++ {
+		data, err = os.ReadFile(path)
+		if i == len(f.cfg.RetryDelays) || err == nil || !errors.Is(err, fs.ErrPermission) {
+			break
 		}
-		if len(data) > 0 {
-			var mrs []*model.Metric
-			err = json.Unmarshal(data, &mrs)
-			if err != nil {
-				return fmt.Errorf("failed to unmarshal file: %w", err)
-			}
-			f.fill(mrs)
+		time.Sleep(f.cfg.RetryDelays[i])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to unmarshal file: %w", err)
 		}
 	}
 	return nil
 }
 
+// metaFilePath returns the path of the metadata file.
+func (f *FileStorage) metaFilePath() string {
+	ext := filepath.Ext(f.cfg.FileStoragePath)
+	return strings.TrimSuffix(f.cfg.FileStoragePath, ext) + ".meta" + ext
+}
+
 // sync syncs the file storage.
 func (f *FileStorage) sync(safe, tryRetry bool) error {
 	if safe {
@@ -141,10 +185,24 @@ func (f *FileStorage) sync(safe, tryRetry bool) error {
 	if f.cfg.FileStoragePath == "" {
 		return nil
 	}
-	if !f.isChanged {
-		return nil
+	if f.isChanged {
+		if err := f.writeFile(f.cfg.FileStoragePath, f.unsafeFindAll(), tryRetry); err != nil {
+			return err
+		}
+		f.isChanged = false
 	}
-	data, err := json.MarshalIndent(f.unsafeFindAll(), "", "   ")
+	if f.isMetaChanged {
+		if err := f.writeFile(f.metaFilePath(), f.unsafeFindAllMeta(), tryRetry); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+		f.isMetaChanged = false
+	}
+	return nil
+}
+
+// writeFile writes v marshaled to JSON to the file.
+func (f *FileStorage) writeFile(path string, v any, tryRetry bool) error {
+	data, err := json.MarshalIndent(v, "", "   ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
@@ -154,39 +212,64 @@ func (f *FileStorage) sync(safe, tryRetry bool) error {
 
 	if tryRetry {
 		for i := 0; ; i++ {
-			err = os.WriteFile(f.cfg.FileStoragePath, data, permFlag)
+			err = os.WriteFile(path, data, permFlag)
 			if i == len(f.cfg.RetryDelays) || err == nil || !errors.Is(err, fs.ErrPermission) {
 				break
 			}
 			time.Sleep(f.cfg.RetryDelays[i])
 		}
 	} else {
-		err = os.WriteFile(f.cfg.FileStoragePath, data, permFlag)
+		err = os.WriteFile(path, data, permFlag)
 	}
 
 	if err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
-	f.isChanged = false
 	return nil
 }
 
-// Run runs the file storage.
+// SetStoreInterval changes the store interval in seconds, the storage is synced on every change if it is not positive.
+//
+// The changes made before the switch to the sync mode are stored by Run.
+func (f *FileStorage) SetStoreInterval(seconds int64) {
+	f.mux.Lock()
+	f.isSync = seconds <= 0
+	f.mux.Unlock()
+	select {
+	case <-f.intervals:
+	default:
+	}
+	f.intervals <- seconds
+}
+
+// Run runs the file storage: it stores the changes every store interval until the context is done.
 func (f *FileStorage) Run(ctx context.Context, l *logging.ZapLogger) {
-	if f.cfg.StoreInterval <= 0 {
-		return
+	t := time.NewTicker(time.Hour)
+	defer t.Stop()
+	var tick <-chan time.Time
+	setInterval := func(seconds int64) {
+		if seconds <= 0 {
+			t.Stop()
+			tick = nil
+			return
+		}
+		t.Reset(time.Duration(seconds) * time.Second)
+		tick = t.C
 	}
-	var err error
-	t := time.NewTicker(time.Duration(f.cfg.StoreInterval) * time.Second)
+	setInterval(f.cfg.StoreInterval)
 	for {
 		select {
 		case <-ctx.Done():
-			t.Stop()
 			return
-		case <-t.C:
-			if err = f.sync(true, false); err != nil {
-				l.ErrorCtx(ctx, fmt.Errorf("failed to sync: %w", err).Error())
+		case seconds := <-f.intervals:
+			setInterval(seconds)
+			if tick != nil {
+				continue
 			}
+		case <-tick:
+		}
+		if err := f.sync(true, false); err != nil {
+			l.ErrorCtx(ctx, fmt.Errorf("failed to sync: %w", err).Error())
 		}
 	}
 }
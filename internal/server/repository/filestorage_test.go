@@ -529,3 +529,61 @@ func TestFileStorage_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestFileStorage_SetStoreInterval(t *testing.T) {
+	gauge, err := model.NewMetricRequest(model.TypeGauge, "test", "23")
+	require.NoError(t, err)
+	cfg := &config.Config{
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+		StoreInterval:   3600,
+		RetryDelays:     []time.Duration{0},
+	}
+	fs := NewFileStorage(newMemStorageWithDataAndIndex(
+		[]*model.Metric{gauge.Clone()}, map[string]map[string]int{model.TypeGauge: {"test": 0}}), cfg)
+	fs.isChanged = true
+	logger, err := logging.NewZapLogger(zapcore.DebugLevel)
+	require.NoError(t, err)
+	go fs.Run(t.Context(), logger)
+
+	fs.SetStoreInterval(0)
+	require.Eventually(t, func() bool { return !fs.isChangedF() }, time.Second, 10*time.Millisecond,
+		"the changes are stored on the switch to the sync mode")
+	_, err = os.Stat(cfg.FileStoragePath)
+	require.NoError(t, err)
+
+	_, err = fs.Update(t.Context(), gauge)
+	require.NoError(t, err)
+	assert.False(t, fs.isChangedF(), "the update is stored at once in the sync mode")
+
+	fs.SetStoreInterval(1)
+	_, err = fs.Update(t.Context(), gauge)
+	require.NoError(t, err)
+	assert.True(t, fs.isChangedF())
+	require.Eventually(t, func() bool { return !fs.isChangedF() }, 3*time.Second, 10*time.Millisecond,
+		"the update is stored by the ticker")
+}
+
+func TestFileStorage_Meta(t *testing.T) {
+	cfg := &config.Config{
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+		RetryDelays:     []time.Duration{0},
+	}
+	metas := []*model.Meta{
+		model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Allocated heap"),
+		model.NewMeta(model.TypeCounter, "PollCount", "", "Number of polls"),
+	}
+	fs := NewFileStorage(NewMemStorage(), cfg)
+	require.NoError(t, fs.SaveMeta(t.Context(), metas))
+	metaPath := filepath.Join(filepath.Dir(cfg.FileStoragePath), "metrics.meta.json")
+	assert.FileExists(t, metaPath)
+	assert.NoFileExists(t, cfg.FileStoragePath)
+
+	restored := NewFileStorage(NewMemStorage(), cfg)
+	require.NoError(t, restored.Restore())
+	got, err := restored.FindAllMeta(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Meta{metas[1], metas[0]}, got)
+
+	require.NoError(t, os.WriteFile(metaPath, []byte("invalid json"), 0o600))
+	assert.Error(t, NewFileStorage(NewMemStorage(), cfg).Restore())
+}
@@ -5,8 +5,9 @@
 // - Create: adds a new metric to the storage.
 // - Update: updates an existing metric in the storage.
 // - CreateOrUpdateBatch: adds or updates multiple metrics in the storage.
-// - Find: finds a metric in the storage by its ID and name.
+// - Find: finds a metric in the storage by its type, name and labels.
 // - FindAll: finds all metrics in the storage.
+// - FindMeta, FindAllMeta, SaveMeta: work with the metrics metadata.
 //
 // The storage is thread-safe and provides a simple locking mechanism.
 package repository
@@ -14,6 +15,8 @@ package repository
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
@@ -23,6 +26,7 @@ import (
 type MemStorage struct {
 	mux   *sync.Mutex
 	index map[string]map[string]int
+	meta  map[string]*model.Meta
 	data  []*model.Metric
 }
 
@@ -31,13 +35,14 @@ func NewMemStorage() *MemStorage {
 	return &MemStorage{
 		mux:   &sync.Mutex{},
 		index: map[string]map[string]int{},
+		meta:  map[string]*model.Meta{},
 		data:  []*model.Metric{},
 	}
 }
 
 // unsafeIndex returns the index of the metric in the data slice.
 func (ms *MemStorage) unsafeIndex(mr *model.MetricRequest) (int, bool) {
-	i, ok := ms.index[mr.MType][mr.ID]
+	i, ok := ms.index[mr.MType][mr.Key()]
 	return i, ok && i < len(ms.data)
 }
 
@@ -88,9 +93,9 @@ func (ms *MemStorage) unsafeCreate(mr *model.MetricRequest) (*model.Metric, erro
 	if _, ok := ms.index[mr.MType]; !ok {
 		ms.index[mr.MType] = map[string]int{}
 	}
-	ms.index[mr.MType][mr.ID] = len(ms.data)
+	ms.index[mr.MType][mr.Key()] = len(ms.data)
 	ms.data = append(ms.data, mr.Clone())
-	return ms.data[ms.index[mr.MType][mr.ID]].Clone(), nil
+	return ms.data[ms.index[mr.MType][mr.Key()]].Clone(), nil
 }
 
 // Create creates a new metric.
@@ -118,6 +123,18 @@ func (ms *MemStorage) unsafeUpdate(mr *model.MetricRequest) (*model.Metric, erro
 		}
 		*ms.data[i].Delta = *mr.Delta
 	}
+	if mr.Histogram != nil {
+		ms.data[i].Histogram = mr.Histogram.Clone()
+	}
+	if mr.Summary != nil {
+		ms.data[i].Summary = mr.Summary.Clone()
+	}
+	if mr.Set != nil {
+		ms.data[i].Set = mr.Set.Clone()
+	}
+	if mr.Timestamp != 0 {
+		ms.data[i].Timestamp = mr.Timestamp
+	}
 	return ms.data[i].Clone(), nil
 }
 
@@ -167,7 +184,59 @@ func (ms *MemStorage) fill(data []*model.Metric) {
 		if _, ok := ms.index[data[i].MType]; !ok {
 			ms.index[data[i].MType] = map[string]int{}
 		}
-		ms.index[data[i].MType][data[i].ID] = i
+		ms.index[data[i].MType][data[i].Key()] = i
 		ms.data[i] = data[i].Clone()
 	}
 }
+
+// FindMeta returns the metadata of the metric with the given type and name.
+func (ms *MemStorage) FindMeta(ctx context.Context, t, id string) (*model.Meta, error) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	if m, ok := ms.meta[(&model.Meta{MType: t, ID: id}).Key()]; ok {
+		return m.Clone(), nil
+	}
+	return nil, model.ErrMetaNotFound
+}
+
+// unsafeFindAllMeta returns the metadata of all metrics ordered by type and name.
+func (ms *MemStorage) unsafeFindAllMeta() []*model.Meta {
+	res := make([]*model.Meta, 0, len(ms.meta))
+	for _, m := range ms.meta {
+		res = append(res, m.Clone())
+	}
+	slices.SortFunc(res, func(a, b *model.Meta) int {
+		return strings.Compare(a.Key(), b.Key())
+	})
+	return res
+}
+
+// FindAllMeta returns the metadata of all metrics.
+func (ms *MemStorage) FindAllMeta(ctx context.Context) ([]*model.Meta, error) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	return ms.unsafeFindAllMeta(), nil
+}
+
+// unsafeSaveMeta creates or replaces the metadata.
+func (ms *MemStorage) unsafeSaveMeta(metas []*model.Meta) {
+	for _, m := range metas {
+		ms.meta[m.Key()] = m.Clone()
+	}
+}
+
+// SaveMeta creates or replaces the metadata.
+func (ms *MemStorage) SaveMeta(ctx context.Context, metas []*model.Meta) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.unsafeSaveMeta(metas)
+	return nil
+}
+
+// fillMeta fills the storage with the given metadata.
+func (ms *MemStorage) fillMeta(metas []*model.Meta) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.meta = make(map[string]*model.Meta, len(metas))
+	ms.unsafeSaveMeta(metas)
+}
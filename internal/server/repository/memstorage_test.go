@@ -461,6 +461,25 @@ func TestMemStorage_unsafeUpdate(t *testing.T) {
 			want:    model.NewMetricCounter("test", 13),
 			wantErr: nil,
 		},
+		{
+			name: "update histogram",
+			fields: fields{
+				index: map[string]map[string]int{
+					model.TypeHistogram: {
+						"test": 0,
+					},
+				},
+				data: []*model.Metric{
+					model.NewMetricHistogram("test", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 1}, Sum: 2}),
+				},
+			},
+			args: args{
+				mr: &model.MetricRequest{Metric: model.NewMetricHistogram("test",
+					&model.Histogram{Bounds: []float64{1}, Counts: []uint64{3, 2}, Sum: 5})},
+			},
+			want:    model.NewMetricHistogram("test", &model.Histogram{Bounds: []float64{1}, Counts: []uint64{3, 2}, Sum: 5}),
+			wantErr: nil,
+		},
 		{
 			name: "update with empty value",
 			fields: fields{
@@ -672,6 +691,7 @@ func newMemStorageWithDataAndIndex(data []*model.Metric, index map[string]map[st
 	return &MemStorage{
 		mux:   new(sync.Mutex),
 		index: index,
+		meta:  map[string]*model.Meta{},
 		data:  data,
 	}
 }
@@ -693,3 +713,69 @@ func checkMetricNotSame(t *testing.T, src *model.Metric, got *model.Metric) {
 		require.Nil(t, got.Delta)
 	}
 }
+
+func TestMemStorage_Labels(t *testing.T) {
+	ms := NewMemStorage()
+	h1 := &model.MetricRequest{Metric: model.NewMetricGauge("cpu", 1)}
+	h1.Labels = model.Labels{"host": "h1"}
+	h2 := &model.MetricRequest{Metric: model.NewMetricGauge("cpu", 2)}
+	h2.Labels = model.Labels{"host": "h2"}
+	_, err := ms.Create(t.Context(), h1)
+	require.NoError(t, err)
+	_, err = ms.Create(t.Context(), h2)
+	require.NoError(t, err)
+	_, err = ms.Create(t.Context(), h1)
+	assert.ErrorIs(t, err, model.ErrMetricAlreadyExist)
+
+	got, err := ms.Find(t.Context(), h2)
+	require.NoError(t, err)
+	assert.Equal(t, h2.Metric, got)
+	_, err = ms.Find(t.Context(), &model.MetricRequest{Metric: model.NewMetricGauge("cpu", 0)})
+	assert.ErrorIs(t, err, model.ErrMetricNotFound)
+
+	got, err = ms.Update(t.Context(), &model.MetricRequest{Metric: &model.Metric{
+		Value: h1.Value, Labels: model.Labels{"host": "h1"}, MType: model.TypeGauge, ID: "cpu",
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, h1.Metric, got)
+	assert.Equal(t, map[string]map[string]int{
+		model.TypeGauge: {`cpu{host="h1"}`: 0, `cpu{host="h2"}`: 1},
+	}, ms.index)
+}
+
+func TestMemStorage_Timestamp(t *testing.T) {
+	ms := NewMemStorage()
+	mr := &model.MetricRequest{Metric: model.NewMetricGauge("test", 1)}
+	mr.Timestamp = 100
+	_, err := ms.Create(t.Context(), mr)
+	require.NoError(t, err)
+
+	got, err := ms.Update(t.Context(), &model.MetricRequest{Metric: model.NewMetricGauge("test", 2)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), got.Timestamp)
+
+	mr = &model.MetricRequest{Metric: model.NewMetricGauge("test", 3)}
+	mr.Timestamp = 200
+	got, err = ms.Update(t.Context(), mr)
+	require.NoError(t, err)
+	assert.Equal(t, mr.Metric, got)
+}
+
+func TestMemStorage_Meta(t *testing.T) {
+	ms := NewMemStorage()
+	_, err := ms.FindMeta(t.Context(), model.TypeGauge, "Alloc")
+	assert.ErrorIs(t, err, model.ErrMetaNotFound)
+
+	meta := model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Allocated heap")
+	require.NoError(t, ms.SaveMeta(t.Context(), []*model.Meta{meta}))
+	got, err := ms.FindMeta(t.Context(), model.TypeGauge, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, meta, got)
+	assert.NotSame(t, meta, got)
+
+	updated := model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Bytes of allocated heap objects")
+	require.NoError(t, ms.SaveMeta(t.Context(), []*model.Meta{updated, model.NewMeta(model.TypeCounter, "PollCount", "", "")}))
+	all, err := ms.FindAllMeta(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Meta{model.NewMeta(model.TypeCounter, "PollCount", "", ""), updated}, all)
+}
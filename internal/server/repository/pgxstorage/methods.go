@@ -12,14 +12,14 @@ import (
 )
 
 func (ps *PGXStorage) find(ctx context.Context, mr *model.MetricRequest) (*model.Metric, error) {
-	row := ps.stmts.findOneStmt.QueryRowContext(ctx, mr.MType, mr.ID)
+	row := ps.stmts.findOneStmt.QueryRowContext(ctx, mr.MType, mr.ID, mr.LabelsKey())
 	m := &model.Metric{}
 	err := m.ScanRow(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = fmt.Errorf("%w: %w", model.ErrMetricNotFound, err)
 		}
-		return nil, fmt.Errorf("failed to find metric with type=%s and id=%s: %w", mr.MType, mr.ID, err)
+		return nil, fmt.Errorf("failed to find metric with type=%s and id=%s%s: %w", mr.MType, mr.ID, mr.LabelsKey(), err)
 	}
 	return m, nil
 }
@@ -48,7 +48,8 @@ func (ps *PGXStorage) findBatch(ctx context.Context, mrs []*model.MetricRequest)
 }
 
 func (ps *PGXStorage) create(ctx context.Context, mr *model.MetricRequest) (*model.Metric, error) {
-	row := ps.stmts.createReturningStmt.QueryRowContext(ctx, mr.MType, mr.ID, mr.Value, mr.Delta)
+	row := ps.stmts.createReturningStmt.QueryRowContext(ctx, mr.MType, mr.ID, mr.Value, mr.Delta, mr.Histogram, mr.Summary, mr.Set,
+		mr.Labels, mr.LabelsKey(), mr.Timestamp)
 	m := &model.Metric{}
 	err := m.ScanRow(row)
 	if err != nil {
@@ -62,14 +63,15 @@ func (ps *PGXStorage) create(ctx context.Context, mr *model.MetricRequest) (*mod
 }
 
 func (ps *PGXStorage) update(ctx context.Context, mr *model.MetricRequest) (*model.Metric, error) {
-	row := ps.stmts.updateReturningStmt.QueryRowContext(ctx, mr.Value, mr.Delta, mr.MType, mr.ID)
+	row := ps.stmts.updateReturningStmt.QueryRowContext(ctx, mr.Value, mr.Delta, mr.Histogram, mr.Summary, mr.Set, mr.Timestamp,
+		mr.MType, mr.ID, mr.LabelsKey())
 	m := &model.Metric{}
 	err := m.ScanRow(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = fmt.Errorf("%w: %w", model.ErrMetricNotFound, err)
 		}
-		return nil, fmt.Errorf("failed to update metric with type=%s and id=%s: %w", mr.MType, mr.ID, err)
+		return nil, fmt.Errorf("failed to update metric with type=%s and id=%s%s: %w", mr.MType, mr.ID, mr.LabelsKey(), err)
 	}
 	return m, nil
 }
@@ -84,7 +86,64 @@ func (ps *PGXStorage) createOrUpdateBatch(ctx context.Context, mrs []*model.Metr
 	}()
 	for _, mr := range mrs {
 		//nolint:sqlclosecheck // ignore
-		_, err = tx.StmtContext(ctx, ps.stmts.upsertStmt).ExecContext(ctx, mr.MType, mr.ID, mr.Value, mr.Delta)
+		_, err = tx.StmtContext(ctx, ps.stmts.upsertStmt).ExecContext(ctx, mr.MType, mr.ID, mr.Value, mr.Delta, mr.Histogram, mr.Summary, mr.Set,
+			mr.Labels, mr.LabelsKey(), mr.Timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to query: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (ps *PGXStorage) findMeta(ctx context.Context, t, id string) (*model.Meta, error) {
+	row := ps.stmts.findMetaStmt.QueryRowContext(ctx, t, id)
+	m := &model.Meta{}
+	err := row.Scan(&m.MType, &m.ID, &m.Unit, &m.Help, &m.Description)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("%w: %w", model.ErrMetaNotFound, err)
+		}
+		return nil, fmt.Errorf("failed to find metadata with type=%s and id=%s: %w", t, id, err)
+	}
+	return m, nil
+}
+
+func (ps *PGXStorage) findAllMeta(ctx context.Context) ([]*model.Meta, error) {
+	rows, err := ps.stmts.findAllMetaStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var metas []*model.Meta
+	for rows.Next() {
+		m := &model.Meta{}
+		if err = rows.Scan(&m.MType, &m.ID, &m.Unit, &m.Help, &m.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		metas = append(metas, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+	return metas, nil
+}
+
+func (ps *PGXStorage) saveMeta(ctx context.Context, metas []*model.Meta) error {
+	tx, err := ps.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	for _, m := range metas {
+		//nolint:sqlclosecheck // ignore
+		_, err = tx.StmtContext(ctx, ps.stmts.upsertMetaStmt).ExecContext(ctx, m.MType, m.ID, m.Unit, m.Help, m.Description)
 		if err != nil {
 			return fmt.Errorf("failed to query: %w", err)
 		}
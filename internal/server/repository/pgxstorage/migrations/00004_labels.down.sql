@@ -0,0 +1,5 @@
+DELETE FROM metrics WHERE labels_key <> '';
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (type, id);
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels_key;
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,4 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB NULL;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels_key TEXT NOT NULL DEFAULT '';
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (type, id, labels_key);
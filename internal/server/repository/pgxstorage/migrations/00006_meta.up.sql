@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS metrics_meta (
+   type VARCHAR(255) NOT NULL,
+   id VARCHAR(255) NOT NULL,
+   unit VARCHAR(255) NOT NULL DEFAULT '',
+   help TEXT NOT NULL DEFAULT '',
+   description TEXT NOT NULL DEFAULT '',
+   PRIMARY KEY (type, id)
+);
@@ -129,6 +129,48 @@ func (ps *PGXStorage) CreateOrUpdateBatch(ctx context.Context, mrs []*model.Metr
 	return ps.FindBatch(ctx, mrs)
 }
 
+// FindMeta returns the metadata of the metric with the given type and name.
+func (ps *PGXStorage) FindMeta(ctx context.Context, t, id string) (m *model.Meta, err error) {
+	var e *pgconn.PgError
+	for i := 0; ; i++ {
+		m, err = ps.findMeta(ctx, t, id)
+		if i == len(ps.cfg.RetryDelays) ||
+			err == nil || !errors.As(err, &e) || !pgerrcode.IsConnectionException(e.Code) {
+			break
+		}
+		time.Sleep(ps.cfg.RetryDelays[i])
+	}
+	return m, err
+}
+
+// FindAllMeta returns the metadata of all metrics.
+func (ps *PGXStorage) FindAllMeta(ctx context.Context) (res []*model.Meta, err error) {
+	var e *pgconn.PgError
+	for i := 0; ; i++ {
+		res, err = ps.findAllMeta(ctx)
+		if i == len(ps.cfg.RetryDelays) ||
+			err == nil || !errors.As(err, &e) || !pgerrcode.IsConnectionException(e.Code) {
+			break
+		}
+		time.Sleep(ps.cfg.RetryDelays[i])
+	}
+	return res, err
+}
+
+// SaveMeta creates or replaces the metadata.
+func (ps *PGXStorage) SaveMeta(ctx context.Context, metas []*model.Meta) (err error) {
+	var e *pgconn.PgError
+	for i := 0; ; i++ {
+		err = ps.saveMeta(ctx, metas)
+		if i == len(ps.cfg.RetryDelays) ||
+			err == nil || !errors.As(err, &e) || !pgerrcode.IsConnectionException(e.Code) {
+			break
+		}
+		time.Sleep(ps.cfg.RetryDelays[i])
+	}
+	return err
+}
+
 // retryForOne retries the function for one metric.
 func (ps *PGXStorage) retryForOne(ctx context.Context, mr *model.MetricRequest,
 	f func(ctx context.Context, mr *model.MetricRequest) (*model.Metric, error)) (m *model.Metric, err error) {
@@ -11,23 +11,38 @@ import (
 )
 
 const (
-	findOneQuery         = "SELECT type, id, value, delta FROM metrics WHERE type = $1 AND id = $2 LIMIT 1;"
-	findAllQuery         = "SELECT type, id, value, delta FROM metrics ORDER BY type, id;"
-	findBatchQueryTpl    = "SELECT type, id, value, delta FROM metrics WHERE %s ORDER BY type, id;"
-	createReturningQuery = "INSERT INTO metrics (type, id, value, delta) VALUES ($1, $2, $3, $4) RETURNING type, id, value, delta;"
-	updateReturningQuery = "UPDATE metrics SET value = $1, delta = $2 WHERE type = $3 AND id = $4 RETURNING type, id, value, delta;"
-	upsertQuery          = `INSERT INTO metrics (type, id, value, delta) VALUES ($1, $2, $3, $4) ON CONFLICT (type, id) 
-    DO UPDATE SET value = EXCLUDED.value, delta = EXCLUDED.delta;`
+	findOneQuery = `SELECT type, id, value, delta, histogram, summary, hll, labels, ts FROM metrics 
+    WHERE type = $1 AND id = $2 AND labels_key = $3 LIMIT 1;`
+	findAllQuery      = "SELECT type, id, value, delta, histogram, summary, hll, labels, ts FROM metrics ORDER BY type, id, labels_key;"
+	findBatchQueryTpl = `SELECT type, id, value, delta, histogram, summary, hll, labels, ts FROM metrics 
+    WHERE %s ORDER BY type, id, labels_key;`
+	createReturningQuery = `INSERT INTO metrics (type, id, value, delta, histogram, summary, hll, labels, labels_key, ts) 
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
+    RETURNING type, id, value, delta, histogram, summary, hll, labels, ts;`
+	updateReturningQuery = `UPDATE metrics SET value = $1, delta = $2, histogram = $3, summary = $4, hll = $5, 
+    ts = COALESCE(NULLIF($6::BIGINT, 0), ts) 
+    WHERE type = $7 AND id = $8 AND labels_key = $9 
+    RETURNING type, id, value, delta, histogram, summary, hll, labels, ts;`
+	upsertQuery = `INSERT INTO metrics (type, id, value, delta, histogram, summary, hll, labels, labels_key, ts) 
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
+    ON CONFLICT (type, id, labels_key) 
+    DO UPDATE SET value = EXCLUDED.value, delta = EXCLUDED.delta, histogram = EXCLUDED.histogram, summary = EXCLUDED.summary, 
+    hll = EXCLUDED.hll, ts = COALESCE(NULLIF(EXCLUDED.ts, 0), metrics.ts);`
+	findMetaQuery    = "SELECT type, id, unit, help, description FROM metrics_meta WHERE type = $1 AND id = $2 LIMIT 1;"
+	findAllMetaQuery = "SELECT type, id, unit, help, description FROM metrics_meta ORDER BY type, id;"
+	upsertMetaQuery  = `INSERT INTO metrics_meta (type, id, unit, help, description) VALUES ($1, $2, $3, $4, $5) 
+    ON CONFLICT (type, id) 
+    DO UPDATE SET unit = EXCLUDED.unit, help = EXCLUDED.help, description = EXCLUDED.description;`
 )
 
 func makeFindBatchQuery(mrs []*model.MetricRequest) (q string, params []any) {
-	const numColumns = 2
+	const numColumns = 3
 	params = make([]any, 0, len(mrs)*numColumns)
 	args := make([]string, 0, len(mrs))
 	for i, mr := range mrs {
 		k := i*numColumns + 1
-		args = append(args, "(type=$"+strconv.Itoa(k)+" AND id=$"+strconv.Itoa(k+1)+")")
-		params = append(params, mr.MType, mr.ID)
+		args = append(args, "(type=$"+strconv.Itoa(k)+" AND id=$"+strconv.Itoa(k+1)+" AND labels_key=$"+strconv.Itoa(k+2)+")")
+		params = append(params, mr.MType, mr.ID, mr.LabelsKey())
 	}
 	return fmt.Sprintf(findBatchQueryTpl, strings.Join(args, " OR ")), params
 }
@@ -38,6 +53,9 @@ type statements struct {
 	createReturningStmt *sql.Stmt
 	updateReturningStmt *sql.Stmt
 	upsertStmt          *sql.Stmt
+	findMetaStmt        *sql.Stmt
+	findAllMetaStmt     *sql.Stmt
+	upsertMetaStmt      *sql.Stmt
 }
 
 func (ps *PGXStorage) prepareStatements(ctx context.Context) (st *statements, err error) {
@@ -62,5 +80,17 @@ func (ps *PGXStorage) prepareStatements(ctx context.Context) (st *statements, er
 	if err != nil {
 		return st, fmt.Errorf("failed to prepare upsertQuery: %w", err)
 	}
+	st.findMetaStmt, err = ps.db.PrepareContext(ctx, findMetaQuery)
+	if err != nil {
+		return st, fmt.Errorf("failed to prepare findMetaQuery: %w", err)
+	}
+	st.findAllMetaStmt, err = ps.db.PrepareContext(ctx, findAllMetaQuery)
+	if err != nil {
+		return st, fmt.Errorf("failed to prepare findAllMetaQuery: %w", err)
+	}
+	st.upsertMetaStmt, err = ps.db.PrepareContext(ctx, upsertMetaQuery)
+	if err != nil {
+		return st, fmt.Errorf("failed to prepare upsertMetaQuery: %w", err)
+	}
 	return
 }
@@ -4,10 +4,12 @@ package server
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/korobkovandrey/runtime-metrics/pkg/logging"
+	"google.golang.org/grpc"
 )
 
 // ListenAndServe starts the HTTP server.
@@ -34,3 +36,34 @@ func ListenAndServe(ctx context.Context, l *logging.ZapLogger,
 	}
 	return nil
 }
+
+// ServeGRPC starts the gRPC server on addr and blocks until the context is done and the server is stopped.
+//
+// The server is stopped gracefully when the context is done, the calls which are not finished
+// within shutdownTimeout are canceled.
+func ServeGRPC(ctx context.Context, l *logging.ZapLogger, addr string, shutdownTimeout time.Duration, s *grpc.Server) error {
+	lis, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		l.InfoCtx(context.WithoutCancel(ctx), "Shutting down the gRPC server...")
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+		t := time.NewTimer(shutdownTimeout)
+		defer t.Stop()
+		select {
+		case <-stopped:
+		case <-t.C:
+			s.Stop()
+		}
+	}()
+	if err = s.Serve(lis); err != nil {
+		return fmt.Errorf("failed to serve gRPC: %w", err)
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
@@ -21,59 +22,120 @@ type BatchUpdaterRepository interface {
 
 // BatchUpdater is a service for batch updating metrics.
 type BatchUpdater struct {
-	r BatchUpdaterRepository
+	r      BatchUpdaterRepository
+	policy OutOfOrderPolicy
 }
 
 // NewBatchUpdater returns a service for batch updating metrics.
-func NewBatchUpdater(r BatchUpdaterRepository) *BatchUpdater {
-	return &BatchUpdater{r: r}
+func NewBatchUpdater(r BatchUpdaterRepository, policy OutOfOrderPolicy) *BatchUpdater {
+	return &BatchUpdater{r: r, policy: policy}
 }
 
 // UpdateBatch updates the metrics.
+//
+// Accumulating metrics (counters, histograms, summaries and sets) with the same ID are merged together
+// and with the stored values, for other metrics the last value wins. The raw members of the set metrics
+// are added to their sketches, so only the sketches are stored.
+//
+// The gauge samples older than the stored points are handled according to the out-of-order policy:
+// the ignored samples are not written and not returned, a rejected sample fails the whole batch.
 func (s *BatchUpdater) UpdateBatch(ctx context.Context, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+	mrsReq, err := groupBatch(mrs)
+	if err != nil {
+		return nil, err
+	}
+	mrsFind := mrsReq
+	if s.policy == OutOfOrderAccept {
+		mrsFind = slices.DeleteFunc(slices.Clone(mrsReq), func(mr *model.MetricRequest) bool {
+			return !model.IsAccumulating(mr.MType)
+		})
+	}
+	if len(mrsFind) > 0 {
+		mExist, err := s.r.FindBatch(ctx, mrsFind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find batch: %w", err)
+		}
+		if mrsReq, err = s.applyStored(mrsReq, mExist); err != nil {
+			return nil, err
+		}
+	}
+	if len(mrsReq) == 0 {
+		return []*model.Metric{}, nil
+	}
+	res, err := s.r.CreateOrUpdateBatch(ctx, mrsReq)
+	if err != nil {
+		return res, fmt.Errorf("failed to update batch: %w", err)
+	}
+	return res, nil
+}
+
+// groupBatch merges the accumulating metrics with the same key and keeps the last of the other metrics
+// with the same key. The accumulating metrics go first in order of appearance, then the other ones.
+func groupBatch(mrs []*model.MetricRequest) ([]*model.MetricRequest, error) {
 	var mrsReq []*model.MetricRequest
 	mrsGaugeIndexMap := map[string]int{}
-	mrsCounterMap := map[string]*model.MetricRequest{}
+	mrsAccumulatingMap := map[string]*model.MetricRequest{}
 	for i := range mrs {
-		if mrs[i].MType != model.TypeCounter {
-			mrsGaugeIndexMap[mrs[i].ID] = i
+		mrs[i].FoldMembers()
+		key := metricKey(mrs[i].Metric)
+		if !model.IsAccumulating(mrs[i].MType) {
+			mrsGaugeIndexMap[key] = i
 			continue
 		}
-		if _, ok := mrsCounterMap[mrs[i].ID]; ok {
-			*mrsCounterMap[mrs[i].ID].Delta += *mrs[i].Delta
+		if _, ok := mrsAccumulatingMap[key]; ok {
+			if err := mrsAccumulatingMap[key].Merge(mrs[i].Metric); err != nil {
+				return nil, fmt.Errorf("failed to merge metric %s: %w", mrs[i].ID, err)
+			}
 		} else {
-			mrsCounterMap[mrs[i].ID] = mrs[i]
-			mrsReq = append(mrsReq, mrsCounterMap[mrs[i].ID])
-		}
-	}
-	if len(mrsReq) > 0 {
-		mCounterExist, err := s.r.FindBatch(ctx, mrsReq)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find batch: %w", err)
-		}
-		for i := range mCounterExist {
-			*mrsCounterMap[mCounterExist[i].ID].Delta += *mCounterExist[i].Delta
+			mrsAccumulatingMap[key] = mrs[i]
+			mrsReq = append(mrsReq, mrsAccumulatingMap[key])
 		}
 	}
-
 	if len(mrsGaugeIndexMap) > 0 {
-		indexes := make([]int, len(mrsGaugeIndexMap))
-		k := 0
+		indexes := make([]int, 0, len(mrsGaugeIndexMap))
 		for _, i := range mrsGaugeIndexMap {
-			indexes[k] = i
-			k++
+			indexes = append(indexes, i)
 		}
 		sort.Ints(indexes)
 		for _, i := range indexes {
 			mrsReq = append(mrsReq, mrs[i])
 		}
 	}
-	if len(mrsReq) == 0 {
-		return []*model.Metric{}, nil
+	return mrsReq, nil
+}
+
+// applyStored merges the accumulating metrics with the stored ones and drops the samples
+// which must not be written according to the out-of-order policy.
+func (s *BatchUpdater) applyStored(mrs []*model.MetricRequest, mExist []*model.Metric) ([]*model.MetricRequest, error) {
+	mExistMap := make(map[string]*model.Metric, len(mExist))
+	for _, m := range mExist {
+		mExistMap[metricKey(m)] = m
 	}
-	res, err := s.r.CreateOrUpdateBatch(ctx, mrsReq)
-	if err != nil {
-		return res, fmt.Errorf("failed to update batch: %w", err)
+	res := mrs[:0]
+	for _, mr := range mrs {
+		m, ok := mExistMap[metricKey(mr.Metric)]
+		if !ok {
+			res = append(res, mr)
+			continue
+		}
+		write, err := s.policy.check(mr, m)
+		if err != nil {
+			return nil, err
+		}
+		if !write {
+			continue
+		}
+		if model.IsAccumulating(mr.MType) {
+			if err = mr.Merge(m); err != nil {
+				return nil, fmt.Errorf("failed to merge metric %s: %w", mr.ID, err)
+			}
+		}
+		res = append(res, mr)
 	}
 	return res, nil
 }
+
+// metricKey returns the key identifying the metric in the batch.
+func metricKey(m *model.Metric) string {
+	return m.MType + ":" + m.Key()
+}
@@ -5,7 +5,10 @@ import (
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
 	"github.com/korobkovandrey/runtime-metrics/internal/server/service/mocks"
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -28,7 +31,7 @@ func TestBatchUpdater_UpdateBatch(t *testing.T) {
 		mrsR[i] = &model.MetricRequest{Metric: want[i].Clone()}
 	}
 	r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Eq(mrsR)).Return(want, nil)
-	got, err := NewBatchUpdater(r).UpdateBatch(t.Context(), []*model.MetricRequest{
+	got, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
 		{Metric: model.NewMetricGauge("testNotExist", 12.3)},
 		{Metric: model.NewMetricCounter("testNotExist", 1)},
 		{Metric: model.NewMetricGauge("testNotExist", 22.5)},
@@ -41,3 +44,130 @@ func TestBatchUpdater_UpdateBatch(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, want, got)
 }
+
+func TestBatchUpdater_UpdateBatch_Histogram(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	newHistogram := func(counts []uint64, sum float64) *model.Metric {
+		return model.NewMetricHistogram("latency", &model.Histogram{Bounds: []float64{0.1, 1}, Counts: counts, Sum: sum})
+	}
+	r := mocks.NewMockBatchUpdaterRepository(ctrl)
+	r.EXPECT().FindBatch(gomock.Any(), gomock.Eq([]*model.MetricRequest{
+		{Metric: newHistogram([]uint64{3, 1, 1}, 4.5)},
+		{Metric: model.NewMetricCounter("latency", 2)},
+	})).Return([]*model.Metric{newHistogram([]uint64{10, 0, 0}, 0.5)}, nil)
+	want := []*model.Metric{
+		newHistogram([]uint64{13, 1, 1}, 5),
+		model.NewMetricCounter("latency", 2),
+	}
+	mrsR := make([]*model.MetricRequest, len(want))
+	for i := range want {
+		mrsR[i] = &model.MetricRequest{Metric: want[i].Clone()}
+	}
+	r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Eq(mrsR)).Return(want, nil)
+	got, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
+		{Metric: newHistogram([]uint64{1, 1, 0}, 1.5)},
+		{Metric: model.NewMetricCounter("latency", 2)},
+		{Metric: newHistogram([]uint64{2, 0, 1}, 3)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	t.Run("bounds mismatch", func(t *testing.T) {
+		r := mocks.NewMockBatchUpdaterRepository(ctrl)
+		r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+		r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+		_, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
+			{Metric: newHistogram([]uint64{1, 1, 0}, 1.5)},
+			{Metric: model.NewMetricHistogram("latency", &model.Histogram{Bounds: []float64{5}, Counts: []uint64{1, 1}, Sum: 9})},
+		})
+		assert.ErrorIs(t, err, model.ErrBoundsMismatch)
+	})
+}
+
+func TestBatchUpdater_UpdateBatch_Summary(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	newSummary := func(values ...float64) *model.Metric {
+		td := tdigest.New(tdigest.DefaultCompression)
+		for _, v := range values {
+			td.Add(v)
+		}
+		return model.NewMetricSummary("latency", td)
+	}
+	r := mocks.NewMockBatchUpdaterRepository(ctrl)
+	r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).Return([]*model.Metric{newSummary(100)}, nil)
+	var got []*model.MetricRequest
+	r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+			got = mrs
+			return nil, nil
+		})
+	_, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
+		{Metric: newSummary(1, 2)},
+		{Metric: newSummary(3)},
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.InDelta(t, 4.0, got[0].Summary.Count(), 1e-9)
+	assert.InDelta(t, 1.0, got[0].Summary.Min(), 1e-9)
+	assert.InDelta(t, 100.0, got[0].Summary.Max(), 1e-9)
+}
+
+func TestBatchUpdater_UpdateBatch_Labels(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	withLabels := func(m *model.Metric, host string) *model.Metric {
+		m.Labels = model.Labels{"host": host}
+		return m
+	}
+	r := mocks.NewMockBatchUpdaterRepository(ctrl)
+	r.EXPECT().FindBatch(gomock.Any(), gomock.Eq([]*model.MetricRequest{
+		{Metric: withLabels(model.NewMetricCounter("requests", 3), "h1")},
+		{Metric: withLabels(model.NewMetricCounter("requests", 2), "h2")},
+	})).Return([]*model.Metric{withLabels(model.NewMetricCounter("requests", 10), "h2")}, nil)
+	want := []*model.Metric{
+		withLabels(model.NewMetricCounter("requests", 3), "h1"),
+		withLabels(model.NewMetricCounter("requests", 12), "h2"),
+	}
+	mrsR := make([]*model.MetricRequest, len(want))
+	for i := range want {
+		mrsR[i] = &model.MetricRequest{Metric: want[i].Clone()}
+	}
+	r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Eq(mrsR)).Return(want, nil)
+	got, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
+		{Metric: withLabels(model.NewMetricCounter("requests", 1), "h1")},
+		{Metric: withLabels(model.NewMetricCounter("requests", 2), "h2")},
+		{Metric: withLabels(model.NewMetricCounter("requests", 2), "h1")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestBatchUpdater_UpdateBatch_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	stored := hll.New(hll.DefaultPrecision)
+	stored.Add("u1")
+	r := mocks.NewMockBatchUpdaterRepository(ctrl)
+	r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).Return([]*model.Metric{model.NewMetricSet("users", stored)}, nil)
+	var got []*model.MetricRequest
+	r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, mrs []*model.MetricRequest) ([]*model.Metric, error) {
+			got = mrs
+			return nil, nil
+		})
+	sketch := hll.New(hll.DefaultPrecision)
+	sketch.Add("u3")
+	_, err := NewBatchUpdater(r, OutOfOrderAccept).UpdateBatch(t.Context(), []*model.MetricRequest{
+		{Metric: &model.Metric{MType: model.TypeSet, ID: "users", Members: []string{"u1", "u2"}}},
+		{Metric: model.NewMetricSet("users", sketch)},
+		{Metric: &model.Metric{MType: model.TypeSet, ID: "ips", Members: []string{"10.0.0.1"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Nil(t, got[0].Members)
+	assert.Equal(t, uint64(3), got[0].AnyValue())
+	assert.Nil(t, got[1].Members)
+	assert.Equal(t, uint64(1), got[1].AnyValue())
+}
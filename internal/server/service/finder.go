@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
 
 	"github.com/korobkovandrey/runtime-metrics/internal/model"
 )
@@ -40,20 +41,20 @@ func (s *Finder) Find(ctx context.Context, mr *model.MetricRequest) (*model.Metr
 	return m, nil
 }
 
-// FindAll returns all metrics
-func (s *Finder) FindAll(ctx context.Context) ([]*model.Metric, error) {
+// FindAll returns all metrics matching all the given label matchers
+func (s *Finder) FindAll(ctx context.Context, matchers ...*model.LabelMatcher) ([]*model.Metric, error) {
 	metrics, err := s.r.FindAll(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find all metrics: %w", err)
 	}
+	if len(matchers) > 0 {
+		metrics = slices.DeleteFunc(metrics, func(m *model.Metric) bool {
+			return !model.MatchLabels(m.Labels, matchers...)
+		})
+	}
 	slices.SortFunc(metrics, func(a *model.Metric, b *model.Metric) int {
 		if a.MType == b.MType {
-			if a.ID > b.ID {
-				return 1
-			} else if a.ID < b.ID {
-				return -1
-			}
-			return 0
+			return strings.Compare(a.Key(), b.Key())
 		}
 		if a.MType > b.MType {
 			return 1
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -65,3 +66,28 @@ func TestFinder_FindAll(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestFinder_FindAll_Matchers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h1 := model.NewMetricGauge("cpu", 1)
+	h1.Labels = model.Labels{"host": "h1"}
+	h2 := model.NewMetricGauge("cpu", 2)
+	h2.Labels = model.Labels{"host": "h2"}
+	noLabels := model.NewMetricGauge("cpu", 3)
+	r := mocks.NewMockFinderRepository(ctrl)
+	r.EXPECT().FindAll(gomock.Any()).DoAndReturn(func(context.Context) ([]*model.Metric, error) {
+		return []*model.Metric{h2, noLabels, h1}, nil
+	}).Times(2)
+	s := NewFinder(r)
+
+	lm, err := model.ParseLabelMatcher("host=~h.*")
+	require.NoError(t, err)
+	got, err := s.FindAll(t.Context(), lm)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Metric{h1, h2}, got)
+
+	got, err = s.FindAll(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Metric{noLabels, h1, h2}, got)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// MetaRepository is a repository for the metrics metadata
+//
+//go:generate mockgen -source=meta.go -destination=mocks/mock_meta.go -package=mocks
+type MetaRepository interface {
+	FindMeta(ctx context.Context, t, id string) (*model.Meta, error)
+	FindAllMeta(ctx context.Context) ([]*model.Meta, error)
+	SaveMeta(ctx context.Context, metas []*model.Meta) error
+}
+
+// MetaService is a service for reading and saving the metrics metadata
+type MetaService struct {
+	r MetaRepository
+}
+
+// NewMetaService returns a new MetaService
+func NewMetaService(r MetaRepository) *MetaService {
+	return &MetaService{r: r}
+}
+
+// Find returns the metadata of the metric with the given type and name
+func (s *MetaService) Find(ctx context.Context, t, id string) (*model.Meta, error) {
+	m, err := s.r.FindMeta(ctx, t, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find metadata: %w", err)
+	}
+	return m, nil
+}
+
+// FindAll returns the metadata of all metrics
+func (s *MetaService) FindAll(ctx context.Context) ([]*model.Meta, error) {
+	metas, err := s.r.FindAllMeta(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find all metadata: %w", err)
+	}
+	return metas, nil
+}
+
+// Save creates or replaces the metadata
+func (s *MetaService) Save(ctx context.Context, metas []*model.Meta) error {
+	if err := s.r.SaveMeta(ctx, metas); err != nil {
+		return fmt.Errorf("failed to save metadata: %w", err)
+	}
+	return nil
+}
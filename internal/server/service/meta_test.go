@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetaService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	meta := model.NewMeta(model.TypeGauge, "Alloc", "bytes", "Allocated heap")
+
+	t.Run("find", func(t *testing.T) {
+		r := mocks.NewMockMetaRepository(ctrl)
+		r.EXPECT().FindMeta(gomock.Any(), model.TypeGauge, "Alloc").Return(meta, nil)
+		got, err := NewMetaService(r).Find(t.Context(), model.TypeGauge, "Alloc")
+		assert.NoError(t, err)
+		assert.Same(t, meta, got)
+	})
+
+	t.Run("find error", func(t *testing.T) {
+		r := mocks.NewMockMetaRepository(ctrl)
+		r.EXPECT().FindMeta(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, model.ErrMetaNotFound)
+		got, err := NewMetaService(r).Find(t.Context(), model.TypeGauge, "Alloc")
+		assert.ErrorIs(t, err, model.ErrMetaNotFound)
+		assert.Nil(t, got)
+	})
+
+	t.Run("find all", func(t *testing.T) {
+		r := mocks.NewMockMetaRepository(ctrl)
+		r.EXPECT().FindAllMeta(gomock.Any()).Return([]*model.Meta{meta}, nil)
+		got, err := NewMetaService(r).FindAll(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, []*model.Meta{meta}, got)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		r := mocks.NewMockMetaRepository(ctrl)
+		r.EXPECT().SaveMeta(gomock.Any(), []*model.Meta{meta}).Return(nil)
+		assert.NoError(t, NewMetaService(r).Save(t.Context(), []*model.Meta{meta}))
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		r := mocks.NewMockMetaRepository(ctrl)
+		r.EXPECT().SaveMeta(gomock.Any(), gomock.Any()).Return(errors.New("error"))
+		assert.Error(t, NewMetaService(r).Save(t.Context(), []*model.Meta{meta}))
+	})
+}
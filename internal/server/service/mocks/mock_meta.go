@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: meta.go
+//
+// Generated by this command:
+//
+//	mockgen -source=meta.go -destination=mocks/mock_meta.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/korobkovandrey/runtime-metrics/internal/model"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMetaRepository is a mock of MetaRepository interface.
+type MockMetaRepository struct {
+	isgomock struct{}
+	ctrl     *gomock.Controller
+	recorder *MockMetaRepositoryMockRecorder
+}
+
+// MockMetaRepositoryMockRecorder is the mock recorder for MockMetaRepository.
+type MockMetaRepositoryMockRecorder struct {
+	mock *MockMetaRepository
+}
+
+// NewMockMetaRepository creates a new mock instance.
+func NewMockMetaRepository(ctrl *gomock.Controller) *MockMetaRepository {
+	mock := &MockMetaRepository{ctrl: ctrl}
+	mock.recorder = &MockMetaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetaRepository) EXPECT() *MockMetaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindAllMeta mocks base method.
+func (m *MockMetaRepository) FindAllMeta(ctx context.Context) ([]*model.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllMeta", ctx)
+	ret0, _ := ret[0].([]*model.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllMeta indicates an expected call of FindAllMeta.
+func (mr *MockMetaRepositoryMockRecorder) FindAllMeta(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllMeta", reflect.TypeOf((*MockMetaRepository)(nil).FindAllMeta), ctx)
+}
+
+// FindMeta mocks base method.
+func (m *MockMetaRepository) FindMeta(ctx context.Context, t, id string) (*model.Meta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMeta", ctx, t, id)
+	ret0, _ := ret[0].(*model.Meta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindMeta indicates an expected call of FindMeta.
+func (mr *MockMetaRepositoryMockRecorder) FindMeta(ctx, t, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMeta", reflect.TypeOf((*MockMetaRepository)(nil).FindMeta), ctx, t, id)
+}
+
+// SaveMeta mocks base method.
+func (m *MockMetaRepository) SaveMeta(ctx context.Context, metas []*model.Meta) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMeta", ctx, metas)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMeta indicates an expected call of SaveMeta.
+func (mr *MockMetaRepositoryMockRecorder) SaveMeta(ctx, metas any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMeta", reflect.TypeOf((*MockMetaRepository)(nil).SaveMeta), ctx, metas)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+)
+
+// OutOfOrderPolicy defines what to do with the gauge samples older than the stored point
+//
+// The policy is not applied to the counters, histograms, summaries and sets: their samples are deltas
+// which are merged with the stored value whatever their timestamps are, so a delayed delta is not lost
+type OutOfOrderPolicy string
+
+const (
+	// OutOfOrderAccept writes the sample as received
+	OutOfOrderAccept OutOfOrderPolicy = "accept"
+	// OutOfOrderIgnore drops the sample and keeps the stored point
+	OutOfOrderIgnore OutOfOrderPolicy = "ignore"
+	// OutOfOrderReject fails the request with model.ErrOutOfOrder
+	OutOfOrderReject OutOfOrderPolicy = "reject"
+)
+
+// ParseOutOfOrderPolicy returns the policy by its name
+func ParseOutOfOrderPolicy(s string) (OutOfOrderPolicy, error) {
+	switch p := OutOfOrderPolicy(s); p {
+	case OutOfOrderAccept, OutOfOrderIgnore, OutOfOrderReject:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown out-of-order policy %q", s)
+}
+
+// check returns true if the sample should be written over the stored metric
+// and an error if the sample must be rejected.
+func (p OutOfOrderPolicy) check(mr *model.MetricRequest, m *model.Metric) (bool, error) {
+	if p == OutOfOrderAccept || m == nil || model.IsAccumulating(mr.MType) || !mr.IsOutOfOrder(m) {
+		return true, nil
+	}
+	if p == OutOfOrderReject {
+		return false, fmt.Errorf("%w: %s %s has timestamp %d older than stored %d",
+			model.ErrOutOfOrder, mr.MType, mr.Key(), mr.Timestamp, m.Timestamp)
+	}
+	return false, nil
+}
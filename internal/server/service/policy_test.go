@@ -0,0 +1,170 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/korobkovandrey/runtime-metrics/internal/model"
+	"github.com/korobkovandrey/runtime-metrics/internal/server/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestParseOutOfOrderPolicy(t *testing.T) {
+	for _, s := range []string{"accept", "ignore", "reject"} {
+		p, err := ParseOutOfOrderPolicy(s)
+		require.NoError(t, err)
+		assert.Equal(t, OutOfOrderPolicy(s), p)
+	}
+	_, err := ParseOutOfOrderPolicy("drop")
+	assert.Error(t, err)
+}
+
+func newTimestampedGauge(value float64, ts int64) *model.Metric {
+	m := model.NewMetricGauge("test", value)
+	m.Timestamp = ts
+	return m
+}
+
+func TestUpdater_Update_OutOfOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("accept", func(t *testing.T) {
+		stored := newTimestampedGauge(1, 200)
+		mr := &model.MetricRequest{Metric: newTimestampedGauge(2, 100)}
+		want := mr.Clone()
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(stored, nil)
+		r.EXPECT().Update(gomock.Any(), gomock.Eq(mr)).Return(want, nil)
+		got, err := NewUpdater(r, OutOfOrderAccept).Update(t.Context(), mr)
+		require.NoError(t, err)
+		assert.Same(t, want, got)
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		stored := newTimestampedGauge(1, 200)
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(stored, nil)
+		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+		got, err := NewUpdater(r, OutOfOrderIgnore).Update(t.Context(), &model.MetricRequest{Metric: newTimestampedGauge(2, 100)})
+		require.NoError(t, err)
+		assert.Same(t, stored, got)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(newTimestampedGauge(1, 200), nil)
+		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+		got, err := NewUpdater(r, OutOfOrderReject).Update(t.Context(), &model.MetricRequest{Metric: newTimestampedGauge(2, 100)})
+		assert.ErrorIs(t, err, model.ErrOutOfOrder)
+		assert.Nil(t, got)
+	})
+
+	t.Run("reject in order", func(t *testing.T) {
+		mr := &model.MetricRequest{Metric: newTimestampedGauge(2, 300)}
+		want := mr.Clone()
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(newTimestampedGauge(1, 200), nil)
+		r.EXPECT().Update(gomock.Any(), gomock.Eq(mr)).Return(want, nil)
+		got, err := NewUpdater(r, OutOfOrderReject).Update(t.Context(), mr)
+		require.NoError(t, err)
+		assert.Same(t, want, got)
+	})
+
+	for _, policy := range []OutOfOrderPolicy{OutOfOrderIgnore, OutOfOrderReject} {
+		t.Run("older counter is merged with "+string(policy), func(t *testing.T) {
+			stored := model.NewMetricCounter("test", 1)
+			stored.Timestamp = 200
+			mr := &model.MetricRequest{Metric: model.NewMetricCounter("test", 2)}
+			mr.Timestamp = 100
+			want := model.NewMetricCounter("test", 3)
+			want.Timestamp = 200
+			r := mocks.NewMockUpdaterRepository(ctrl)
+			r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(stored, nil)
+			r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(want, nil)
+			got, err := NewUpdater(r, policy).Update(t.Context(), mr)
+			require.NoError(t, err)
+			assert.Same(t, want, got)
+		})
+	}
+
+	t.Run("counter keeps latest timestamp", func(t *testing.T) {
+		stored := model.NewMetricCounter("test", 1)
+		stored.Timestamp = 200
+		mr := &model.MetricRequest{Metric: model.NewMetricCounter("test", 2)}
+		mr.Timestamp = 100
+		want := model.NewMetricCounter("test", 3)
+		want.Timestamp = 200
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(stored, nil)
+		r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(want, nil)
+		_, err := NewUpdater(r, OutOfOrderAccept).Update(t.Context(), mr)
+		require.NoError(t, err)
+	})
+}
+
+func TestBatchUpdater_UpdateBatch_OutOfOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	newCounter := func(delta, ts int64) *model.Metric {
+		m := model.NewMetricCounter("test", delta)
+		m.Timestamp = ts
+		return m
+	}
+
+	t.Run("ignore", func(t *testing.T) {
+		r := mocks.NewMockBatchUpdaterRepository(ctrl)
+		r.EXPECT().FindBatch(gomock.Any(), gomock.Eq([]*model.MetricRequest{
+			{Metric: newCounter(1, 100)},
+			{Metric: newTimestampedGauge(2, 100)},
+		})).Return([]*model.Metric{newCounter(10, 200), newTimestampedGauge(1, 50)}, nil)
+		want := []*model.Metric{newCounter(11, 200), newTimestampedGauge(2, 100)}
+		r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Eq([]*model.MetricRequest{
+			{Metric: want[0].Clone()},
+			{Metric: want[1].Clone()},
+		})).Return(want, nil)
+		got, err := NewBatchUpdater(r, OutOfOrderIgnore).UpdateBatch(t.Context(), []*model.MetricRequest{
+			{Metric: newCounter(1, 100)},
+			{Metric: newTimestampedGauge(2, 100)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("ignore all", func(t *testing.T) {
+		r := mocks.NewMockBatchUpdaterRepository(ctrl)
+		r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).Return([]*model.Metric{newTimestampedGauge(1, 200)}, nil)
+		r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+		got, err := NewBatchUpdater(r, OutOfOrderIgnore).UpdateBatch(t.Context(), []*model.MetricRequest{
+			{Metric: newTimestampedGauge(2, 100)},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		r := mocks.NewMockBatchUpdaterRepository(ctrl)
+		r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).
+			Return([]*model.Metric{newCounter(10, 200), newTimestampedGauge(1, 200)}, nil)
+		r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Any()).MaxTimes(0)
+		_, err := NewBatchUpdater(r, OutOfOrderReject).UpdateBatch(t.Context(), []*model.MetricRequest{
+			{Metric: newCounter(1, 100)},
+			{Metric: newTimestampedGauge(2, 100)},
+		})
+		assert.ErrorIs(t, err, model.ErrOutOfOrder)
+	})
+
+	t.Run("reject keeps older counter", func(t *testing.T) {
+		r := mocks.NewMockBatchUpdaterRepository(ctrl)
+		r.EXPECT().FindBatch(gomock.Any(), gomock.Any()).Return([]*model.Metric{newCounter(10, 200)}, nil)
+		want := []*model.Metric{newCounter(11, 200)}
+		r.EXPECT().CreateOrUpdateBatch(gomock.Any(), gomock.Eq([]*model.MetricRequest{{Metric: want[0].Clone()}})).
+			Return(want, nil)
+		got, err := NewBatchUpdater(r, OutOfOrderReject).UpdateBatch(t.Context(), []*model.MetricRequest{
+			{Metric: newCounter(1, 100)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+}
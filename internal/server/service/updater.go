@@ -22,16 +22,21 @@ type UpdaterRepository interface {
 
 // Updater is a service for updating metrics
 type Updater struct {
-	r UpdaterRepository
+	r      UpdaterRepository
+	policy OutOfOrderPolicy
 }
 
 // NewUpdater returns a new Updater
-func NewUpdater(r UpdaterRepository) *Updater {
-	return &Updater{r: r}
+func NewUpdater(r UpdaterRepository, policy OutOfOrderPolicy) *Updater {
+	return &Updater{r: r, policy: policy}
 }
 
 // Update updates the metric
+//
+// The gauge sample older than the stored point is handled according to the out-of-order policy,
+// the ignored sample leaves the stored metric unchanged.
 func (s *Updater) Update(ctx context.Context, mr *model.MetricRequest) (*model.Metric, error) {
+	mr.FoldMembers()
 	m, err := s.r.Find(ctx, mr)
 	if err != nil {
 		if !errors.Is(err, model.ErrMetricNotFound) {
@@ -49,16 +54,23 @@ func (s *Updater) Update(ctx context.Context, mr *model.MetricRequest) (*model.M
 			return m, fmt.Errorf("failed to find metric: %w", err)
 		}
 	}
+	write, err := s.policy.check(mr, m)
+	if err != nil {
+		return nil, err
+	}
+	if !write {
+		return m, nil
+	}
 	needUpdate := false
 	switch mr.MType {
 	case model.TypeGauge:
 		if mr.Value != nil {
 			needUpdate = true
 		}
-	case model.TypeCounter:
-		if mr.Delta != nil {
-			if m.Delta != nil {
-				*mr.Delta += *m.Delta
+	case model.TypeCounter, model.TypeHistogram, model.TypeSummary, model.TypeSet:
+		if mr.AnyValue() != nil {
+			if err = mr.Merge(m); err != nil {
+				return nil, fmt.Errorf("failed to merge metric: %w", err)
 			}
 			needUpdate = true
 		}
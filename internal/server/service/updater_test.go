@@ -23,7 +23,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(nil, model.ErrMetricNotFound)
 		r.EXPECT().Create(gomock.Any(), gomock.Eq(mr)).Return(want, nil)
 		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.NoError(t, err)
 		assert.Same(t, want, got)
@@ -39,7 +39,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(memMetric, nil)
 		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
 		r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(want, nil)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.NoError(t, err)
 		assert.Same(t, want, got)
@@ -55,12 +55,49 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(memMetric, nil)
 		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
 		r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(want, nil)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.NoError(t, err)
 		assert.Same(t, want, got)
 	})
 
+	t.Run("updating histogram", func(t *testing.T) {
+		mr := &model.MetricRequest{Metric: model.NewMetricHistogram("test", &model.Histogram{
+			Bounds: []float64{1, 10}, Counts: []uint64{1, 2, 3}, Sum: 50,
+		})}
+		memMetric := model.NewMetricHistogram("test", &model.Histogram{
+			Bounds: []float64{1, 10}, Counts: []uint64{4, 0, 1}, Sum: 20,
+		})
+		want := model.NewMetricHistogram("test", &model.Histogram{
+			Bounds: []float64{1, 10}, Counts: []uint64{5, 2, 4}, Sum: 70,
+		})
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(memMetric, nil)
+		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
+		r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(want, nil)
+		s := NewUpdater(r, OutOfOrderAccept)
+		got, err := s.Update(t.Context(), mr)
+		assert.NoError(t, err)
+		assert.Same(t, want, got)
+	})
+
+	t.Run("updating histogram bounds mismatch", func(t *testing.T) {
+		mr := &model.MetricRequest{Metric: model.NewMetricHistogram("test", &model.Histogram{
+			Bounds: []float64{1, 10}, Counts: []uint64{1, 2, 3}, Sum: 50,
+		})}
+		memMetric := model.NewMetricHistogram("test", &model.Histogram{
+			Bounds: []float64{5}, Counts: []uint64{4, 1}, Sum: 20,
+		})
+		r := mocks.NewMockUpdaterRepository(ctrl)
+		r.EXPECT().Find(gomock.Any(), gomock.Any()).Return(memMetric, nil)
+		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
+		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
+		s := NewUpdater(r, OutOfOrderAccept)
+		got, err := s.Update(t.Context(), mr)
+		assert.Nil(t, got)
+		assert.ErrorIs(t, err, model.ErrValueIsNotValid)
+	})
+
 	t.Run("error", func(t *testing.T) {
 		mr, err := model.NewMetricRequest(model.TypeGauge, "test", "10")
 		require.NoError(t, err)
@@ -68,7 +105,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(nil, errors.New("error"))
 		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
 		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.Nil(t, got)
 		assert.Error(t, err)
@@ -81,7 +118,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(nil, model.ErrMetricNotFound)
 		r.EXPECT().Create(gomock.Any(), gomock.Eq(mr)).Return(nil, errors.New("error"))
 		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.Nil(t, got)
 		assert.Error(t, err)
@@ -94,7 +131,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Times(2).Return(nil, model.ErrMetricNotFound)
 		r.EXPECT().Create(gomock.Any(), gomock.Eq(mr)).Return(nil, model.ErrMetricAlreadyExist)
 		r.EXPECT().Update(gomock.Any(), gomock.Any()).MaxTimes(0)
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.Nil(t, got)
 		assert.Error(t, err)
@@ -110,7 +147,7 @@ func TestUpdater_Update(t *testing.T) {
 		r.EXPECT().Find(gomock.Any(), gomock.Eq(mr)).Return(memMetric, nil)
 		r.EXPECT().Create(gomock.Any(), gomock.Any()).MaxTimes(0)
 		r.EXPECT().Update(gomock.Any(), gomock.Eq(&model.MetricRequest{Metric: want})).Return(nil, errors.New("error"))
-		s := NewUpdater(r)
+		s := NewUpdater(r, OutOfOrderAccept)
 		got, err := s.Update(t.Context(), mr)
 		assert.Nil(t, got)
 		assert.Error(t, err)
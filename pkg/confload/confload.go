@@ -0,0 +1,290 @@
+// Package confload loads a config struct from the defaults, a config file, the environment and the flags.
+//
+// The precedence is flags > env > file > defaults: the defaults are the defaults of the flags bound
+// to the struct fields, the file and the environment override them, and the flags set on the command
+// line are applied last. The fields are loaded from the environment variable named by the env tag,
+// and from the config file key named by the json tag, the file value is parsed like the value
+// of the environment variable, so the durations are written as "5s" and the lists as arrays.
+package confload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caarlos0/env/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPath is the environment variable with the path of the config file.
+const EnvPath = "CONFIG"
+
+// defaultSource is the source of the fields which are not set.
+const defaultSource = "default"
+
+// Sources are the sources of the config field values by the field names, e.g. "env ADDRESS".
+type Sources map[string]string
+
+// Of returns the source of the field value.
+func (s Sources) Of(field string) string {
+	if src, ok := s[field]; ok {
+		return src
+	}
+	return defaultSource
+}
+
+// Describe returns the fields with their sources, e.g. "Addr from flag -a, Key from default".
+func (s Sources) Describe(fields ...string) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + " from " + s.Of(field)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// field is the struct field loaded from the file and the environment.
+type field struct {
+	value reflect.Value
+	name  string
+	env   string
+	key   string
+	sep   string
+}
+
+// Load loads the config file and the environment into cfg and applies the flags set on the command line again.
+//
+// The cfg must be a pointer to a struct, fs must be parsed. The file is read from path or,
+// if path is empty, from the path in the EnvPath variable, no file is read if both are empty.
+// The file is YAML if its extension is .yaml or .yml and JSON otherwise, the unknown keys are errors.
+func Load(fs *flag.FlagSet, cfg any, path string) (Sources, error) {
+	fields, err := structFields(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var set []*flag.Flag
+	values := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set = append(set, f)
+		values[f.Name] = f.Value.String()
+	})
+	sources := make(Sources)
+	if path == "" {
+		path = os.Getenv(EnvPath)
+	}
+	if path != "" {
+		if err = loadFile(cfg, fields, path, sources); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range fields {
+		v, ok := os.LookupEnv(f.env)
+		if !ok || v == "" {
+			continue
+		}
+		if err = parseVar(cfg, f.env, v); err != nil {
+			return nil, fmt.Errorf("env %s: %w", f.env, err)
+		}
+		sources[f.name] = "env " + f.env
+	}
+	for _, fl := range set {
+		if err = fl.Value.Set(values[fl.Name]); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", fl.Name, err)
+		}
+		ptr := flagPointer(fl.Value)
+		for _, f := range fields {
+			if ptr != 0 && f.value.Addr().Pointer() == ptr {
+				sources[f.name] = "flag -" + fl.Name
+			}
+		}
+	}
+	return sources, nil
+}
+
+// Apply copies the reloadable fields of the reloaded cfg to running and returns the names of the fields
+// whose values differ: the applied reloadable fields and the fields which need a restart, the latter keep
+// the running values.
+//
+// The cfg is expected to be loaded again with the same precedence flags > env > file > defaults, so a field
+// differs if its value is changed in the source it is taken from, e.g. in the config file, or if a source
+// of higher precedence sets or stops setting it. The fields without the env tag are not compared.
+//
+// The running and cfg must be pointers to the structs of the same type.
+func Apply(running, cfg any, reloadable ...string) (applied, restart []string) {
+	runningFields, errRunning := structFields(running)
+	fields, err := structFields(cfg)
+	if errRunning != nil || err != nil || len(runningFields) != len(fields) {
+		return nil, nil
+	}
+	for i, f := range fields {
+		if reflect.DeepEqual(runningFields[i].value.Interface(), f.value.Interface()) {
+			continue
+		}
+		if slices.Contains(reloadable, f.name) {
+			runningFields[i].value.Set(f.value)
+			applied = append(applied, f.name)
+		} else {
+			restart = append(restart, f.name)
+		}
+	}
+	return applied, restart
+}
+
+// structFields returns the fields of the struct having the env tag.
+func structFields(cfg any) ([]field, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config must be a pointer to a struct")
+	}
+	v = v.Elem()
+	var fields []field
+	for i := range v.NumField() {
+		sf := v.Type().Field(i)
+		name, ok := sf.Tag.Lookup("env")
+		if !ok || !sf.IsExported() {
+			continue
+		}
+		f := field{value: v.Field(i), name: sf.Name, env: name, sep: ","}
+		if sep, ok := sf.Tag.Lookup("envSeparator"); ok {
+			f.sep = sep
+		}
+		if key, _, _ := strings.Cut(sf.Tag.Get("json"), ","); key != "-" {
+			f.key = key
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// loadFile loads the values of the config file keys into the fields.
+func loadFile(cfg any, fields []field, path string, sources Sources) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	values := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		err = d.Decode(&values)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		if f.key != "" {
+			byKey[f.key] = f
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown key %q", path, key)
+		}
+		s, err := formatValue(values[key], f.sep)
+		if err != nil {
+			return fmt.Errorf("config file %s: key %s: %w", path, key, err)
+		}
+		if err = parseVar(cfg, f.env, s); err != nil {
+			return fmt.Errorf("config file %s: key %s: %w", path, key, err)
+		}
+		sources[f.name] = "config file " + path
+	}
+	return nil
+}
+
+// parseVar parses the value of the environment variable into the field.
+func parseVar(cfg any, name, value string) error {
+	//nolint:wrapcheck // the error names the field and the value
+	return env.Parse(cfg, env.Options{Environment: map[string]string{name: value}})
+}
+
+// formatValue returns the file value as the value of the environment variable,
+// the array items are joined with the separator.
+func formatValue(v any, sep string) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := formatValue(item, sep)
+			if err != nil {
+				return "", err
+			}
+			if _, ok := item.([]any); ok || strings.Contains(s, sep) {
+				return "", fmt.Errorf("item %d: the item must be a scalar without %q", i, sep)
+			}
+			items[i] = s
+		}
+		return strings.Join(items, sep), nil
+	}
+	return "", fmt.Errorf("unexpected value of type %T", v)
+}
+
+// flagPointer returns the address of the variable the flag value is bound to or 0.
+func flagPointer(v flag.Value) uintptr {
+	if l, ok := v.(*listValue); ok {
+		return reflect.ValueOf(l.list).Pointer()
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		return rv.Pointer()
+	}
+	return 0
+}
+
+// listValue is the flag value of the list separated by sep.
+type listValue struct {
+	list *[]string
+	sep  string
+}
+
+// List returns the flag value setting the list separated by sep, the empty value is the empty list.
+func List(list *[]string, sep string) flag.Value {
+	return &listValue{list: list, sep: sep}
+}
+
+// String returns the list joined with the separator.
+func (l *listValue) String() string {
+	if l.list == nil {
+		return ""
+	}
+	return strings.Join(*l.list, l.sep)
+}
+
+// Set replaces the list.
+func (l *listValue) Set(s string) error {
+	if s == "" {
+		*l.list = nil
+		return nil
+	}
+	*l.list = strings.Split(s, l.sep)
+	return nil
+}
@@ -0,0 +1,163 @@
+package confload
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Addr     string          `env:"TEST_ADDRESS" json:"address"`
+	Key      string          `env:"TEST_KEY" json:"key"`
+	Mode     string          `env:"TEST_MODE" json:"mode"`
+	Hidden   string          `env:"TEST_HIDDEN" json:"-"`
+	Names    []string        `env:"TEST_NAMES" envSeparator:";" json:"names"`
+	Delays   []time.Duration `env:"TEST_DELAYS" envSeparator:"," json:"delays"`
+	Timeout  time.Duration   `env:"TEST_TIMEOUT" json:"timeout"`
+	Interval int             `env:"TEST_INTERVAL" json:"interval"`
+	Enabled  bool            `env:"TEST_ENABLED" json:"enabled"`
+}
+
+// newFlagSet returns the flag set bound to the config and parsed from the args
+func newFlagSet(t *testing.T, cfg *testConfig, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "a", "localhost:8080", "")
+	fs.StringVar(&cfg.Key, "k", "", "")
+	fs.StringVar(&cfg.Mode, "m", "fast", "")
+	fs.Var(List(&cfg.Names, ";"), "names", "")
+	fs.DurationVar(&cfg.Timeout, "timeout", time.Second, "")
+	fs.IntVar(&cfg.Interval, "i", 1, "")
+	require.NoError(t, fs.Parse(args))
+	return fs
+}
+
+// writeFile writes the config file to the temp dir and returns its path
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+		"address": "file:1", "key": "file", "mode": "file", "names": ["a", "b"],
+		"delays": ["1s", "2s"], "timeout": "3s", "interval": 30, "enabled": true
+	}`)
+	t.Setenv("TEST_KEY", "env")
+	t.Setenv("TEST_MODE", "env")
+	cfg := &testConfig{}
+	fs := newFlagSet(t, cfg, "-m", "flag", "-i", "7")
+	src, err := Load(fs, cfg, path)
+	require.NoError(t, err)
+	assert.Equal(t, &testConfig{
+		Addr:     "file:1",
+		Key:      "env",
+		Mode:     "flag",
+		Names:    []string{"a", "b"},
+		Delays:   []time.Duration{time.Second, 2 * time.Second},
+		Timeout:  3 * time.Second,
+		Interval: 7,
+		Enabled:  true,
+	}, cfg)
+	assert.Equal(t, "config file "+path, src.Of("Addr"))
+	assert.Equal(t, "env TEST_KEY", src.Of("Key"))
+	assert.Equal(t, "flag -m", src.Of("Mode"))
+	assert.Equal(t, "flag -i", src.Of("Interval"))
+	assert.Equal(t, "default", src.Of("Hidden"))
+	assert.Equal(t, "Mode from flag -m, Hidden from default", src.Describe("Mode", "Hidden"))
+}
+
+func TestLoad_YAML(t *testing.T) {
+	path := writeFile(t, "config.yaml", "address: yaml:1\nnames:\n  - a\n  - b c\ndelays: [1s, 500ms]\ninterval: 5\n")
+	t.Setenv(EnvPath, path)
+	cfg := &testConfig{}
+	_, err := Load(newFlagSet(t, cfg, "-names", "x;y"), cfg, "")
+	require.NoError(t, err)
+	assert.Equal(t, "yaml:1", cfg.Addr)
+	assert.Equal(t, []string{"x", "y"}, cfg.Names)
+	assert.Equal(t, []time.Duration{time.Second, 500 * time.Millisecond}, cfg.Delays)
+	assert.Equal(t, 5, cfg.Interval)
+	assert.Equal(t, "fast", cfg.Mode)
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg := &testConfig{}
+	src, err := Load(newFlagSet(t, cfg), cfg, "")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8080", cfg.Addr)
+	assert.Equal(t, time.Second, cfg.Timeout)
+	assert.Empty(t, src)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		env     string
+		wantErr string
+	}{
+		{name: "unknown key", file: "c.json", data: `{"adress": "x"}`, wantErr: `unknown key "adress"`},
+		{name: "hidden key", file: "c.json", data: `{"Hidden": "x"}`, wantErr: `unknown key "Hidden"`},
+		{name: "bad file value", file: "c.json", data: `{"interval": "soon"}`, wantErr: "key interval"},
+		{name: "bad duration", file: "c.yml", data: "timeout: 5", wantErr: "key timeout"},
+		{name: "object value", file: "c.json", data: `{"key": {"a": 1}}`, wantErr: "key key: unexpected value"},
+		{name: "separator in item", file: "c.json", data: `{"names": ["a;b"]}`, wantErr: "key names: item 0"},
+		{name: "malformed file", file: "c.json", data: `{"address":`, wantErr: "failed to parse config file"},
+		{name: "missing file", wantErr: "failed to read config file"},
+		{name: "bad env value", env: "soon", wantErr: "env TEST_INTERVAL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			switch {
+			case tt.file != "":
+				path = writeFile(t, tt.file, tt.data)
+			case tt.env == "":
+				path = filepath.Join(t.TempDir(), "missing.json")
+			}
+			if tt.env != "" {
+				t.Setenv("TEST_INTERVAL", tt.env)
+			}
+			cfg := &testConfig{}
+			_, err := Load(newFlagSet(t, cfg), cfg, path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			if tt.file != "" {
+				assert.Contains(t, err.Error(), path)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	var list []string
+	v := List(&list, ",")
+	require.NoError(t, v.Set("a,b"))
+	assert.Equal(t, []string{"a", "b"}, list)
+	assert.Equal(t, "a,b", v.String())
+	require.NoError(t, v.Set(""))
+	assert.Nil(t, list)
+	assert.Empty(t, (&listValue{}).String())
+}
+
+func TestApply(t *testing.T) {
+	running := &testConfig{Addr: "a:1", Key: "old", Interval: 1, Names: []string{"a"}}
+	cfg := &testConfig{Addr: "b:1", Key: "new", Interval: 2, Names: []string{"a"}}
+	applied, restart := Apply(running, cfg, "Key", "Interval", "Names")
+	assert.Equal(t, []string{"Key", "Interval"}, applied)
+	assert.Equal(t, []string{"Addr"}, restart)
+	assert.Equal(t, &testConfig{Addr: "a:1", Key: "new", Interval: 2, Names: []string{"a"}}, running,
+		"the fields which need a restart keep the running values")
+
+	applied, restart = Apply(running, running)
+	assert.Empty(t, applied)
+	assert.Empty(t, restart)
+}
@@ -0,0 +1,26 @@
+package hll_test
+
+import (
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/pkg/hll"
+)
+
+func Example() {
+	// Count the users on two hosts, some users are seen on both
+	host1 := hll.New(hll.DefaultPrecision)
+	host2 := hll.New(hll.DefaultPrecision)
+	for i := range 60 {
+		host1.Add(fmt.Sprintf("user%d", i))
+		host2.Add(fmt.Sprintf("user%d", i+40))
+	}
+
+	// Merge the sketches and estimate the number of distinct users
+	if err := host1.Merge(host2); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("distinct users:", host1.Count())
+	// Output:
+	// distinct users: 100
+}
@@ -0,0 +1,238 @@
+// Package hll provides a mergeable sketch for estimating the number of distinct values of a stream.
+//
+// It implements HyperLogLog by Flajolet et al. with the linear counting correction for small
+// cardinalities: every value is hashed, the first bits of the hash select a register and the register
+// keeps the maximum position of the first set bit of the rest of the hash. The values themselves are not
+// stored, so the memory used by the sketch depends only on its precision. Two sketches of the same
+// precision can be merged without loss of accuracy.
+//
+// Sketch can be marshaled to JSON or to the binary encoding and stored in a database with database/sql.
+package hll
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// MinPrecision is the minimum precision of the sketch.
+	MinPrecision = 4
+	// MaxPrecision is the maximum precision of the sketch.
+	MaxPrecision = 18
+	// DefaultPrecision is the precision used by New when the given precision is out of range,
+	// the standard error of the estimate is about 0.8%.
+	DefaultPrecision = 14
+)
+
+// ErrPrecisionMismatch is returned when sketches of different precisions are merged.
+var ErrPrecisionMismatch = errors.New("precision mismatch")
+
+// Sketch is a HyperLogLog sketch.
+type Sketch struct {
+	registers []uint8
+	precision uint8
+}
+
+// New returns a new empty sketch with the given precision.
+//
+// The sketch uses 2^precision registers, higher precision means more accurate estimates.
+func New(precision uint8) *Sketch {
+	if precision < MinPrecision || precision > MaxPrecision {
+		precision = DefaultPrecision
+	}
+	return &Sketch{
+		registers: make([]uint8, 1<<precision),
+		precision: precision,
+	}
+}
+
+// Precision returns the precision of the sketch.
+func (s *Sketch) Precision() uint8 {
+	return s.precision
+}
+
+// Add adds the value to the sketch.
+func (s *Sketch) Add(v string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(v))
+	s.AddHash(mix(h.Sum64()))
+}
+
+// AddHash adds the value with the given 64-bit hash to the sketch.
+//
+// The hash must be uniformly distributed.
+func (s *Sketch) AddHash(x uint64) {
+	i := x >> (64 - s.precision)
+	// the sentinel bit limits the rank when the rest of the hash is zero
+	w := x<<s.precision | 1<<(s.precision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > s.registers[i] {
+		s.registers[i] = rank
+	}
+}
+
+// Merge adds all values of the given sketch to the sketch.
+func (s *Sketch) Merge(o *Sketch) error {
+	if s.precision != o.precision {
+		return fmt.Errorf("%w: %d and %d", ErrPrecisionMismatch, s.precision, o.precision)
+	}
+	for i, r := range o.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Count returns the estimate of the number of distinct added values.
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := alpha(len(s.registers)) * m * m / sum
+	const linearCountingFactor = 2.5
+	if estimate <= linearCountingFactor*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// Clone returns a copy of the sketch.
+func (s *Sketch) Clone() *Sketch {
+	return &Sketch{
+		registers: slices.Clone(s.registers),
+		precision: s.precision,
+	}
+}
+
+// alpha returns the bias correction constant for the given number of registers.
+func alpha(m int) float64 {
+	switch m {
+	case 16: //nolint:mnd // ignore
+		return 0.673
+	case 32: //nolint:mnd // ignore
+		return 0.697
+	case 64: //nolint:mnd // ignore
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// mix is the finalizer of splitmix64, it spreads the bits of the FNV hash over the whole word.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// jsonSketch is the JSON representation of the sketch.
+type jsonSketch struct {
+	Registers string `json:"registers"`
+	Precision uint8  `json:"precision"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s *Sketch) MarshalJSON() ([]byte, error) {
+	//nolint:wrapcheck // ignore
+	return json.Marshal(jsonSketch{
+		Registers: base64.StdEncoding.EncodeToString(s.registers),
+		Precision: s.precision,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Sketch) UnmarshalJSON(data []byte) error {
+	var js jsonSketch
+	if err := json.Unmarshal(data, &js); err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	registers, err := base64.StdEncoding.DecodeString(js.Registers)
+	if err != nil {
+		return fmt.Errorf("invalid registers: %w", err)
+	}
+	return s.load(js.Precision, registers)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The sketch is encoded as the precision byte followed by the registers.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 1+len(s.registers))
+	data = append(data, s.precision)
+	return append(data, s.registers...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty data")
+	}
+	return s.load(data[0], slices.Clone(data[1:]))
+}
+
+// load sets the sketch from the precision and the registers.
+func (s *Sketch) load(precision uint8, registers []uint8) error {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return errors.New("invalid precision")
+	}
+	if len(registers) != 1<<precision {
+		return errors.New("number of registers does not match precision")
+	}
+	maxRank := 64 - precision + 1
+	if slices.Max(registers) > maxRank {
+		return errors.New("invalid register value")
+	}
+	s.registers, s.precision = registers, precision
+	return nil
+}
+
+// String returns the estimate of the number of distinct values.
+func (s *Sketch) String() string {
+	return fmt.Sprint(s.Count())
+}
+
+// Value implements driver.Valuer, the sketch is stored as JSON.
+func (s *Sketch) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	data, err := s.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sketch: %w", err)
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner, the sketch is stored as JSON.
+func (s *Sketch) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported sketch source type %T", src)
+	}
+	if err := s.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("failed to unmarshal sketch: %w", err)
+	}
+	return nil
+}
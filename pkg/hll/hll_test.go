@@ -0,0 +1,132 @@
+package hll
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch_Count(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		precision uint8
+		delta     float64
+	}{
+		{name: "empty", n: 0, precision: DefaultPrecision},
+		{name: "small", n: 10, precision: DefaultPrecision},
+		{name: "linear counting", n: 1000, precision: DefaultPrecision, delta: 0.01},
+		{name: "large", n: 200000, precision: DefaultPrecision, delta: 0.03},
+		{name: "low precision", n: 10000, precision: MinPrecision, delta: 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.precision)
+			for i := range tt.n {
+				s.Add("user" + strconv.Itoa(i))
+				s.Add("user" + strconv.Itoa(i))
+			}
+			if tt.delta == 0 {
+				assert.Equal(t, uint64(tt.n), s.Count())
+				return
+			}
+			assert.InEpsilon(t, float64(tt.n), float64(s.Count()), tt.delta)
+		})
+	}
+}
+
+func TestNew_Precision(t *testing.T) {
+	assert.Equal(t, uint8(DefaultPrecision), New(0).Precision())
+	assert.Equal(t, uint8(DefaultPrecision), New(MaxPrecision+1).Precision())
+	assert.Equal(t, uint8(MinPrecision), New(MinPrecision).Precision())
+	assert.Len(t, New(MinPrecision).registers, 16)
+}
+
+func TestSketch_AddHash(t *testing.T) {
+	s := New(MinPrecision)
+	s.AddHash(0)
+	assert.Equal(t, uint8(64-MinPrecision+1), s.registers[0])
+	s.AddHash(1<<63 | 1<<59)
+	assert.Equal(t, uint8(1), s.registers[8])
+}
+
+func TestSketch_Merge(t *testing.T) {
+	s1, s2 := New(DefaultPrecision), New(DefaultPrecision)
+	for i := range 1000 {
+		s1.Add(strconv.Itoa(i))
+		s2.Add(strconv.Itoa(i + 500))
+	}
+	c := s1.Clone()
+	require.NoError(t, s1.Merge(s2))
+	assert.InEpsilon(t, 1500.0, float64(s1.Count()), 0.02)
+	assert.InEpsilon(t, 1000.0, float64(c.Count()), 0.02)
+	assert.ErrorIs(t, s1.Merge(New(MinPrecision)), ErrPrecisionMismatch)
+}
+
+func TestSketch_JSON(t *testing.T) {
+	s := New(MinPrecision)
+	for _, v := range []string{"a", "b", "c"} {
+		s.Add(v)
+	}
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	var got Sketch
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, s, &got)
+	assert.Equal(t, "3", got.String())
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid json", data: `[]`},
+		{name: "invalid precision", data: `{"precision":2,"registers":"AAAA"}`},
+		{name: "invalid registers", data: `{"precision":4,"registers":"!"}`},
+		{name: "registers mismatch", data: `{"precision":4,"registers":"AAAA"}`},
+		{name: "invalid register value", data: `{"precision":4,"registers":"/wAAAAAAAAAAAAAAAAAAAA=="}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, json.Unmarshal([]byte(tt.data), &Sketch{}))
+		})
+	}
+}
+
+func TestSketch_ValueScan(t *testing.T) {
+	s := New(MinPrecision)
+	s.Add("a")
+	v, err := s.Value()
+	require.NoError(t, err)
+	var got Sketch
+	require.NoError(t, got.Scan(v))
+	assert.Equal(t, s, &got)
+	require.NoError(t, got.Scan(string(v.([]byte))))
+	assert.Error(t, got.Scan(1))
+	assert.Error(t, got.Scan([]byte(`{}`)))
+
+	v, err = (*Sketch)(nil).Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestSketch_Binary(t *testing.T) {
+	s := New(MinPrecision)
+	for _, v := range []string{"a", "b", "c"} {
+		s.Add(v)
+	}
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+	assert.Len(t, data, 17)
+	var got Sketch
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, s, &got)
+	data[1]++
+	assert.Equal(t, s, &got, "registers must not share the data")
+
+	assert.Error(t, got.UnmarshalBinary(nil))
+	assert.Error(t, got.UnmarshalBinary(data[:5]))
+	assert.Error(t, got.UnmarshalBinary([]byte{MaxPrecision + 1}))
+}
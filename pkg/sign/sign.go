@@ -5,6 +5,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"hash"
 )
 
 // Make returns the HMAC-SHA256 hash of the given data and key.
@@ -42,3 +43,68 @@ func Validate(data, key, hash []byte) bool {
 	h.Write(data)
 	return hmac.Equal(hash, h.Sum(nil))
 }
+
+// Validator checks the hash of the data written to it, so the data does not have to be held in memory.
+type Validator struct {
+	h    hash.Hash
+	hash []byte
+}
+
+// NewValidator returns a new validator of the given hash for the given key.
+func NewValidator(key, hash []byte) *Validator {
+	v := &Validator{hash: hash}
+	if len(key) > 0 && len(hash) > 0 {
+		v.h = hmac.New(sha256.New, key)
+	}
+	return v
+}
+
+// Write adds the data to the hash.
+func (v *Validator) Write(p []byte) (int, error) {
+	if v.h == nil {
+		return len(p), nil
+	}
+	//nolint:wrapcheck // ignore
+	return v.h.Write(p)
+}
+
+// Valid checks if the hash is valid for the written data, it behaves like Validate.
+func (v *Validator) Valid() bool {
+	if v.h == nil {
+		return true
+	}
+	return hmac.Equal(v.hash, v.h.Sum(nil))
+}
+
+// Hasher makes the hash of the data written to it, so the data does not have to be held in memory.
+type Hasher struct {
+	mac hash.Hash
+	n   int
+}
+
+// NewHasher returns a new hasher for the given key.
+func NewHasher(key []byte) *Hasher {
+	h := &Hasher{}
+	if len(key) > 0 {
+		h.mac = hmac.New(sha256.New, key)
+	}
+	return h
+}
+
+// Write adds the data to the hash.
+func (h *Hasher) Write(p []byte) (int, error) {
+	h.n += len(p)
+	if h.mac == nil {
+		return len(p), nil
+	}
+	//nolint:wrapcheck // ignore
+	return h.mac.Write(p)
+}
+
+// Sum returns the hash of the written data, it behaves like Make.
+func (h *Hasher) Sum() []byte {
+	if h.mac == nil || h.n == 0 {
+		return nil
+	}
+	return h.mac.Sum(nil)
+}
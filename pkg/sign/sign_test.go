@@ -217,3 +217,56 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidator(t *testing.T) {
+	key := []byte("secretkey")
+	data := []byte("streamed testdata")
+	tests := []struct {
+		name string
+		key  []byte
+		hash []byte
+		want bool
+	}{
+		{name: "valid", key: key, hash: Make(data, key), want: true},
+		{name: "invalid", key: key, hash: Make([]byte("other"), key), want: false},
+		{name: "empty key", hash: Make(data, key), want: true},
+		{name: "empty hash", key: key, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewValidator(tt.key, tt.hash)
+			for _, part := range [][]byte{data[:8], data[8:]} {
+				n, err := v.Write(part)
+				assert.NoError(t, err)
+				assert.Equal(t, len(part), n)
+			}
+			assert.Equal(t, tt.want, v.Valid())
+			assert.Equal(t, Validate(data, tt.key, tt.hash), v.Valid())
+		})
+	}
+}
+
+func TestHasher(t *testing.T) {
+	key := []byte("secretkey")
+	data := []byte("streamed testdata")
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+	}{
+		{name: "valid", key: key, data: data},
+		{name: "empty key", data: data},
+		{name: "empty data", key: key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHasher(tt.key)
+			for _, part := range [][]byte{tt.data[:len(tt.data)/2], tt.data[len(tt.data)/2:]} {
+				n, err := h.Write(part)
+				assert.NoError(t, err)
+				assert.Equal(t, len(part), n)
+			}
+			assert.Equal(t, Make(tt.data, tt.key), h.Sum())
+		})
+	}
+}
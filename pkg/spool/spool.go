@@ -0,0 +1,118 @@
+// Package spool provides a buffer which keeps small data in memory and spills large data
+// to a temporary file.
+//
+// It is used to read a stream more than once, e.g. to validate a large request body before
+// processing it, without holding the whole stream in memory.
+package spool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrRewound is returned when the data is written to the rewound buffer.
+var ErrRewound = errors.New("spool is rewound")
+
+// Buffer is a spool buffer.
+//
+// The data is written to the buffer first, then the buffer is rewound and the data is read from it.
+type Buffer struct {
+	file      *os.File
+	reader    io.ReadSeeker
+	mem       bytes.Buffer
+	maxMemory int
+}
+
+// New returns a new buffer which keeps up to maxMemory bytes in memory.
+func New(maxMemory int) *Buffer {
+	return &Buffer{maxMemory: maxMemory}
+}
+
+// Write writes the data to the buffer, the data is moved to a temporary file once it exceeds the memory limit.
+func (b *Buffer) Write(p []byte) (int, error) {
+	if b.reader != nil {
+		return 0, ErrRewound
+	}
+	if b.file == nil && b.mem.Len()+len(p) > b.maxMemory {
+		f, err := os.CreateTemp("", "spool-*")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		b.file = f
+		if _, err = b.mem.WriteTo(f); err != nil {
+			return 0, fmt.Errorf("failed to write temp file: %w", err)
+		}
+	}
+	if b.file != nil {
+		//nolint:wrapcheck // ignore
+		return b.file.Write(p)
+	}
+	//nolint:wrapcheck // ignore
+	return b.mem.Write(p)
+}
+
+// ReadFrom writes all data from the reader to the buffer.
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	//nolint:wrapcheck // ignore
+	return io.Copy(struct{ io.Writer }{b}, r)
+}
+
+// Rewind makes the written data available for reading from the beginning.
+func (b *Buffer) Rewind() error {
+	if b.reader != nil {
+		_, err := b.reader.Seek(0, io.SeekStart)
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	if b.file == nil {
+		b.reader = bytes.NewReader(b.mem.Bytes())
+		return nil
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek temp file: %w", err)
+	}
+	b.reader = b.file
+	return nil
+}
+
+// Read reads the data from the rewound buffer.
+func (b *Buffer) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		if err := b.Rewind(); err != nil {
+			return 0, err
+		}
+	}
+	//nolint:wrapcheck // ignore
+	return b.reader.Read(p)
+}
+
+// Seek sets the offset for the next Read.
+func (b *Buffer) Seek(offset int64, whence int) (int64, error) {
+	if b.reader == nil {
+		if err := b.Rewind(); err != nil {
+			return 0, err
+		}
+	}
+	//nolint:wrapcheck // ignore
+	return b.reader.Seek(offset, whence)
+}
+
+// InMemory returns true if the data is kept in memory.
+func (b *Buffer) InMemory() bool {
+	return b.file == nil
+}
+
+// Close removes the temporary file.
+func (b *Buffer) Close() error {
+	b.mem.Reset()
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	b.file = nil
+	return errors.Join(err, os.Remove(name))
+}
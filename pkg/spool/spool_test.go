@@ -0,0 +1,70 @@
+package spool
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		maxMemory    int
+		wantInMemory bool
+	}{
+		{name: "memory", data: "small data", maxMemory: 64, wantInMemory: true},
+		{name: "empty", data: "", maxMemory: 0, wantInMemory: true},
+		{name: "file", data: strings.Repeat("large data ", 100), maxMemory: 64, wantInMemory: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.maxMemory)
+			n, err := b.ReadFrom(iotest.OneByteReader(strings.NewReader(tt.data)))
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(tt.data)), n)
+			assert.Equal(t, tt.wantInMemory, b.InMemory())
+			var name string
+			if !b.InMemory() {
+				name = b.file.Name()
+			}
+
+			require.NoError(t, b.Rewind())
+			got, err := io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.data, string(got))
+
+			_, err = b.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+			got, err = io.ReadAll(b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.data, string(got))
+
+			_, err = b.Write([]byte("more"))
+			assert.ErrorIs(t, err, ErrRewound)
+
+			require.NoError(t, b.Close())
+			if name != "" {
+				_, err = os.Stat(name)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestBuffer_ReadWithoutRewind(t *testing.T) {
+	b := New(4)
+	defer func() {
+		require.NoError(t, b.Close())
+	}()
+	_, err := b.Write([]byte("spilled"))
+	require.NoError(t, err)
+	got, err := io.ReadAll(b)
+	require.NoError(t, err)
+	assert.Equal(t, "spilled", string(got))
+}
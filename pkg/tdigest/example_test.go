@@ -0,0 +1,25 @@
+package tdigest_test
+
+import (
+	"fmt"
+
+	"github.com/korobkovandrey/runtime-metrics/pkg/tdigest"
+)
+
+func Example() {
+	// Collect values on two hosts
+	host1 := tdigest.New(tdigest.DefaultCompression)
+	host2 := tdigest.New(tdigest.DefaultCompression)
+	for i := 1; i <= 50; i++ {
+		host1.Add(float64(i))
+		host2.Add(float64(i + 50))
+	}
+
+	// Merge the digests and estimate the quantiles of all values
+	host1.Merge(host2)
+	fmt.Printf("count: %.0f, min: %.0f, max: %.0f\n", host1.Count(), host1.Min(), host1.Max())
+	fmt.Printf("p50: %.1f\n", host1.Quantile(0.5))
+	// Output:
+	// count: 100, min: 1, max: 100
+	// p50: 50.5
+}
@@ -0,0 +1,372 @@
+// Package tdigest provides a mergeable sketch for estimating quantiles of a stream of values.
+//
+// It implements the merging t-digest by Ted Dunning: values are grouped into centroids,
+// the size of a centroid is limited by the scale function, so the centroids near the tails
+// are small and the quantile estimate there is accurate. Two digests can be merged without
+// loss of accuracy, which makes the digest suitable for aggregating distributions collected
+// on different hosts.
+//
+// TDigest can be marshaled to JSON or to the compact binary encoding and stored in a database with database/sql.
+//
+// TDigest is not safe for concurrent use, but its reading methods do not modify it,
+// so it can be read by many goroutines while it is not written.
+package tdigest
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// DefaultCompression is the compression used by New when the given compression is not positive.
+const DefaultCompression = 100
+
+// Centroid is a group of values represented by their mean and count.
+type Centroid struct {
+	Mean   float64
+	Weight float64
+}
+
+// TDigest is a t-digest sketch.
+type TDigest struct {
+	centroids   []Centroid
+	unmerged    []Centroid
+	compression float64
+	count       float64
+	sum         float64
+	min         float64
+	max         float64
+}
+
+// New returns a new empty digest with the given compression.
+//
+// Higher compression means more centroids and more accurate estimates.
+func New(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	return &TDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Add adds the value to the digest.
+func (t *TDigest) Add(v float64) {
+	t.AddWeighted(v, 1)
+}
+
+// AddWeighted adds the value with the given weight to the digest.
+func (t *TDigest) AddWeighted(v, w float64) {
+	if math.IsNaN(v) || w <= 0 {
+		return
+	}
+	t.unmerged = append(t.unmerged, Centroid{Mean: v, Weight: w})
+	t.count += w
+	t.sum += v * w
+	t.min = math.Min(t.min, v)
+	t.max = math.Max(t.max, v)
+	if float64(len(t.unmerged)) > t.bufferSize() {
+		t.compress()
+	}
+}
+
+// Merge adds all values of the given digest to the digest.
+func (t *TDigest) Merge(o *TDigest) {
+	if o.count == 0 {
+		return
+	}
+	t.unmerged = append(t.unmerged, o.centroids...)
+	t.unmerged = append(t.unmerged, o.unmerged...)
+	t.count += o.count
+	t.sum += o.sum
+	t.min = math.Min(t.min, o.min)
+	t.max = math.Max(t.max, o.max)
+	t.compress()
+}
+
+// Count returns the total weight of the added values.
+func (t *TDigest) Count() float64 {
+	return t.count
+}
+
+// Sum returns the sum of the added values.
+func (t *TDigest) Sum() float64 {
+	return t.sum
+}
+
+// Min returns the minimum added value.
+func (t *TDigest) Min() float64 {
+	return t.min
+}
+
+// Max returns the maximum added value.
+func (t *TDigest) Max() float64 {
+	return t.max
+}
+
+// Centroids returns the centroids of the digest ordered by mean.
+func (t *TDigest) Centroids() []Centroid {
+	return slices.Clone(t.merged())
+}
+
+// Quantile returns the estimate of the q-quantile, q must be in [0, 1].
+//
+// It returns NaN if the digest is empty.
+func (t *TDigest) Quantile(q float64) float64 {
+	centroids := t.merged()
+	n := len(centroids)
+	switch {
+	case n == 0 || math.IsNaN(q):
+		return math.NaN()
+	case q <= 0:
+		return t.min
+	case q >= 1:
+		return t.max
+	case n == 1:
+		return centroids[0].Mean
+	}
+	index := q * t.count
+	first := centroids[0]
+	if index < first.Weight/2 {
+		return t.min + index/(first.Weight/2)*(first.Mean-t.min)
+	}
+	weightSoFar := first.Weight / 2
+	for i := 0; i < n-1; i++ {
+		dw := (centroids[i].Weight + centroids[i+1].Weight) / 2
+		if weightSoFar+dw > index {
+			return centroids[i].Mean + (index-weightSoFar)/dw*(centroids[i+1].Mean-centroids[i].Mean)
+		}
+		weightSoFar += dw
+	}
+	last := centroids[n-1]
+	return last.Mean + (index-weightSoFar)/(last.Weight/2)*(t.max-last.Mean)
+}
+
+// Clone returns a copy of the digest.
+func (t *TDigest) Clone() *TDigest {
+	c := *t
+	c.centroids = slices.Clone(t.centroids)
+	c.unmerged = slices.Clone(t.unmerged)
+	return &c
+}
+
+// bufferSize returns the number of unmerged values which triggers compression.
+func (t *TDigest) bufferSize() float64 {
+	const bufferFactor = 5
+	return bufferFactor * t.compression
+}
+
+// compress merges the unmerged values into the centroids.
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+	t.centroids = t.merged()
+	t.unmerged = t.unmerged[:0]
+}
+
+// merged returns the centroids with the unmerged values merged into them without modifying the digest.
+func (t *TDigest) merged() []Centroid {
+	if len(t.unmerged) == 0 {
+		return t.centroids
+	}
+	all := make([]Centroid, 0, len(t.centroids)+len(t.unmerged))
+	all = append(append(all, t.centroids...), t.unmerged...)
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Mean < all[j].Mean
+	})
+	merged := make([]Centroid, 0, len(all))
+	cur := all[0]
+	weightSoFar := 0.0
+	limit := t.limit(0)
+	for _, c := range all[1:] {
+		if weightSoFar+cur.Weight+c.Weight <= limit {
+			cur.Weight += c.Weight
+			cur.Mean += (c.Mean - cur.Mean) * c.Weight / cur.Weight
+			continue
+		}
+		merged = append(merged, cur)
+		weightSoFar += cur.Weight
+		limit = t.limit(weightSoFar)
+		cur = c
+	}
+	return append(merged, cur)
+}
+
+// limit returns the maximum cumulative weight of the centroid starting at the given weight.
+//
+// It uses the k1 scale function k(q) = δ/2π * asin(2q-1).
+func (t *TDigest) limit(weightSoFar float64) float64 {
+	x := math.Max(-1, math.Min(1, 2*weightSoFar/t.count-1))
+	k := t.compression / (2 * math.Pi) * math.Asin(x)
+	q := (math.Sin((k+1)*2*math.Pi/t.compression) + 1) / 2
+	return q * t.count
+}
+
+// jsonDigest is the JSON representation of the digest.
+type jsonDigest struct {
+	Centroids   [][2]float64 `json:"centroids"`
+	Compression float64      `json:"compression"`
+	Min         float64      `json:"min"`
+	Max         float64      `json:"max"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *TDigest) MarshalJSON() ([]byte, error) {
+	centroids := t.merged()
+	jd := jsonDigest{
+		Centroids:   make([][2]float64, len(centroids)),
+		Compression: t.compression,
+	}
+	for i, c := range centroids {
+		jd.Centroids[i] = [2]float64{c.Mean, c.Weight}
+	}
+	if t.count > 0 {
+		jd.Min, jd.Max = t.min, t.max
+	}
+	//nolint:wrapcheck // ignore
+	return json.Marshal(jd)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TDigest) UnmarshalJSON(data []byte) error {
+	var jd jsonDigest
+	if err := json.Unmarshal(data, &jd); err != nil {
+		//nolint:wrapcheck // ignore
+		return err
+	}
+	return t.load(&jd)
+}
+
+// load sets the digest from its serialized representation.
+//
+// All the values must be finite, the centroids must have positive weights and be ordered by mean,
+// the min must not be greater than the max.
+func (t *TDigest) load(jd *jsonDigest) error {
+	if !isFinite(jd.Compression) {
+		return errors.New("invalid compression")
+	}
+	*t = *New(jd.Compression)
+	for i, c := range jd.Centroids {
+		if !isFinite(c[0]) || !isFinite(c[1]) || c[1] <= 0 {
+			return fmt.Errorf("invalid centroid %d", i)
+		}
+		if i > 0 && c[0] < jd.Centroids[i-1][0] {
+			return errors.New("centroids must be ordered by mean")
+		}
+		t.centroids = append(t.centroids, Centroid{Mean: c[0], Weight: c[1]})
+		t.count += c[1]
+		t.sum += c[0] * c[1]
+	}
+	if t.count > 0 {
+		if !isFinite(jd.Min) || !isFinite(jd.Max) || jd.Min > jd.Max {
+			return errors.New("invalid min and max")
+		}
+		if !isFinite(t.count) || !isFinite(t.sum) {
+			return errors.New("the total weight or sum is not finite")
+		}
+		t.min, t.max = jd.Min, jd.Max
+	}
+	return nil
+}
+
+// isFinite returns true if the value is neither NaN nor infinite.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+const (
+	// float64Size is the size of the float64 in the binary encoding.
+	float64Size = 8
+	// binaryHeaderSize is the size of the compression, min and max in the binary encoding.
+	binaryHeaderSize = 3 * float64Size
+	// centroidSize is the size of the centroid in the binary encoding.
+	centroidSize = 2 * float64Size
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+//
+// The digest is encoded as the compression, min and max followed by the mean and weight
+// of every centroid, all as little-endian float64.
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	centroids := t.merged()
+	data := make([]byte, 0, binaryHeaderSize+len(centroids)*centroidSize)
+	minV, maxV := 0.0, 0.0
+	if t.count > 0 {
+		minV, maxV = t.min, t.max
+	}
+	for _, v := range []float64{t.compression, minV, maxV} {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	for _, c := range centroids {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.Mean))
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(c.Weight))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	if len(data) < binaryHeaderSize || (len(data)-binaryHeaderSize)%centroidSize != 0 {
+		return errors.New("invalid digest size")
+	}
+	float := func(offset int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+	}
+	jd := jsonDigest{
+		Centroids:   make([][2]float64, (len(data)-binaryHeaderSize)/centroidSize),
+		Compression: float(0),
+		Min:         float(float64Size),
+		Max:         float(2 * float64Size),
+	}
+	for i := range jd.Centroids {
+		offset := binaryHeaderSize + i*centroidSize
+		jd.Centroids[i] = [2]float64{float(offset), float(offset + float64Size)}
+	}
+	return t.load(&jd)
+}
+
+// String returns the JSON representation of the digest.
+func (t *TDigest) String() string {
+	data, err := t.MarshalJSON()
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Value implements driver.Valuer, the digest is stored as JSON.
+func (t *TDigest) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	data, err := t.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal digest: %w", err)
+	}
+	return data, nil
+}
+
+// Scan implements sql.Scanner, the digest is stored as JSON.
+func (t *TDigest) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported digest source type %T", src)
+	}
+	if err := t.UnmarshalJSON(data); err != nil {
+		return fmt.Errorf("failed to unmarshal digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package tdigest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTDigest_Quantile(t *testing.T) {
+	td := New(0)
+	values := make([]float64, 10000)
+	r := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // ignore
+	for i := range values {
+		values[i] = r.NormFloat64()*10 + 50
+		td.Add(values[i])
+	}
+	sort.Float64s(values)
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.95, 0.99} {
+		want := values[int(q*float64(len(values)))]
+		assert.InDelta(t, want, td.Quantile(q), 0.5, "q=%v", q)
+	}
+	assert.Equal(t, values[0], td.Quantile(0))
+	assert.Equal(t, values[len(values)-1], td.Quantile(1))
+	assert.InDelta(t, float64(len(values)), td.Count(), 1e-9)
+	assert.Less(t, len(td.Centroids()), len(values)/10)
+}
+
+func TestTDigest_QuantileSmall(t *testing.T) {
+	td := New(100)
+	assert.True(t, math.IsNaN(td.Quantile(0.5)))
+	td.Add(3)
+	assert.InDelta(t, 3.0, td.Quantile(0.5), 1e-9)
+	td.Add(5)
+	assert.InDelta(t, 3.0, td.Quantile(0), 1e-9)
+	assert.InDelta(t, 4.0, td.Quantile(0.5), 1e-9)
+	assert.InDelta(t, 5.0, td.Quantile(1), 1e-9)
+}
+
+func TestTDigest_Merge(t *testing.T) {
+	a, b, all := New(100), New(100), New(100)
+	for i := 0; i < 1000; i++ {
+		a.Add(float64(i))
+		all.Add(float64(i))
+	}
+	for i := 1000; i < 3000; i++ {
+		b.Add(float64(i))
+		all.Add(float64(i))
+	}
+	a.Merge(b)
+	assert.InDelta(t, all.Count(), a.Count(), 1e-9)
+	assert.InDelta(t, all.Sum(), a.Sum(), 1e-6)
+	assert.Equal(t, 0.0, a.Min())
+	assert.Equal(t, 2999.0, a.Max())
+	for _, q := range []float64{0.1, 0.5, 0.99} {
+		assert.InDelta(t, q*3000, a.Quantile(q), 15, "q=%v", q)
+	}
+	a.Merge(New(100))
+	assert.InDelta(t, all.Count(), a.Count(), 1e-9)
+}
+
+func TestTDigest_Clone(t *testing.T) {
+	td := New(100)
+	td.Add(1)
+	c := td.Clone()
+	c.Add(2)
+	assert.InDelta(t, 1.0, td.Count(), 1e-9)
+	assert.InDelta(t, 2.0, c.Count(), 1e-9)
+}
+
+func TestTDigest_JSON(t *testing.T) {
+	td := New(50)
+	for i := 0; i < 500; i++ {
+		td.Add(float64(i % 37))
+	}
+	data, err := json.Marshal(td)
+	require.NoError(t, err)
+
+	got := &TDigest{}
+	require.NoError(t, json.Unmarshal(data, got))
+	assert.Equal(t, td.Centroids(), got.Centroids())
+	assert.InDelta(t, td.Count(), got.Count(), 1e-9)
+	assert.Equal(t, td.Min(), got.Min())
+	assert.Equal(t, td.Max(), got.Max())
+	assert.InDelta(t, td.Quantile(0.9), got.Quantile(0.9), 1e-9)
+
+	empty, err := json.Marshal(New(10))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"centroids":[],"compression":10,"min":0,"max":0}`, string(empty))
+
+	for _, invalid := range []string{
+		`{"centroids":[[2,1],[1,1]],"min":1,"max":2}`,
+		`{"centroids":[[1,0]],"min":1,"max":1}`,
+		`{"centroids":[[1,1]],"min":2,"max":1}`,
+		`{"centroids":[[1,1e308],[2,1e308]],"min":1,"max":2}`,
+		`{"centroids":[[1e308,1e308]],"min":1e308,"max":1e308}`,
+		`[]`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(invalid), &TDigest{}), invalid)
+	}
+}
+
+func TestTDigest_ValueScan(t *testing.T) {
+	td := New(100)
+	td.Add(1)
+	td.Add(2)
+	v, err := td.Value()
+	require.NoError(t, err)
+
+	got := &TDigest{}
+	require.NoError(t, got.Scan(v))
+	assert.Equal(t, td.Centroids(), got.Centroids())
+
+	got = &TDigest{}
+	require.NoError(t, got.Scan(td.String()))
+	assert.Equal(t, td.Centroids(), got.Centroids())
+
+	assert.Error(t, got.Scan(1))
+
+	var null *TDigest
+	v, err = null.Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestTDigest_Binary(t *testing.T) {
+	td := New(50)
+	for i := 1; i <= 1000; i++ {
+		td.Add(float64(i))
+	}
+	data, err := td.MarshalBinary()
+	require.NoError(t, err)
+	var got TDigest
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, td.Centroids(), got.Centroids())
+	assert.InDelta(t, td.Count(), got.Count(), 1e-9)
+	assert.InDelta(t, td.Quantile(0.9), got.Quantile(0.9), 1e-9)
+	assert.InDelta(t, 1.0, got.Min(), 1e-9)
+	assert.InDelta(t, 1000.0, got.Max(), 1e-9)
+
+	data, err = New(0).MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Zero(t, got.Count())
+
+	assert.Error(t, got.UnmarshalBinary(nil))
+	assert.Error(t, got.UnmarshalBinary(data[:len(data)-1]))
+	assert.Error(t, got.UnmarshalBinary(append(data, make([]byte, 8)...)))
+	assert.Error(t, got.UnmarshalBinary(append(data, make([]byte, 16)...)), "zero weight centroid")
+
+	binaryDigest := func(values ...float64) []byte {
+		var b []byte
+		for _, v := range values {
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		}
+		return b
+	}
+	require.NoError(t, got.UnmarshalBinary(binaryDigest(10, 1, 2, 1, 1, 2, 1)))
+	for name, invalid := range map[string][]float64{
+		"NaN compression": {math.NaN(), 1, 1, 1, 1},
+		"NaN min":         {10, math.NaN(), 1, 1, 1},
+		"infinite max":    {10, 1, math.Inf(1), 1, 1},
+		"infinite mean":   {10, 1, 1, math.Inf(1), 1},
+		"infinite weight": {10, 1, 1, 1, math.Inf(1)},
+		"unordered":       {10, 1, 2, 2, 1, 1, 1},
+	} {
+		assert.Error(t, got.UnmarshalBinary(binaryDigest(invalid...)), name)
+	}
+}
+
+func TestTDigest_ReadDoesNotModify(t *testing.T) {
+	td := New(10)
+	for i := 0; i < 20; i++ {
+		td.Add(float64(i))
+	}
+	require.NotEmpty(t, td.unmerged)
+	unmerged := len(td.unmerged)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.InDelta(t, 9.5, td.Quantile(0.5), 1)
+			assert.NotEmpty(t, td.Centroids())
+			_, err := td.MarshalJSON()
+			assert.NoError(t, err)
+			_, err = td.MarshalBinary()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, td.unmerged, unmerged)
+}